@@ -1,10 +1,22 @@
 package main
 
 import (
+	"context"
 	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 
+	"github.com/danzBraham/cats-social/internal/blobstore"
 	"github.com/danzBraham/cats-social/internal/database"
+	"github.com/danzBraham/cats-social/internal/helpers/jwt"
+	"github.com/danzBraham/cats-social/internal/helpers/signedtoken"
 	"github.com/danzBraham/cats-social/internal/http"
+	"github.com/danzBraham/cats-social/internal/mailer"
+	"github.com/danzBraham/cats-social/internal/notifier"
+	"github.com/danzBraham/cats-social/internal/services"
+	"github.com/danzBraham/cats-social/internal/workers"
 	_ "github.com/joho/godotenv/autoload"
 )
 
@@ -16,8 +28,51 @@ func main() {
 	}
 	defer pool.Close()
 
-	server := http.NewServer(addr, pool)
-	if err := server.Launch(); err != nil {
+	if err := jwt.LoadKeySet(); err != nil {
+		log.Fatalf("failed to load the jwt keys: %v", err)
+	}
+
+	if err := signedtoken.LoadSecret(); err != nil {
+		log.Fatalf("failed to load the signing secret: %v", err)
+	}
+
+	mail, err := mailer.NewMailer()
+	if err != nil {
+		log.Fatalf("failed to set up the mailer: %v", err)
+	}
+
+	blobStore, err := blobstore.NewBlobStore()
+	if err != nil {
+		log.Fatalf("failed to set up the blob store: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	hub := notifier.NewHub()
+	svcs := services.NewServices(pool, mail, blobStore, hub)
+	svcs.EventBus.Subscribe("webhooks", svcs.Webhook.HandleEvent)
+
+	// the workers use the pool, so they have to stop before it is closed
+	var wg sync.WaitGroup
+	run := func(f func(ctx context.Context)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			f(ctx)
+		}()
+	}
+	run(func(ctx context.Context) { hub.Listen(ctx, pool) })
+	run(workers.NewImageVariantWorker(svcs.Image).Run)
+	run(workers.NewWebhookWorker(svcs.Webhook).Run)
+	run(workers.NewOutboxWorker(svcs.EventBus).Run)
+	run(workers.NewMatchExpiryWorker(svcs.Match).Run)
+
+	server := http.NewServer(addr, pool, blobStore, svcs)
+	err = server.Launch(ctx)
+	stop()
+	wg.Wait()
+	if err != nil {
 		log.Fatal(err)
 	}
 }
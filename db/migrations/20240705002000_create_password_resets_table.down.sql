@@ -0,0 +1,6 @@
+BEGIN;
+
+DROP INDEX IF EXISTS idx_password_resets_token_hash;
+DROP TABLE IF EXISTS password_resets;
+
+COMMIT;
@@ -0,0 +1,7 @@
+BEGIN;
+
+DROP INDEX IF EXISTS idx_audit_logs_user_id;
+DROP TABLE IF EXISTS audit_logs;
+DROP TABLE IF EXISTS login_attempts;
+
+COMMIT;
@@ -0,0 +1,7 @@
+BEGIN;
+
+DROP INDEX IF EXISTS idx_image_variants_status;
+DROP TABLE IF EXISTS image_variants;
+DROP TYPE IF EXISTS image_variant_status;
+
+COMMIT;
@@ -0,0 +1,31 @@
+BEGIN;
+
+DO $$
+BEGIN
+  IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'image_variant_status') THEN
+    CREATE TYPE image_variant_status AS ENUM ('pending', 'processing', 'ready', 'failed');
+  END IF;
+END $$;
+
+CREATE TABLE IF NOT EXISTS image_variants (
+  source_url TEXT PRIMARY KEY NOT NULL,
+  source_key TEXT NOT NULL,
+  cat_id VARCHAR(26) NOT NULL,
+  status image_variant_status NOT NULL DEFAULT 'pending',
+  attempts INT NOT NULL DEFAULT 0,
+  width INT,
+  height INT,
+  thumbnail_url TEXT,
+  thumbnail_width INT,
+  thumbnail_height INT,
+  medium_url TEXT,
+  medium_width INT,
+  medium_height INT,
+  created_at TIMESTAMP DEFAULT NOW(),
+  updated_at TIMESTAMP DEFAULT NOW(),
+  FOREIGN KEY (cat_id) REFERENCES cats(id) ON DELETE NO ACTION ON UPDATE NO ACTION
+);
+
+CREATE INDEX IF NOT EXISTS idx_image_variants_status ON image_variants (status, created_at);
+
+COMMIT;
@@ -0,0 +1,7 @@
+BEGIN;
+
+DROP INDEX IF EXISTS idx_cat_images_primary;
+DROP INDEX IF EXISTS idx_cat_images_cat_id;
+DROP TABLE IF EXISTS cat_images;
+
+COMMIT;
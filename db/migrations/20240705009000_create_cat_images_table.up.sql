@@ -0,0 +1,32 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS cat_images (
+  id VARCHAR(26) PRIMARY KEY NOT NULL,
+  cat_id VARCHAR(26) NOT NULL,
+  url TEXT NOT NULL,
+  position INT NOT NULL,
+  caption VARCHAR(200) NOT NULL DEFAULT '',
+  is_primary BOOLEAN NOT NULL DEFAULT false,
+  created_at TIMESTAMP DEFAULT NOW(),
+  updated_at TIMESTAMP DEFAULT NOW(),
+  FOREIGN KEY (cat_id) REFERENCES cats(id) ON DELETE NO ACTION ON UPDATE NO ACTION
+);
+
+CREATE INDEX IF NOT EXISTS idx_cat_images_cat_id ON cat_images (cat_id, position);
+-- a cat has at most one primary image
+CREATE UNIQUE INDEX IF NOT EXISTS idx_cat_images_primary ON cat_images (cat_id) WHERE is_primary;
+
+-- move the existing images over, the first one becomes the primary image
+INSERT INTO cat_images (id, cat_id, url, position, is_primary)
+SELECT
+  UPPER(SUBSTR(MD5(c.id || ':' || i.position), 1, 26)),
+  c.id,
+  i.url,
+  i.position - 1,
+  i.position = 1
+FROM
+  cats c,
+  UNNEST(c.image_urls) WITH ORDINALITY AS i(url, position)
+ON CONFLICT DO NOTHING;
+
+COMMIT;
@@ -0,0 +1,8 @@
+BEGIN;
+
+DROP INDEX IF EXISTS idx_cats_search_vector;
+DROP TRIGGER IF EXISTS trg_cats_search_vector ON cats;
+DROP FUNCTION IF EXISTS cats_search_vector_update();
+ALTER TABLE cats DROP COLUMN IF EXISTS search_vector;
+
+COMMIT;
@@ -0,0 +1,31 @@
+BEGIN;
+
+ALTER TABLE cats ADD COLUMN IF NOT EXISTS search_vector TSVECTOR NOT NULL DEFAULT '';
+
+-- the 'simple' configuration doesn't stem, so prefix matching works on the
+-- words as they were written. Casting the race enum to text isn't immutable,
+-- which rules out a generated column.
+CREATE OR REPLACE FUNCTION cats_search_vector_update() RETURNS TRIGGER AS $$
+BEGIN
+  NEW.search_vector :=
+    setweight(to_tsvector('simple', NEW.name), 'A') ||
+    setweight(to_tsvector('simple', NEW.race::TEXT), 'B') ||
+    setweight(to_tsvector('simple', NEW.description), 'C');
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_cats_search_vector ON cats;
+CREATE TRIGGER trg_cats_search_vector
+  BEFORE INSERT OR UPDATE OF name, race, description ON cats
+  FOR EACH ROW EXECUTE FUNCTION cats_search_vector_update();
+
+UPDATE cats SET
+  search_vector =
+    setweight(to_tsvector('simple', name), 'A') ||
+    setweight(to_tsvector('simple', race::TEXT), 'B') ||
+    setweight(to_tsvector('simple', description), 'C');
+
+CREATE INDEX IF NOT EXISTS idx_cats_search_vector ON cats USING GIN (search_vector);
+
+COMMIT;
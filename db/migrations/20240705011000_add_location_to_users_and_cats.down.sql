@@ -0,0 +1,15 @@
+BEGIN;
+
+DROP INDEX IF EXISTS idx_cats_location;
+
+ALTER TABLE cats DROP CONSTRAINT IF EXISTS cats_location_check;
+ALTER TABLE cats DROP COLUMN IF EXISTS city;
+ALTER TABLE cats DROP COLUMN IF EXISTS longitude;
+ALTER TABLE cats DROP COLUMN IF EXISTS latitude;
+
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_location_check;
+ALTER TABLE users DROP COLUMN IF EXISTS city;
+ALTER TABLE users DROP COLUMN IF EXISTS longitude;
+ALTER TABLE users DROP COLUMN IF EXISTS latitude;
+
+COMMIT;
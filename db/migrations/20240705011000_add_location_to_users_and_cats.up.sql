@@ -0,0 +1,16 @@
+BEGIN;
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS latitude DOUBLE PRECISION;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS longitude DOUBLE PRECISION;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS city VARCHAR(100) NOT NULL DEFAULT '';
+ALTER TABLE users ADD CONSTRAINT users_location_check CHECK ((latitude IS NULL) = (longitude IS NULL));
+
+ALTER TABLE cats ADD COLUMN IF NOT EXISTS latitude DOUBLE PRECISION;
+ALTER TABLE cats ADD COLUMN IF NOT EXISTS longitude DOUBLE PRECISION;
+ALTER TABLE cats ADD COLUMN IF NOT EXISTS city VARCHAR(100) NOT NULL DEFAULT '';
+ALTER TABLE cats ADD CONSTRAINT cats_location_check CHECK ((latitude IS NULL) = (longitude IS NULL));
+
+-- narrows distance searches down to a bounding box before computing distances
+CREATE INDEX IF NOT EXISTS idx_cats_location ON cats (latitude, longitude);
+
+COMMIT;
@@ -0,0 +1,15 @@
+BEGIN;
+
+DROP INDEX IF EXISTS idx_match_requests_pending_created_at;
+
+-- enum values can't be dropped, so the type is rebuilt without 'expired'
+UPDATE match_requests SET status = 'rejected' WHERE status = 'expired';
+
+ALTER TYPE match_status RENAME TO match_status_old;
+CREATE TYPE match_status AS ENUM ('pending', 'approved', 'rejected');
+ALTER TABLE match_requests ALTER COLUMN status DROP DEFAULT;
+ALTER TABLE match_requests ALTER COLUMN status TYPE match_status USING status::text::match_status;
+ALTER TABLE match_requests ALTER COLUMN status SET DEFAULT 'pending';
+DROP TYPE match_status_old;
+
+COMMIT;
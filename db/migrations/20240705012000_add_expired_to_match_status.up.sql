@@ -0,0 +1,9 @@
+BEGIN;
+
+ALTER TYPE match_status ADD VALUE IF NOT EXISTS 'expired';
+
+-- the expiry scheduler only looks at pending requests
+CREATE INDEX IF NOT EXISTS idx_match_requests_pending_created_at ON match_requests (created_at)
+  WHERE status = 'pending' AND is_deleted = false;
+
+COMMIT;
@@ -0,0 +1,19 @@
+BEGIN;
+
+ALTER TABLE match_requests DROP COLUMN IF EXISTS unmatched_at;
+ALTER TABLE match_requests DROP COLUMN IF EXISTS unmatched_by;
+
+-- enum values can't be dropped, so the type is rebuilt without 'unmatched'
+UPDATE match_requests SET status = 'rejected', is_deleted = true WHERE status = 'unmatched';
+
+DROP INDEX IF EXISTS idx_match_requests_pending_created_at;
+ALTER TYPE match_status RENAME TO match_status_old;
+CREATE TYPE match_status AS ENUM ('pending', 'approved', 'rejected', 'expired');
+ALTER TABLE match_requests ALTER COLUMN status DROP DEFAULT;
+ALTER TABLE match_requests ALTER COLUMN status TYPE match_status USING status::text::match_status;
+ALTER TABLE match_requests ALTER COLUMN status SET DEFAULT 'pending';
+DROP TYPE match_status_old;
+CREATE INDEX IF NOT EXISTS idx_match_requests_pending_created_at ON match_requests (created_at)
+  WHERE status = 'pending' AND is_deleted = false;
+
+COMMIT;
@@ -0,0 +1,8 @@
+BEGIN;
+
+ALTER TYPE match_status ADD VALUE IF NOT EXISTS 'unmatched';
+
+ALTER TABLE match_requests ADD COLUMN IF NOT EXISTS unmatched_by VARCHAR(26) REFERENCES users(id) ON DELETE SET NULL;
+ALTER TABLE match_requests ADD COLUMN IF NOT EXISTS unmatched_at TIMESTAMP;
+
+COMMIT;
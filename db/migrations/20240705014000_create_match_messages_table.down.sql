@@ -0,0 +1,7 @@
+BEGIN;
+
+DROP INDEX IF EXISTS idx_match_messages_match_id_created_at;
+
+DROP TABLE IF EXISTS match_messages;
+
+COMMIT;
@@ -0,0 +1,16 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS match_messages (
+  id VARCHAR(26) PRIMARY KEY NOT NULL,
+  match_id VARCHAR(26) NOT NULL,
+  sender_id VARCHAR(26) NOT NULL,
+  body VARCHAR(1000) NOT NULL,
+  read_at TIMESTAMP,
+  created_at TIMESTAMP DEFAULT NOW(),
+  FOREIGN KEY (match_id) REFERENCES match_requests(id) ON DELETE CASCADE ON UPDATE NO ACTION,
+  FOREIGN KEY (sender_id) REFERENCES users(id) ON DELETE NO ACTION ON UPDATE NO ACTION
+);
+
+CREATE INDEX IF NOT EXISTS idx_match_messages_match_id_created_at ON match_messages (match_id, created_at, id);
+
+COMMIT;
@@ -0,0 +1,8 @@
+BEGIN;
+
+DROP INDEX IF EXISTS idx_notifications_unread;
+DROP INDEX IF EXISTS idx_notifications_user_id_created_at;
+
+DROP TABLE IF EXISTS notifications;
+
+COMMIT;
@@ -0,0 +1,10 @@
+BEGIN;
+
+DROP INDEX IF EXISTS idx_outbox_events_due;
+DROP INDEX IF EXISTS idx_outbox_events_aggregate_id;
+
+DROP TABLE IF EXISTS outbox_events;
+
+DROP TYPE IF EXISTS outbox_event_status;
+
+COMMIT;
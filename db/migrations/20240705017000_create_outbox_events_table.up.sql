@@ -0,0 +1,31 @@
+BEGIN;
+
+DO $$
+BEGIN
+  IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'outbox_event_status') THEN
+    CREATE TYPE outbox_event_status AS ENUM ('pending', 'processing', 'dispatched', 'failed');
+  END IF;
+END $$;
+
+-- domain events are written in the same transaction as the change they
+-- describe and dispatched to the handlers afterwards
+CREATE TABLE IF NOT EXISTS outbox_events (
+  id VARCHAR(26) PRIMARY KEY NOT NULL,
+  type VARCHAR(50) NOT NULL,
+  aggregate_id VARCHAR(26) NOT NULL,
+  payload JSONB NOT NULL,
+  status outbox_event_status NOT NULL DEFAULT 'pending',
+  handled_by TEXT[] NOT NULL DEFAULT '{}',
+  attempts INT NOT NULL DEFAULT 0,
+  next_attempt_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  error TEXT,
+  dispatched_at TIMESTAMP,
+  created_at TIMESTAMP DEFAULT NOW(),
+  updated_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_outbox_events_aggregate_id ON outbox_events (aggregate_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_outbox_events_due ON outbox_events (next_attempt_at)
+  WHERE status IN ('pending', 'processing');
+
+COMMIT;
@@ -0,0 +1,8 @@
+BEGIN;
+
+DROP INDEX IF EXISTS idx_login_challenges_user_id;
+DROP INDEX IF EXISTS idx_login_challenges_token_hash;
+
+DROP TABLE IF EXISTS login_challenges;
+
+COMMIT;
@@ -0,0 +1,17 @@
+BEGIN;
+
+-- a login challenge is issued after the password of a 2FA account checks out
+-- and is deleted once it's exchanged for a session
+CREATE TABLE IF NOT EXISTS login_challenges (
+  id VARCHAR(26) PRIMARY KEY NOT NULL,
+  user_id VARCHAR(26) NOT NULL,
+  token_hash VARCHAR(64) NOT NULL,
+  expires_at TIMESTAMP NOT NULL,
+  created_at TIMESTAMP DEFAULT NOW(),
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE ON UPDATE NO ACTION
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_login_challenges_token_hash ON login_challenges (token_hash);
+CREATE INDEX IF NOT EXISTS idx_login_challenges_user_id ON login_challenges (user_id);
+
+COMMIT;
@@ -0,0 +1,8 @@
+BEGIN;
+
+DROP INDEX IF EXISTS idx_stream_tickets_user_id;
+DROP INDEX IF EXISTS idx_stream_tickets_token_hash;
+
+DROP TABLE IF EXISTS stream_tickets;
+
+COMMIT;
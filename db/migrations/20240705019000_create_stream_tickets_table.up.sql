@@ -0,0 +1,20 @@
+BEGIN;
+
+-- a stream ticket lets a browser open the notification stream, since
+-- EventSource and WebSocket can't send an Authorization header. It's issued
+-- for a session and deleted the first time it's used.
+CREATE TABLE IF NOT EXISTS stream_tickets (
+  id VARCHAR(26) PRIMARY KEY NOT NULL,
+  user_id VARCHAR(26) NOT NULL,
+  session_id VARCHAR(26) NOT NULL,
+  token_hash VARCHAR(64) NOT NULL,
+  expires_at TIMESTAMP NOT NULL,
+  created_at TIMESTAMP DEFAULT NOW(),
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE ON UPDATE NO ACTION,
+  FOREIGN KEY (session_id) REFERENCES sessions(id) ON DELETE CASCADE ON UPDATE NO ACTION
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_stream_tickets_token_hash ON stream_tickets (token_hash);
+CREATE INDEX IF NOT EXISTS idx_stream_tickets_user_id ON stream_tickets (user_id);
+
+COMMIT;
@@ -0,0 +1,7 @@
+BEGIN;
+
+DROP INDEX IF EXISTS idx_webhook_deliveries_webhook_id_event_id;
+
+ALTER TABLE webhook_deliveries DROP COLUMN IF EXISTS redelivery_of;
+
+COMMIT;
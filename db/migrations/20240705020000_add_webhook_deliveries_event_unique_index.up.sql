@@ -0,0 +1,29 @@
+BEGIN;
+
+ALTER TABLE webhook_deliveries ADD COLUMN IF NOT EXISTS redelivery_of VARCHAR(26);
+
+-- the earliest delivery of an event to a webhook is the original one, the
+-- later ones were queued by a redelivery or by a dispatch that ran twice
+UPDATE
+  webhook_deliveries d
+SET
+  redelivery_of = o.id
+FROM (
+  SELECT DISTINCT ON (webhook_id, event_id)
+    id, webhook_id, event_id
+  FROM
+    webhook_deliveries
+  ORDER BY
+    webhook_id, event_id, created_at, id
+) o
+WHERE
+  d.webhook_id = o.webhook_id
+  AND d.event_id = o.event_id
+  AND d.id <> o.id;
+
+-- an event is delivered once to a webhook, only explicit redeliveries may
+-- send it again
+CREATE UNIQUE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook_id_event_id ON webhook_deliveries (webhook_id, event_id)
+  WHERE redelivery_of IS NULL;
+
+COMMIT;
@@ -0,0 +1,5 @@
+BEGIN;
+
+ALTER TABLE cat_images DROP CONSTRAINT IF EXISTS cat_images_cat_id_position_key;
+
+COMMIT;
@@ -0,0 +1,24 @@
+BEGIN;
+
+-- concurrent uploads could give two images of a cat the same position,
+-- number the images of every cat again before the constraint is added
+UPDATE
+  cat_images ci
+SET
+  position = o.position
+FROM (
+  SELECT
+    id,
+    ROW_NUMBER() OVER (PARTITION BY cat_id ORDER BY position, created_at, id) - 1 AS position
+  FROM
+    cat_images
+) o
+WHERE
+  ci.id = o.id
+  AND ci.position <> o.position;
+
+-- deferred, images are moved one by one when a cat is reordered or updated
+ALTER TABLE cat_images
+  ADD CONSTRAINT cat_images_cat_id_position_key UNIQUE (cat_id, position) DEFERRABLE INITIALLY DEFERRED;
+
+COMMIT;
@@ -0,0 +1,60 @@
+package blobstore
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+)
+
+type BlobStore interface {
+	Put(ctx context.Context, key, contentType string, data []byte) error
+	Get(ctx context.Context, key string) ([]byte, error)
+	Delete(ctx context.Context, key string) error
+	// URL returns the public URL the blob is served from.
+	URL(key string) string
+	// Key is the inverse of URL. It reports false for URLs that don't point
+	// into this store, e.g. images hosted somewhere else.
+	Key(url string) (string, bool)
+}
+
+// NewBlobStore builds the store selected by BLOB_DRIVER ("local" or "s3").
+// The local store is used when no driver is configured.
+func NewBlobStore() (BlobStore, error) {
+	switch driver := os.Getenv("BLOB_DRIVER"); driver {
+	case "s3":
+		return NewS3BlobStore(
+			os.Getenv("S3_ENDPOINT"),
+			os.Getenv("S3_REGION"),
+			os.Getenv("S3_BUCKET"),
+			os.Getenv("S3_ACCESS_KEY_ID"),
+			os.Getenv("S3_SECRET_ACCESS_KEY"),
+			os.Getenv("BLOB_PUBLIC_URL"),
+		)
+	case "", "local":
+		publicURL := os.Getenv("BLOB_PUBLIC_URL")
+		if publicURL == "" {
+			appURL := os.Getenv("APP_URL")
+			if appURL == "" {
+				appURL = "http://localhost:8080"
+			}
+			publicURL = strings.TrimSuffix(appURL, "/") + LocalRoutePrefix
+		}
+		return NewLocalBlobStore(os.Getenv("BLOB_DIR"), publicURL)
+	default:
+		return nil, fmt.Errorf("unknown blob driver: %s", driver)
+	}
+}
+
+func joinURL(base, key string) string {
+	return strings.TrimSuffix(base, "/") + "/" + key
+}
+
+func trimURL(base, url string) (string, bool) {
+	prefix := strings.TrimSuffix(base, "/") + "/"
+	key, ok := strings.CutPrefix(url, prefix)
+	if !ok || key == "" {
+		return "", false
+	}
+	return key, true
+}
@@ -0,0 +1,73 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LocalRoutePrefix is where the server mounts a LocalBlobStore.
+const LocalRoutePrefix = "/images"
+
+// LocalBlobStore keeps blobs as files under Dir and serves them itself.
+type LocalBlobStore struct {
+	Dir       string
+	PublicURL string
+}
+
+func NewLocalBlobStore(dir, publicURL string) (BlobStore, error) {
+	if dir == "" {
+		dir = "uploads"
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &LocalBlobStore{Dir: dir, PublicURL: publicURL}, nil
+}
+
+func (s *LocalBlobStore) Put(ctx context.Context, key, contentType string, data []byte) error {
+	path := s.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+func (s *LocalBlobStore) Get(ctx context.Context, key string) ([]byte, error) {
+	return os.ReadFile(s.path(key))
+}
+
+func (s *LocalBlobStore) Delete(ctx context.Context, key string) error {
+	err := os.Remove(s.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (s *LocalBlobStore) URL(key string) string {
+	return joinURL(s.PublicURL, key)
+}
+
+func (s *LocalBlobStore) Key(url string) (string, bool) {
+	return trimURL(s.PublicURL, url)
+}
+
+// ServeHTTP serves a single blob. The request path is the key, so the handler
+// has to be mounted with http.StripPrefix(LocalRoutePrefix, ...).
+func (s *LocalBlobStore) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if strings.HasSuffix(r.URL.Path, "/") {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	http.FileServer(http.Dir(s.Dir)).ServeHTTP(w, r)
+}
+
+func (s *LocalBlobStore) path(key string) string {
+	return filepath.Join(s.Dir, filepath.FromSlash(filepath.Clean("/"+key)))
+}
@@ -0,0 +1,264 @@
+package blobstore
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	testAccessKeyId     = "AKIDEXAMPLE"
+	testSecretAccessKey = "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"
+	testRegion          = "eu-west-1"
+	testBucket          = "cats"
+)
+
+var authorizationPattern = regexp.MustCompile(`^AWS4-HMAC-SHA256 Credential=([^/]+)/(\d{8})/([^/]+)/s3/aws4_request, SignedHeaders=host;x-amz-content-sha256;x-amz-date, Signature=([0-9a-f]{64})$`)
+
+// fakeS3 is a stand-in for an S3 bucket. It checks the signature of every
+// request the way S3 does and keeps the objects in memory.
+type fakeS3 struct {
+	t       *testing.T
+	secret  string
+	mu      sync.Mutex
+	objects map[string][]byte
+	types   map[string]string
+}
+
+func newFakeS3(t *testing.T) (*fakeS3, *httptest.Server) {
+	fake := &fakeS3{
+		t:       t,
+		secret:  testSecretAccessKey,
+		objects: map[string][]byte{},
+		types:   map[string]string{},
+	}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	return fake, server
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !f.verify(r, body) {
+		http.Error(w, "SignatureDoesNotMatch", http.StatusForbidden)
+		return
+	}
+
+	key, ok := strings.CutPrefix(r.URL.Path, "/"+testBucket+"/")
+	if !ok {
+		http.Error(w, "NoSuchBucket", http.StatusNotFound)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch r.Method {
+	case http.MethodPut:
+		f.objects[key] = body
+		f.types[key] = r.Header.Get("Content-Type")
+	case http.MethodGet:
+		data, ok := f.objects[key]
+		if !ok {
+			http.Error(w, "NoSuchKey", http.StatusNotFound)
+			return
+		}
+		w.Write(data)
+	case http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (f *fakeS3) verify(r *http.Request, body []byte) bool {
+	match := authorizationPattern.FindStringSubmatch(r.Header.Get("Authorization"))
+	if match == nil {
+		f.t.Errorf("malformed Authorization header %q", r.Header.Get("Authorization"))
+		return false
+	}
+	accessKeyId, date, region, signature := match[1], match[2], match[3], match[4]
+
+	amzDate := r.Header.Get("X-Amz-Date")
+	if _, err := time.Parse("20060102T150405Z", amzDate); err != nil || !strings.HasPrefix(amzDate, date) {
+		f.t.Errorf("X-Amz-Date %q doesn't match the credential date %q", amzDate, date)
+		return false
+	}
+
+	sum := sha256.Sum256(body)
+	payloadHash := hex.EncodeToString(sum[:])
+	if r.Header.Get("X-Amz-Content-Sha256") != payloadHash {
+		f.t.Errorf("X-Amz-Content-Sha256 = %q, want %q", r.Header.Get("X-Amz-Content-Sha256"), payloadHash)
+		return false
+	}
+
+	if accessKeyId != testAccessKeyId || region != testRegion {
+		return false
+	}
+
+	canonicalRequest := r.Method + "\n" +
+		r.URL.EscapedPath() + "\n" +
+		"\n" +
+		"host:" + r.Host + "\n" +
+		"x-amz-content-sha256:" + payloadHash + "\n" +
+		"x-amz-date:" + amzDate + "\n" +
+		"\n" +
+		"host;x-amz-content-sha256;x-amz-date\n" +
+		payloadHash
+	canonicalSum := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" +
+		amzDate + "\n" +
+		date + "/" + region + "/s3/aws4_request\n" +
+		hex.EncodeToString(canonicalSum[:])
+
+	key := []byte("AWS4" + f.secret)
+	for _, part := range []string{date, region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	return hex.EncodeToString(hmacSHA256(key, stringToSign)) == signature
+}
+
+func newTestS3Store(t *testing.T, endpoint, secretAccessKey string) BlobStore {
+	t.Helper()
+	store, err := NewS3BlobStore(endpoint, testRegion, testBucket, testAccessKeyId, secretAccessKey, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func TestS3BlobStoreRoundTrip(t *testing.T) {
+	fake, server := newFakeS3(t)
+	store := newTestS3Store(t, server.URL, testSecretAccessKey)
+	ctx := context.Background()
+
+	keys := []string{
+		"cats/01J1Z2/photo.jpg",
+		"cats/01J1Z2/a photo+with spaces.jpg",
+		"cats/01J1Z2/ünïcode.png",
+	}
+	for _, key := range keys {
+		t.Run(key, func(t *testing.T) {
+			data := []byte("image bytes of " + key)
+			if err := store.Put(ctx, key, "image/jpeg", data); err != nil {
+				t.Fatalf("Put() error = %v", err)
+			}
+			if fake.types[key] != "image/jpeg" {
+				t.Errorf("stored content type = %q, want image/jpeg", fake.types[key])
+			}
+
+			got, err := store.Get(ctx, key)
+			if err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			if string(got) != string(data) {
+				t.Errorf("Get() = %q, want %q", got, data)
+			}
+
+			if err := store.Delete(ctx, key); err != nil {
+				t.Fatalf("Delete() error = %v", err)
+			}
+			if _, err := store.Get(ctx, key); err == nil {
+				t.Error("Get() after Delete() error = nil, want an error")
+			}
+		})
+	}
+}
+
+func TestS3BlobStoreWrongSecret(t *testing.T) {
+	_, server := newFakeS3(t)
+	store := newTestS3Store(t, server.URL, "not the secret")
+
+	err := store.Put(context.Background(), "cats/01J1Z2/photo.jpg", "image/jpeg", []byte("data"))
+	if err == nil || !strings.Contains(err.Error(), "403") {
+		t.Errorf("Put() error = %v, want a 403", err)
+	}
+}
+
+func TestS3BlobStoreURL(t *testing.T) {
+	tests := []struct {
+		name      string
+		publicURL string
+		key       string
+		wantURL   string
+	}{
+		{"bucket url", "", "cats/01J1Z2/photo.jpg", "http://s3.local:9000/cats/cats/01J1Z2/photo.jpg"},
+		{"cdn", "https://cdn.example.com", "cats/01J1Z2/photo.jpg", "https://cdn.example.com/cats/01J1Z2/photo.jpg"},
+		{"cdn with trailing slash", "https://cdn.example.com/", "cats/01J1Z2/photo.jpg", "https://cdn.example.com/cats/01J1Z2/photo.jpg"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, err := NewS3BlobStore("http://s3.local:9000/", testRegion, testBucket, testAccessKeyId, testSecretAccessKey, tt.publicURL)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			url := store.URL(tt.key)
+			if url != tt.wantURL {
+				t.Errorf("URL() = %q, want %q", url, tt.wantURL)
+			}
+			key, ok := store.Key(url)
+			if !ok || key != tt.key {
+				t.Errorf("Key(%q) = %q, %v, want %q, true", url, key, ok, tt.key)
+			}
+		})
+	}
+}
+
+func TestS3BlobStoreKeyRejectsForeignURLs(t *testing.T) {
+	store, err := NewS3BlobStore("http://s3.local:9000", testRegion, testBucket, testAccessKeyId, testSecretAccessKey, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	urls := []string{
+		"https://example.com/cats/photo.jpg",
+		"http://s3.local:9000/other-bucket/photo.jpg",
+		"http://s3.local:9000/cats/",
+		"http://s3.local:9000/cats",
+	}
+	for _, url := range urls {
+		if key, ok := store.Key(url); ok {
+			t.Errorf("Key(%q) = %q, true, want false", url, key)
+		}
+	}
+}
+
+func TestNewS3BlobStore(t *testing.T) {
+	tests := []struct {
+		name       string
+		endpoint   string
+		region     string
+		bucket     string
+		wantErr    bool
+		wantRegion string
+	}{
+		{"missing endpoint", "", testRegion, testBucket, true, ""},
+		{"missing bucket", "http://s3.local:9000", testRegion, "", true, ""},
+		{"default region", "http://s3.local:9000", "", testBucket, false, "us-east-1"},
+		{"region", "http://s3.local:9000", testRegion, testBucket, false, testRegion},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, err := NewS3BlobStore(tt.endpoint, tt.region, tt.bucket, testAccessKeyId, testSecretAccessKey, "")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewS3BlobStore() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && store.(*S3BlobStore).Region != tt.wantRegion {
+				t.Errorf("Region = %q, want %q", store.(*S3BlobStore).Region, tt.wantRegion)
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package apikeyentity
+
+type Scope string
+
+const (
+	CatsRead     Scope = "cats:read"
+	CatsWrite    Scope = "cats:write"
+	MatchesRead  Scope = "matches:read"
+	MatchesWrite Scope = "matches:write"
+)
+
+type ApiKey struct {
+	Id         string
+	UserId     string
+	Name       string
+	Prefix     string
+	KeyHash    string
+	Scopes     []Scope
+	LastUsedAt string
+	RevokedAt  string
+	CreatedAt  string
+}
+
+type CreateApiKeyRequest struct {
+	Name   string  `json:"name" validate:"required,min=1,max=50"`
+	Scopes []Scope `json:"scopes" validate:"required,min=1,unique,dive,oneof='cats:read' 'cats:write' 'matches:read' 'matches:write'"`
+}
+
+type CreateApiKeyResponse struct {
+	Id        string  `json:"id"`
+	Name      string  `json:"name"`
+	Key       string  `json:"key"`
+	Scopes    []Scope `json:"scopes"`
+	CreatedAt string  `json:"createdAt"`
+}
+
+type GetApiKeyResponse struct {
+	Id         string  `json:"id"`
+	Name       string  `json:"name"`
+	Prefix     string  `json:"prefix"`
+	Scopes     []Scope `json:"scopes"`
+	LastUsedAt *string `json:"lastUsedAt"`
+	CreatedAt  string  `json:"createdAt"`
+}
@@ -0,0 +1,20 @@
+package auditentity
+
+type Action string
+
+const (
+	LoginLockout    Action = "login_lockout"
+	UserSuspended   Action = "user_suspended"
+	UserUnsuspended Action = "user_unsuspended"
+	CatForceDeleted Action = "cat_force_deleted"
+	MatchCancelled  Action = "match_cancelled"
+)
+
+type AuditLog struct {
+	Id        string
+	UserId    string
+	Action    Action
+	Subject   string
+	IpAddress string
+	CreatedAt string
+}
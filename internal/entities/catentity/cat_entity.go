@@ -1,5 +1,15 @@
 package catentity
 
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/danzBraham/cats-social/internal/entities/imageentity"
+	"github.com/danzBraham/cats-social/internal/entities/locationentity"
+	"github.com/danzBraham/cats-social/internal/errors/caterror"
+)
+
 type Race string
 
 const (
@@ -30,6 +40,7 @@ type Cat struct {
 	AgeInMonth  int
 	Description string
 	ImageUrls   []string
+	Location    *locationentity.Location
 	HasMatched  bool
 	OwnerId     string
 	CreatedAt   string
@@ -42,7 +53,9 @@ type CreateCatRequest struct {
 	Sex         Sex      `json:"sex" validate:"required,oneof='male' 'female'"`
 	AgeInMonth  int      `json:"ageInMonth" validate:"required,min=1,max=120082"`
 	Description string   `json:"description" validate:"required,min=1,max=200"`
-	ImageUrls   []string `json:"imageUrls" validate:"required,min=1,dive,required,http_url"`
+	ImageUrls   []string `json:"imageUrls" validate:"omitempty,dive,required,http_url"`
+	// Location defaults to the owner's location
+	Location *locationentity.Location `json:"location"`
 }
 
 type CreateCatResponse struct {
@@ -54,24 +67,186 @@ type CatQueryParams struct {
 	Id         string
 	Limit      int
 	Offset     int
-	Race       Race
-	Sex        Sex
+	Races      []Race
+	Sexes      []Sex
 	HasMatched bool
-	AgeInMonth string
+	AgeInMonth []AgeFilter
 	Owned      bool
 	Search     string
+	Near       *NearFilter
+	Sort       []CatSort
+	// Cursor continues after the cat it points at, Offset is ignored then
+	Cursor       *CatCursor
+	IncludeTotal bool
+}
+
+// AgeFilter compares age_in_month with Value, Operator is one of =, <, <=, >
+// or >=.
+type AgeFilter struct {
+	Operator string
+	Value    int
+}
+
+// NearFilter only keeps cats with a location, within RadiusKm of the point
+// unless it is 0.
+type NearFilter struct {
+	Latitude  float64
+	Longitude float64
+	RadiusKm  float64
+}
+
+type CatSortField string
+
+const (
+	SortByName       CatSortField = "name"
+	SortByAgeInMonth CatSortField = "ageInMonth"
+	SortByCreatedAt  CatSortField = "createdAt"
+	SortByUpdatedAt  CatSortField = "updatedAt"
+	// SortByRelevance ranks the cats matching Search, names weigh the most
+	SortByRelevance CatSortField = "relevance"
+	// SortByDistance orders by the distance to the Near point
+	SortByDistance CatSortField = "distance"
+)
+
+type CatSort struct {
+	Field CatSortField
+	Desc  bool
+}
+
+// DefaultCatSort lists the most recently updated cats first.
+var DefaultCatSort = []CatSort{{Field: SortByUpdatedAt, Desc: true}}
+
+// DefaultCatSearchSort lists the most relevant cats first when searching.
+var DefaultCatSearchSort = []CatSort{{Field: SortByRelevance, Desc: true}}
+
+// DefaultCatNearSort lists the closest cats first when filtering by location.
+var DefaultCatNearSort = []CatSort{{Field: SortByDistance}}
+
+// SortKey identifies a sort, a cursor is only valid for the sort it was
+// issued for.
+func SortKey(sorts []CatSort) string {
+	keys := make([]string, 0, len(sorts))
+	for _, sort := range sorts {
+		direction := "asc"
+		if sort.Desc {
+			direction = "desc"
+		}
+		keys = append(keys, string(sort.Field)+":"+direction)
+	}
+	return strings.Join(keys, ",")
+}
+
+// CatCursor holds the sort values of the cat a page continues after, one per
+// sort field followed by the cat id.
+type CatCursor struct {
+	Values []interface{}
+}
+
+// CatPage is one page of GET /v1/cat. Total is only counted on request.
+type CatPage struct {
+	Cats       []*GetCatResponse
+	NextCursor string
+	HasMore    bool
+	Total      *int
 }
 
 type GetCatResponse struct {
-	Id          string   `json:"id"`
-	Name        string   `json:"name"`
-	Race        Race     `json:"race"`
-	Sex         Sex      `json:"sex"`
-	AgeInMonth  int      `json:"ageInMonth"`
-	Description string   `json:"description"`
-	ImageUrls   []string `json:"imageUrls"`
-	HasMatched  bool     `json:"hasMatched"`
-	CreatedAt   string   `json:"createdAt"`
+	Id          string      `json:"id"`
+	Name        string      `json:"name"`
+	Race        Race        `json:"race"`
+	Sex         Sex         `json:"sex"`
+	AgeInMonth  int         `json:"ageInMonth"`
+	Description string      `json:"description"`
+	ImageUrls   []string    `json:"imageUrls"`
+	Images      []*CatImage `json:"images"`
+	HasMatched  bool        `json:"hasMatched"`
+	// the exact location of a cat is never shown, only its city and its
+	// distance to the point it was searched near
+	City       string   `json:"city"`
+	DistanceKm *float64 `json:"distanceKm,omitempty"`
+	CreatedAt  string   `json:"createdAt"`
+	// Highlights is only set when searching
+	Highlights *CatHighlights `json:"highlights,omitempty"`
+}
+
+// CatHighlights are HTML escaped snippets of the cat with the matched words
+// wrapped in <mark> tags.
+type CatHighlights struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// RecommendationWeights scale each factor of a recommendation score.
+type RecommendationWeights struct {
+	SameRace     float64
+	AgeProximity float64
+	Distance     float64
+	Recency      float64
+}
+
+type RecommendationQueryParams struct {
+	Limit   int
+	Weights RecommendationWeights
+	// Cursor continues after the recommendation it points at
+	Cursor *RecommendationCursor
+}
+
+// RecommendationCursor also pins the time recency is measured from, so scores
+// don't change between pages.
+type RecommendationCursor struct {
+	AsOf  time.Time
+	Score float64
+	Id    string
+}
+
+// RecommendationFactors are each between 0 and 1, Distance is 0 when either
+// cat has no location.
+type RecommendationFactors struct {
+	SameRace     float64 `json:"sameRace"`
+	AgeProximity float64 `json:"ageProximity"`
+	Distance     float64 `json:"distance"`
+	Recency      float64 `json:"recency"`
+}
+
+type Recommendation struct {
+	Cat     *GetCatResponse       `json:"cat"`
+	Score   float64               `json:"score"`
+	Factors RecommendationFactors `json:"factors"`
+}
+
+type RecommendationPage struct {
+	Recommendations []*Recommendation
+	NextCursor      string
+	HasMore         bool
+}
+
+// CatImage is one image of a cat. Width, height and the variants are only
+// known for uploaded images once they have been processed.
+type CatImage struct {
+	Id        string               `json:"id"`
+	Url       string               `json:"url"`
+	Caption   string               `json:"caption"`
+	IsPrimary bool                 `json:"isPrimary"`
+	Width     int                  `json:"width,omitempty"`
+	Height    int                  `json:"height,omitempty"`
+	Thumbnail *imageentity.Variant `json:"thumbnail,omitempty"`
+	Medium    *imageentity.Variant `json:"medium,omitempty"`
+}
+
+type UploadCatImagesResponse struct {
+	ImageUrls []string    `json:"imageUrls"`
+	Images    []*CatImage `json:"images"`
+}
+
+type UpdateCatImageRequest struct {
+	Caption *string `json:"caption" validate:"omitempty,max=200"`
+	// IsPrimary can only be set, the primary image changes by making another
+	// image primary.
+	IsPrimary *bool `json:"isPrimary" validate:"omitempty,eq=true"`
+}
+
+type ReorderCatImagesRequest struct {
+	ImageIds []string `json:"imageIds" validate:"required,min=1,unique,dive,required"`
 }
 
 type UpdateCatRequest struct {
@@ -81,4 +256,41 @@ type UpdateCatRequest struct {
 	AgeInMonth  int      `json:"ageInMonth" validate:"required,min=1,max=120082"`
 	Description string   `json:"description" validate:"required,min=1,max=200"`
 	ImageUrls   []string `json:"imageUrls" validate:"required,min=1,dive,required,http_url"`
+	// Location defaults to the owner's location
+	Location *locationentity.Location `json:"location"`
+}
+
+// PatchCatRequest follows JSON Merge Patch (RFC 7396): only the fields that are
+// present are updated. Location is the only optional field, null removes it,
+// removing any other field with null is rejected.
+type PatchCatRequest struct {
+	Name        *string                  `json:"name" validate:"omitempty,min=1,max=30"`
+	Race        *Race                    `json:"race" validate:"omitempty,oneof='Persian' 'Maine Coon' 'Siamese' 'Ragdoll' 'Bengal' 'Sphynx' 'British Shorthair' 'Abyssinian' 'Scottish Fold' 'Birman'"`
+	Sex         *Sex                     `json:"sex" validate:"omitempty,oneof='male' 'female'"`
+	AgeInMonth  *int                     `json:"ageInMonth" validate:"omitempty,min=1,max=120082"`
+	Description *string                  `json:"description" validate:"omitempty,min=1,max=200"`
+	ImageUrls   *[]string                `json:"imageUrls" validate:"omitempty,min=1,dive,required,http_url"`
+	Location    *locationentity.Location `json:"location"`
+	// ClearLocation is set when location is null
+	ClearLocation bool `json:"-"`
+}
+
+func (p *PatchCatRequest) UnmarshalJSON(data []byte) error {
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	for key, value := range fields {
+		if string(value) == "null" && key != "location" {
+			return caterror.ErrCatFieldIsRequired
+		}
+	}
+
+	type patchCatRequest PatchCatRequest
+	err := json.Unmarshal(data, (*patchCatRequest)(p))
+	if err != nil {
+		return err
+	}
+	p.ClearLocation = string(fields["location"]) == "null"
+	return nil
 }
@@ -0,0 +1,75 @@
+package evententity
+
+import "encoding/json"
+
+type Type string
+
+const (
+	CatCreated     Type = "cat.created"
+	CatUpdated     Type = "cat.updated"
+	CatDeleted     Type = "cat.deleted"
+	MatchRequested Type = "match.requested"
+	MatchApproved  Type = "match.approved"
+	MatchRejected  Type = "match.rejected"
+	MatchDeleted   Type = "match.deleted"
+	MatchUnmatched Type = "match.unmatched"
+	MatchExpired   Type = "match.expired"
+	UserRegistered Type = "user.registered"
+)
+
+type Status string
+
+const (
+	Pending    Status = "pending"
+	Processing Status = "processing"
+	Dispatched Status = "dispatched"
+	Failed     Status = "failed"
+)
+
+// Event is a domain event. It's written to the outbox in the same
+// transaction as the change it describes, so it's recorded if and only if
+// the change is.
+type Event struct {
+	Id string
+	// Type decides the shape of Payload
+	Type Type
+	// AggregateId is the id of the cat, match request or user that changed
+	AggregateId string
+	Payload     json.RawMessage
+	// HandledBy are the handlers that already handled the event, they are
+	// skipped when the event is dispatched again
+	HandledBy []string
+	Attempts  int
+	CreatedAt string
+}
+
+// CatPayload is the payload of the cat events. The deleted event only
+// carries the ids, the updated event of a patch only the patched fields.
+type CatPayload struct {
+	CatId       string `json:"catId"`
+	OwnerId     string `json:"ownerId"`
+	Name        string `json:"name,omitempty"`
+	Race        string `json:"race,omitempty"`
+	Sex         string `json:"sex,omitempty"`
+	AgeInMonth  int    `json:"ageInMonth,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// MatchPayload is the payload of the match events. The issuer owns the user
+// cat and the receiver owns the match cat.
+type MatchPayload struct {
+	MatchId    string `json:"matchId"`
+	MatchCatId string `json:"matchCatId"`
+	UserCatId  string `json:"userCatId"`
+	IssuerId   string `json:"issuerId"`
+	ReceiverId string `json:"receiverId"`
+	Status     string `json:"status"`
+	// ActorId is the user who made the change, empty for expired requests
+	ActorId string `json:"actorId,omitempty"`
+}
+
+type UserPayload struct {
+	UserId string `json:"userId"`
+	Name   string `json:"name"`
+	Email  string `json:"email"`
+}
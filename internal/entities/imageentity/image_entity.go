@@ -0,0 +1,34 @@
+package imageentity
+
+type Status string
+
+const (
+	Pending    Status = "pending"
+	Processing Status = "processing"
+	Ready      Status = "ready"
+	Failed     Status = "failed"
+)
+
+// VariantJob is an uploaded image whose variants still have to be generated.
+type VariantJob struct {
+	SourceUrl string
+	SourceKey string
+	CatId     string
+	Attempts  int
+}
+
+type Variant struct {
+	Url    string `json:"url"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+// Image describes one cat image. Width, height and the variants are only
+// known for uploaded images once the worker has processed them.
+type Image struct {
+	Url       string   `json:"url"`
+	Width     int      `json:"width,omitempty"`
+	Height    int      `json:"height,omitempty"`
+	Thumbnail *Variant `json:"thumbnail,omitempty"`
+	Medium    *Variant `json:"medium,omitempty"`
+}
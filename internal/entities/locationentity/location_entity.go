@@ -0,0 +1,9 @@
+package locationentity
+
+// Location is where a user or a cat lives. Latitude and longitude are pointers
+// so the equator and the prime meridian pass the required check.
+type Location struct {
+	Latitude  *float64 `json:"latitude" validate:"required,min=-90,max=90"`
+	Longitude *float64 `json:"longitude" validate:"required,min=-180,max=180"`
+	City      string   `json:"city" validate:"max=100"`
+}
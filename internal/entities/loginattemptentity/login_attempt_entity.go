@@ -0,0 +1,9 @@
+package loginattemptentity
+
+import "time"
+
+type LoginAttempt struct {
+	Key       string
+	Failures  int
+	LockedFor time.Duration
+}
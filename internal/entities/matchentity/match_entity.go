@@ -1,13 +1,27 @@
 package matchentity
 
-import "github.com/danzBraham/cats-social/internal/entities/catentity"
+import (
+	"time"
+
+	"github.com/danzBraham/cats-social/internal/entities/catentity"
+)
 
 type Status string
 
 const (
-	Pending  Status = "pending"
-	Approved Status = "approved"
-	Rejected Status = "rejected"
+	Pending   Status = "pending"
+	Approved  Status = "approved"
+	Rejected  Status = "rejected"
+	Expired   Status = "expired"
+	Unmatched Status = "unmatched"
+)
+
+// Direction tells whether a match request was sent to or by the user's cat.
+type Direction string
+
+const (
+	Incoming Direction = "incoming"
+	Outgoing Direction = "outgoing"
 )
 
 type Match struct {
@@ -21,6 +35,17 @@ type Match struct {
 	UpdatedAt  string
 }
 
+// MatchParties are the cats of a match and their owners. The issuer owns the
+// user cat and the receiver owns the match cat.
+type MatchParties struct {
+	Id         string
+	MatchCatId string
+	UserCatId  string
+	IssuerId   string
+	ReceiverId string
+	Status     Status
+}
+
 type CreateMatchRequest struct {
 	MatchCatId string `json:"matchCatId" validate:"required,len=26"`
 	UserCatId  string `json:"userCatId" validate:"required,len=26"`
@@ -33,15 +58,54 @@ type IssuerDetail struct {
 	CreatedAt string `json:"createdAt"`
 }
 
+type ReceiverDetail struct {
+	Name      string `json:"name"`
+	Email     string `json:"email"`
+	CreatedAt string `json:"createdAt"`
+}
+
+// UnmatchDetail tells who ended an approved match and when.
+type UnmatchDetail struct {
+	Name        string `json:"name"`
+	Email       string `json:"email"`
+	UnmatchedAt string `json:"unmatchedAt"`
+}
+
 type GetMatchResponse struct {
 	Id             string                   `json:"id"`
+	Status         Status                   `json:"status"`
+	Direction      Direction                `json:"direction"`
 	IssuedBy       IssuerDetail             `json:"issuedBy"`
+	ReceivedBy     ReceiverDetail           `json:"receivedBy"`
 	MatchCatDetail catentity.GetCatResponse `json:"matchCatDetail"`
 	UserCatDetail  catentity.GetCatResponse `json:"userCatDetail"`
 	Message        string                   `json:"message"`
+	UnmatchedBy    *UnmatchDetail           `json:"unmatchedBy,omitempty"`
 	CreatedAt      string                   `json:"createdAt"`
 }
 
+type MatchQueryParams struct {
+	Limit     int
+	Statuses  []Status
+	Direction Direction
+	// CatId keeps the requests either cat of which is this cat
+	CatId string
+	// Cursor continues after the request it points at
+	Cursor *MatchCursor
+}
+
+type MatchCursor struct {
+	CreatedAt time.Time
+	Id        string
+}
+
+// MatchPage is one page of GET /v1/cat/match, newest first.
+type MatchPage struct {
+	Matches    []*GetMatchResponse
+	NextCursor string
+	HasMore    bool
+}
+
 type ApproveMatchRequest struct {
 	MatchId string `json:"matchId" validate:"required,len=26"`
 }
@@ -49,3 +113,7 @@ type ApproveMatchRequest struct {
 type RejectMatchRequest struct {
 	MatchId string `json:"matchId" validate:"required,len=26"`
 }
+
+type UnmatchRequest struct {
+	MatchId string `json:"matchId" validate:"required,len=26"`
+}
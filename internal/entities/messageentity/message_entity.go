@@ -0,0 +1,50 @@
+package messageentity
+
+import "time"
+
+// Message is sent in the thread of a match request, between the owners of
+// its two cats.
+type Message struct {
+	Id       string
+	MatchId  string
+	SenderId string
+	Body     string
+}
+
+type CreateMessageRequest struct {
+	Body string `json:"body" validate:"required,max=1000"`
+}
+
+type SenderDetail struct {
+	Name string `json:"name"`
+}
+
+type GetMessageResponse struct {
+	Id     string       `json:"id"`
+	Sender SenderDetail `json:"sender"`
+	IsMine bool         `json:"isMine"`
+	Body   string       `json:"body"`
+	// ReadAt stays null until the other owner has opened the thread
+	ReadAt    *string `json:"readAt"`
+	CreatedAt string  `json:"createdAt"`
+}
+
+type MessageQueryParams struct {
+	Limit  int
+	Cursor *MessageCursor
+	// MarkAsRead is unset for API keys without the matches:write scope,
+	// reading the thread with those doesn't send read receipts.
+	MarkAsRead bool
+}
+
+type MessageCursor struct {
+	CreatedAt time.Time
+	Id        string
+}
+
+// MessagePage is one page of a thread, newest first.
+type MessagePage struct {
+	Messages   []*GetMessageResponse
+	NextCursor string
+	HasMore    bool
+}
@@ -0,0 +1,88 @@
+package notificationentity
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Channel is the Postgres channel every new notification is sent on, so the
+// replicas can push it to the streams of its user.
+const Channel = "notifications"
+
+type Type string
+
+const (
+	MatchRequested  Type = "match.requested"
+	MatchApproved   Type = "match.approved"
+	MatchRejected   Type = "match.rejected"
+	MatchWithdrawn  Type = "match.withdrawn"
+	MatchUnmatched  Type = "match.unmatched"
+	MatchRemoved    Type = "match.removed"
+	MessageReceived Type = "message.received"
+)
+
+type Notification struct {
+	Id     string
+	UserId string
+	Type   Type
+	Data   json.RawMessage
+}
+
+// MatchData is the data of the match.* notifications.
+type MatchData struct {
+	MatchId    string `json:"matchId"`
+	MatchCatId string `json:"matchCatId,omitempty"`
+	UserCatId  string `json:"userCatId,omitempty"`
+}
+
+// MessageData is the data of the message.received notification.
+type MessageData struct {
+	MatchId   string `json:"matchId"`
+	MessageId string `json:"messageId"`
+}
+
+type GetNotificationResponse struct {
+	Id        string          `json:"id"`
+	Type      Type            `json:"type"`
+	Data      json.RawMessage `json:"data"`
+	ReadAt    *string         `json:"readAt"`
+	CreatedAt string          `json:"createdAt"`
+}
+
+// Event is the payload sent on Channel.
+type Event struct {
+	UserId       string                   `json:"userId"`
+	Notification *GetNotificationResponse `json:"notification"`
+}
+
+type NotificationQueryParams struct {
+	Limit  int
+	Unread bool
+	Cursor *NotificationCursor
+}
+
+type NotificationCursor struct {
+	CreatedAt time.Time
+	Id        string
+}
+
+// NotificationPage is one page of the inbox, newest first.
+type NotificationPage struct {
+	Notifications []*GetNotificationResponse
+	NextCursor    string
+	HasMore       bool
+}
+
+// StreamTicket stands in for the access token of a session when a browser
+// opens a notification stream.
+type StreamTicket struct {
+	Id        string
+	UserId    string
+	SessionId string
+	TokenHash string
+}
+
+type CreateStreamTicketResponse struct {
+	Ticket    string `json:"ticket"`
+	ExpiresAt string `json:"expiresAt"`
+}
@@ -0,0 +1,20 @@
+package sessionentity
+
+type Session struct {
+	Id               string
+	UserId           string
+	RefreshTokenHash string
+	ExpiresAt        string
+	RevokedAt        string
+	CreatedAt        string
+	UpdatedAt        string
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refreshToken" validate:"required"`
+}
+
+type RefreshTokenResponse struct {
+	AccessToken  string `json:"accessToken"`
+	RefreshToken string `json:"refreshToken"`
+}
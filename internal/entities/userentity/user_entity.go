@@ -1,12 +1,32 @@
 package userentity
 
+import (
+	"encoding/json"
+
+	"github.com/danzBraham/cats-social/internal/entities/locationentity"
+)
+
+type Role string
+
+const (
+	RoleUser      Role = "user"
+	RoleModerator Role = "moderator"
+	RoleAdmin     Role = "admin"
+)
+
 type User struct {
-	Id        string
-	Name      string
-	Email     string
-	Password  string
-	CreatedAt string
-	UpdatedAt string
+	Id              string
+	Name            string
+	Email           string
+	Password        string
+	Role            Role
+	EmailVerifiedAt string
+	SuspendedAt     string
+	TOTPSecret      string
+	TOTPEnabledAt   string
+	Location        *locationentity.Location
+	CreatedAt       string
+	UpdatedAt       string
 }
 
 type RegisterUserRequest struct {
@@ -16,9 +36,10 @@ type RegisterUserRequest struct {
 }
 
 type RegisterUserResponse struct {
-	Name        string `json:"name"`
-	Email       string `json:"email"`
-	AccessToken string `json:"accessToken"`
+	Name         string `json:"name"`
+	Email        string `json:"email"`
+	AccessToken  string `json:"accessToken"`
+	RefreshToken string `json:"refreshToken"`
 }
 
 type LoginUserRequest struct {
@@ -27,7 +48,121 @@ type LoginUserRequest struct {
 }
 
 type LoginUserResponse struct {
-	Name        string `json:"name"`
-	Email       string `json:"email"`
-	AccessToken string `json:"accessToken"`
+	Name              string `json:"name"`
+	Email             string `json:"email"`
+	AccessToken       string `json:"accessToken,omitempty"`
+	RefreshToken      string `json:"refreshToken,omitempty"`
+	TwoFactorRequired bool   `json:"twoFactorRequired,omitempty"`
+	ChallengeToken    string `json:"challengeToken,omitempty"`
+}
+
+type LoginTwoFactorRequest struct {
+	ChallengeToken string `json:"challengeToken" validate:"required"`
+	Code           string `json:"code" validate:"required,min=6,max=20"`
+}
+
+type GetUserResponse struct {
+	Id               string `json:"id"`
+	Name             string `json:"name"`
+	Email            string `json:"email"`
+	EmailVerified    bool   `json:"emailVerified"`
+	TwoFactorEnabled bool   `json:"twoFactorEnabled"`
+	// Location is where new cats of the user are placed by default
+	Location  *locationentity.Location `json:"location"`
+	CreatedAt string                   `json:"createdAt"`
+}
+
+type UpdateUserRequest struct {
+	Name     *string                  `json:"name" validate:"omitempty,min=5,max=50"`
+	Email    *string                  `json:"email" validate:"omitempty,email"`
+	Location *locationentity.Location `json:"location"`
+	// ClearLocation is set when location is null
+	ClearLocation bool `json:"-"`
+}
+
+func (p *UpdateUserRequest) UnmarshalJSON(data []byte) error {
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+
+	type updateUserRequest UpdateUserRequest
+	err := json.Unmarshal(data, (*updateUserRequest)(p))
+	if err != nil {
+		return err
+	}
+	p.ClearLocation = string(fields["location"]) == "null"
+	return nil
+}
+
+// UserAccess is what the auth middleware needs to know about the user behind
+// a token.
+type UserAccess struct {
+	Role        Role
+	IsSuspended bool
+}
+
+type PasswordReset struct {
+	Id        string
+	UserId    string
+	TokenHash string
+	ExpiresAt string
+	UsedAt    string
+	CreatedAt string
+}
+
+// LoginChallenge is the second step of a login with 2FA, the token is only
+// known to the client and stored hashed.
+type LoginChallenge struct {
+	Id        string
+	UserId    string
+	TokenHash string
+}
+
+type ChangePasswordRequest struct {
+	OldPassword string `json:"oldPassword" validate:"required,min=5,max=15"`
+	NewPassword string `json:"newPassword" validate:"required,min=5,max=15"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token       string `json:"token" validate:"required"`
+	NewPassword string `json:"newPassword" validate:"required,min=5,max=15"`
+}
+
+type UserQueryParams struct {
+	Limit  int
+	Offset int
+	Search string
+}
+
+type AdminUserResponse struct {
+	Id            string `json:"id"`
+	Name          string `json:"name"`
+	Email         string `json:"email"`
+	Role          Role   `json:"role"`
+	EmailVerified bool   `json:"emailVerified"`
+	Suspended     bool   `json:"suspended"`
+	CreatedAt     string `json:"createdAt"`
+}
+
+type SetupTwoFactorResponse struct {
+	Secret          string `json:"secret"`
+	ProvisioningUri string `json:"provisioningUri"`
+}
+
+type EnableTwoFactorRequest struct {
+	Code string `json:"code" validate:"required,len=6,numeric"`
+}
+
+type EnableTwoFactorResponse struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+type DisableTwoFactorRequest struct {
+	Password string `json:"password" validate:"required,min=5,max=15"`
+	Code     string `json:"code" validate:"required,min=6,max=20"`
 }
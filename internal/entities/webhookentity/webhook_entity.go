@@ -0,0 +1,131 @@
+package webhookentity
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type EventType string
+
+const (
+	CatCreated     EventType = "cat.created"
+	CatDeleted     EventType = "cat.deleted"
+	MatchRequested EventType = "match.requested"
+	MatchApproved  EventType = "match.approved"
+	MatchRejected  EventType = "match.rejected"
+	MatchDeleted   EventType = "match.deleted"
+	MatchUnmatched EventType = "match.unmatched"
+	MatchExpired   EventType = "match.expired"
+)
+
+type DeliveryStatus string
+
+const (
+	Pending    DeliveryStatus = "pending"
+	Processing DeliveryStatus = "processing"
+	Delivered  DeliveryStatus = "delivered"
+	Failed     DeliveryStatus = "failed"
+)
+
+type Webhook struct {
+	Id         string
+	UserId     string
+	Url        string
+	Secret     string
+	EventTypes []EventType
+	IsGlobal   bool
+}
+
+type CreateWebhookRequest struct {
+	Url string `json:"url" validate:"required,url,max=2048,startswith=http"`
+	// EventTypes left empty subscribes to every event
+	EventTypes []EventType `json:"eventTypes" validate:"unique,dive,oneof='cat.created' 'cat.deleted' 'match.requested' 'match.approved' 'match.rejected' 'match.deleted' 'match.unmatched' 'match.expired'"`
+	// Global webhooks get the events of every user, only admins can create them
+	Global bool `json:"global"`
+}
+
+// CreateWebhookResponse is the only response that shows the signing secret.
+type CreateWebhookResponse struct {
+	Id         string      `json:"id"`
+	Url        string      `json:"url"`
+	EventTypes []EventType `json:"eventTypes"`
+	Global     bool        `json:"global"`
+	Secret     string      `json:"secret"`
+	CreatedAt  string      `json:"createdAt"`
+}
+
+type GetWebhookResponse struct {
+	Id         string      `json:"id"`
+	Url        string      `json:"url"`
+	EventTypes []EventType `json:"eventTypes"`
+	Global     bool        `json:"global"`
+	CreatedAt  string      `json:"createdAt"`
+}
+
+// Event is the body posted to the webhooks.
+type Event struct {
+	Id        string          `json:"id"`
+	Type      EventType       `json:"type"`
+	CreatedAt string          `json:"createdAt"`
+	Data      json.RawMessage `json:"data"`
+}
+
+type CatData struct {
+	CatId      string `json:"catId"`
+	OwnerId    string `json:"ownerId"`
+	Name       string `json:"name,omitempty"`
+	Race       string `json:"race,omitempty"`
+	Sex        string `json:"sex,omitempty"`
+	AgeInMonth int    `json:"ageInMonth,omitempty"`
+}
+
+type MatchData struct {
+	MatchId    string `json:"matchId"`
+	MatchCatId string `json:"matchCatId"`
+	UserCatId  string `json:"userCatId"`
+	Status     string `json:"status"`
+}
+
+// Delivery is an event queued for a webhook. The rows are kept afterwards as
+// the delivery log.
+type Delivery struct {
+	Id        string
+	WebhookId string
+	Url       string
+	Secret    string
+	EventId   string
+	EventType EventType
+	Payload   json.RawMessage
+	Attempts  int
+}
+
+type GetDeliveryResponse struct {
+	Id             string          `json:"id"`
+	EventId        string          `json:"eventId"`
+	EventType      EventType       `json:"eventType"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         DeliveryStatus  `json:"status"`
+	Attempts       int             `json:"attempts"`
+	ResponseStatus *int            `json:"responseStatus"`
+	Error          *string         `json:"error"`
+	NextAttemptAt  *string         `json:"nextAttemptAt"`
+	DeliveredAt    *string         `json:"deliveredAt"`
+	CreatedAt      string          `json:"createdAt"`
+}
+
+type DeliveryQueryParams struct {
+	Limit  int
+	Cursor *DeliveryCursor
+}
+
+type DeliveryCursor struct {
+	CreatedAt time.Time
+	Id        string
+}
+
+// DeliveryPage is one page of the delivery log of a webhook, newest first.
+type DeliveryPage struct {
+	Deliveries []*GetDeliveryResponse
+	NextCursor string
+	HasMore    bool
+}
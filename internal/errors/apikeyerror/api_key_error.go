@@ -0,0 +1,7 @@
+package apikeyerror
+
+import "errors"
+
+var (
+	ErrApiKeyIdNotFound = errors.New("api key id not found")
+)
@@ -3,9 +3,16 @@ package autherror
 import "errors"
 
 var (
-	ErrMissingAuthHeader          = errors.New("missing Authorization header")
-	ErrInvalidAuthHeader          = errors.New("invalid Authorization header")
-	ErrInvalidToken               = errors.New("invalid token")
-	ErrUnknownClaims              = errors.New("unknown claims type")
-	ErrUserIdNotFoundInTheContext = errors.New("user id not found in the context")
+	ErrMissingAuthHeader             = errors.New("missing Authorization header")
+	ErrInvalidAuthHeader             = errors.New("invalid Authorization header")
+	ErrInvalidToken                  = errors.New("invalid token")
+	ErrUnknownClaims                 = errors.New("unknown claims type")
+	ErrUserIdNotFoundInTheContext    = errors.New("user id not found in the context")
+	ErrSessionIdNotFoundInTheContext = errors.New("session id not found in the context")
+	ErrSessionRevoked                = errors.New("session has been revoked or expired")
+	ErrInvalidRefreshToken           = errors.New("invalid refresh token")
+	ErrForbidden                     = errors.New("you don't have permission to access this resource")
+	ErrInvalidApiKey                 = errors.New("invalid api key")
+	ErrInsufficientScope             = errors.New("api key is missing the required scope")
+	ErrApiKeyNotAllowed              = errors.New("this endpoint can't be used with an api key")
 )
@@ -3,8 +3,16 @@ package caterror
 import "errors"
 
 var (
-	ErrCatIdNotFound = errors.New("cat id not found")
-	ErrCatNotFound   = errors.New("cat not found")
-	ErrNotCatOwner   = errors.New("you're not the cat owner")
-	ErrSexIsEdited   = errors.New("sex cannot be changed when cat is already requested for a match")
+	ErrCatIdNotFound        = errors.New("cat id not found")
+	ErrCatNotFound          = errors.New("cat not found")
+	ErrNotCatOwner          = errors.New("you're not the cat owner")
+	ErrSexIsEdited          = errors.New("sex cannot be changed when cat is already requested for a match")
+	ErrNoImagesUploaded     = errors.New("at least one image is required")
+	ErrTooManyImages        = errors.New("too many images in one upload")
+	ErrImageTooLarge        = errors.New("image is too large")
+	ErrUnsupportedImageType = errors.New("image must be a jpeg, png, gif or webp")
+	ErrCatImageIdNotFound   = errors.New("cat image id not found")
+	ErrInvalidImageOrder    = errors.New("image ids must list every image of the cat exactly once")
+	ErrCatFieldIsRequired   = errors.New("cat fields cannot be removed, omit the field to keep its value")
+	ErrInvalidCatQuery      = errors.New("invalid cat query")
 )
@@ -16,4 +16,8 @@ var (
 	ErrBothCatsHaveAlreadyMatched  = errors.New("both cats have already matched")
 	ErrBothCatsHaveSameOwner       = errors.New("both cats have same owner")
 	ErrMatchRequestAlreadyExists   = errors.New("match request for these two cats already exists")
+	ErrInvalidMatchQuery           = errors.New("invalid match query")
+	ErrMatchRequestExpired         = errors.New("match request has expired")
+	ErrMatchIsNotApproved          = errors.New("match request is not approved")
+	ErrNotMatchParticipant         = errors.New("you don't own either cat of the match")
 )
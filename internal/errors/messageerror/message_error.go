@@ -0,0 +1,9 @@
+package messageerror
+
+import "errors"
+
+var (
+	ErrMessageIsEmpty      = errors.New("message is empty")
+	ErrThreadIsReadOnly    = errors.New("the match request thread is read-only")
+	ErrInvalidMessageQuery = errors.New("invalid message query")
+)
@@ -0,0 +1,11 @@
+package notificationerror
+
+import "errors"
+
+var (
+	ErrNotificationIdNotFound   = errors.New("notification id not found")
+	ErrInvalidNotificationQuery = errors.New("invalid notification query")
+	ErrStreamingUnsupported     = errors.New("streaming is not supported")
+	ErrInvalidStreamTicket      = errors.New("invalid or expired stream ticket")
+	ErrOriginNotAllowed         = errors.New("origin is not allowed")
+)
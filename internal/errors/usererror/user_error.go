@@ -1,9 +1,40 @@
 package usererror
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"time"
+)
 
 var (
-	ErrEmailAlreadyExists = errors.New("email already exists")
-	ErrUserNotFound       = errors.New("user not found")
-	ErrInvalidPassword    = errors.New("invalid password")
+	ErrEmailAlreadyExists       = errors.New("email already exists")
+	ErrUserNotFound             = errors.New("user not found")
+	ErrInvalidPassword          = errors.New("invalid password")
+	ErrInvalidCredentials       = errors.New("invalid email or password")
+	ErrTooManyLoginAttempts     = errors.New("too many failed login attempts")
+	ErrInvalidResetToken        = errors.New("invalid or expired password reset token")
+	ErrInvalidVerificationToken = errors.New("invalid or expired email verification token")
+	ErrEmailNotVerified         = errors.New("email is not verified")
+	ErrEmailAlreadyVerified     = errors.New("email is already verified")
+	ErrUserSuspended            = errors.New("user is suspended")
+	ErrCannotSuspendYourself    = errors.New("you can't suspend yourself")
+	ErrTwoFactorAlreadyEnabled  = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorNotEnabled      = errors.New("two-factor authentication is not enabled")
+	ErrTwoFactorNotSetUp        = errors.New("two-factor authentication has not been set up")
+	ErrInvalidTwoFactorCode     = errors.New("invalid two-factor authentication code")
+	ErrInvalidChallengeToken    = errors.New("invalid or expired login challenge token")
 )
+
+// LoginLockedError is returned while an account or client is locked out
+// after too many failed logins. It matches ErrTooManyLoginAttempts.
+type LoginLockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LoginLockedError) Error() string {
+	return fmt.Sprintf("%s, try again in %s", ErrTooManyLoginAttempts, e.RetryAfter.Round(time.Second))
+}
+
+func (e *LoginLockedError) Unwrap() error {
+	return ErrTooManyLoginAttempts
+}
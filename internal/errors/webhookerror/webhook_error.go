@@ -0,0 +1,11 @@
+package webhookerror
+
+import "errors"
+
+var (
+	ErrWebhookIdNotFound      = errors.New("webhook id not found")
+	ErrDeliveryIdNotFound     = errors.New("webhook delivery id not found")
+	ErrGlobalWebhookForbidden = errors.New("only admins can create global webhooks")
+	ErrInvalidWebhookQuery    = errors.New("invalid webhook query")
+	ErrBlockedAddress         = errors.New("webhook url resolves to a blocked address")
+)
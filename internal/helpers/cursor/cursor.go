@@ -0,0 +1,40 @@
+package cursor
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Encode builds an opaque cursor from the sort values of the row a page
+// continues after.
+func Encode(values ...interface{}) string {
+	raw, _ := json.Marshal(values)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// Decode reads a cursor built by Encode into values, which must be pointers
+// matching the encoded values in number and type.
+func Decode(cursor string, values ...interface{}) error {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return ErrInvalidCursor
+	}
+
+	fields := []json.RawMessage{}
+	err = json.Unmarshal(raw, &fields)
+	if err != nil || len(fields) != len(values) {
+		return ErrInvalidCursor
+	}
+
+	for i, field := range fields {
+		err = json.Unmarshal(field, values[i])
+		if err != nil {
+			return ErrInvalidCursor
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,58 @@
+package cursor
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRoundTrip(t *testing.T) {
+	createdAt := time.Date(2024, 7, 5, 10, 30, 0, 123456789, time.UTC)
+	cursor := Encode(createdAt, "01J1Z2", 42)
+
+	var (
+		decodedCreatedAt time.Time
+		decodedId        string
+		decodedRank      int
+	)
+	if err := Decode(cursor, &decodedCreatedAt, &decodedId, &decodedRank); err != nil {
+		t.Fatal(err)
+	}
+
+	if !decodedCreatedAt.Equal(createdAt) {
+		t.Errorf("createdAt = %v, want %v", decodedCreatedAt, createdAt)
+	}
+	if decodedId != "01J1Z2" {
+		t.Errorf("id = %q, want %q", decodedId, "01J1Z2")
+	}
+	if decodedRank != 42 {
+		t.Errorf("rank = %d, want 42", decodedRank)
+	}
+}
+
+func TestDecodeRejectsInvalidCursors(t *testing.T) {
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{"not base64", "not a cursor!"},
+		{"not json", "bm90IGpzb24"},
+		{"not an array", "eyJpZCI6IjAxSjFaMiJ9"},
+		{"too few values", Encode(time.Now())},
+		{"too many values", Encode(time.Now(), "01J1Z2", "extra")},
+		{"wrong type", Encode("01J1Z2", time.Now())},
+		{"number for a string", Encode(time.Now(), 42)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				createdAt time.Time
+				id        string
+			)
+			if err := Decode(tt.cursor, &createdAt, &id); !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("Decode() error = %v, want %v", err, ErrInvalidCursor)
+			}
+		})
+	}
+}
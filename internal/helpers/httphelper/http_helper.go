@@ -2,6 +2,7 @@ package httphelper
 
 import (
 	"encoding/json"
+	"net"
 	"net/http"
 
 	"github.com/danzBraham/cats-social/internal/helpers/validator"
@@ -11,6 +12,14 @@ type ResponseBody struct {
 	Error   string      `json:"error,omitempty"`
 	Message string      `json:"message"`
 	Data    interface{} `json:"data,omitempty"`
+	Meta    *Meta       `json:"meta,omitempty"`
+}
+
+// Meta describes the page returned by list endpoints.
+type Meta struct {
+	NextCursor string `json:"nextCursor,omitempty"`
+	HasMore    bool   `json:"hasMore"`
+	Total      *int   `json:"total,omitempty"`
 }
 
 func DecodeJSON(r *http.Request, payload interface{}) error {
@@ -37,6 +46,14 @@ func SuccessResponse(w http.ResponseWriter, status int, message string, data int
 	})
 }
 
+func SuccessResponseWithMeta(w http.ResponseWriter, status int, message string, data interface{}, meta *Meta) {
+	EncodeJSON(w, status, ResponseBody{
+		Message: message,
+		Data:    data,
+		Meta:    meta,
+	})
+}
+
 func DecodeAndValidate(w http.ResponseWriter, r *http.Request, payload interface{}) error {
 	err := DecodeJSON(r, payload)
 	if err != nil {
@@ -55,3 +72,13 @@ func DecodeAndValidate(w http.ResponseWriter, r *http.Request, payload interface
 
 	return nil
 }
+
+// ClientIP returns the IP address of the client. RemoteAddr already holds the
+// forwarded address when the server runs with TRUST_PROXY enabled.
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
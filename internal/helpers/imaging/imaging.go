@@ -0,0 +1,115 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+
+	_ "image/gif"
+)
+
+// MaxPixels guards against decompression bombs, small files that decode into
+// huge images.
+const MaxPixels = 50_000_000
+
+var ErrImageTooLarge = errors.New("image has too many pixels")
+
+// Decode decodes a JPEG, PNG or GIF (first frame) and applies the EXIF
+// orientation of JPEGs, so the result is the upright image.
+func Decode(data []byte) (*image.RGBA, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if config.Width*config.Height > MaxPixels {
+		return nil, ErrImageTooLarge
+	}
+
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	rgba := toRGBA(img)
+	if format == "jpeg" {
+		rgba = orient(rgba, exifOrientation(data))
+	}
+	return rgba, nil
+}
+
+// Fit scales the image down so it fits in a maxWidth x maxHeight box while
+// keeping its aspect ratio. Images that already fit are returned as is.
+func Fit(img *image.RGBA, maxWidth, maxHeight int) *image.RGBA {
+	width, height := img.Bounds().Dx(), img.Bounds().Dy()
+	if width <= maxWidth && height <= maxHeight {
+		return img
+	}
+
+	if width*maxHeight > height*maxWidth {
+		height = max(1, height*maxWidth/width)
+		width = maxWidth
+	} else {
+		width = max(1, width*maxHeight/height)
+		height = maxHeight
+	}
+	return resize(img, width, height)
+}
+
+// Encode encodes opaque images as JPEG and images with transparency as PNG.
+// It returns the encoded bytes, their content type and file extension.
+func Encode(img *image.RGBA) ([]byte, string, string, error) {
+	buf := &bytes.Buffer{}
+	if img.Opaque() {
+		err := jpeg.Encode(buf, img, &jpeg.Options{Quality: 82})
+		return buf.Bytes(), "image/jpeg", ".jpg", err
+	}
+	err := png.Encode(buf, img)
+	return buf.Bytes(), "image/png", ".png", err
+}
+
+func toRGBA(img image.Image) *image.RGBA {
+	bounds := img.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), img, bounds.Min, draw.Src)
+	return rgba
+}
+
+// resize downsamples with a box filter, every destination pixel is the
+// average of the source pixels it covers. Pixels are premultiplied so
+// transparent areas don't bleed into their neighbours.
+func resize(src *image.RGBA, width, height int) *image.RGBA {
+	srcWidth, srcHeight := src.Bounds().Dx(), src.Bounds().Dy()
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	for y := 0; y < height; y++ {
+		y0 := y * srcHeight / height
+		y1 := max(y0+1, (y+1)*srcHeight/height)
+		for x := 0; x < width; x++ {
+			x0 := x * srcWidth / width
+			x1 := max(x0+1, (x+1)*srcWidth/width)
+
+			var r, g, b, a, n uint32
+			for sy := y0; sy < y1; sy++ {
+				i := src.PixOffset(x0, sy)
+				for sx := x0; sx < x1; sx++ {
+					r += uint32(src.Pix[i])
+					g += uint32(src.Pix[i+1])
+					b += uint32(src.Pix[i+2])
+					a += uint32(src.Pix[i+3])
+					i += 4
+					n++
+				}
+			}
+
+			j := dst.PixOffset(x, y)
+			dst.Pix[j] = uint8(r / n)
+			dst.Pix[j+1] = uint8(g / n)
+			dst.Pix[j+2] = uint8(b / n)
+			dst.Pix[j+3] = uint8(a / n)
+		}
+	}
+	return dst
+}
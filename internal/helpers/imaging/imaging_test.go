@@ -0,0 +1,143 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func encodeJPEG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	err := jpeg.Encode(buf, image.NewRGBA(image.Rect(0, 0, width, height)), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// withExif puts an APP1 segment with the orientation right after the start
+// of image marker of a JPEG.
+func withExif(data []byte, orientation uint16) []byte {
+	app1 := exifJPEG(tiffWithOrientation(binary.BigEndian, orientation))
+	app1 = app1[2 : len(app1)-2]
+	return append(append(append([]byte{}, data[:2]...), app1...), data[2:]...)
+}
+
+// pngHeader returns a PNG that claims the given size. Decoding its config
+// only reads the header, so the missing pixel data is never noticed.
+func pngHeader(t *testing.T, width, height uint32) []byte {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	if err := png.Encode(buf, image.NewRGBA(image.Rect(0, 0, 1, 1))); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	// the IHDR chunk follows the 8 byte signature, its data starts after
+	// the length and the type
+	ihdr := data[8+8 : 8+8+13]
+	binary.BigEndian.PutUint32(ihdr[0:], width)
+	binary.BigEndian.PutUint32(ihdr[4:], height)
+	binary.BigEndian.PutUint32(data[8+8+13:], crc32.ChecksumIEEE(data[8+4:8+8+13]))
+	return data
+}
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name       string
+		data       []byte
+		wantWidth  int
+		wantHeight int
+		wantErr    error
+	}{
+		{"jpeg", encodeJPEG(t, 4, 2), 4, 2, nil},
+		{"jpeg rotated by exif", withExif(encodeJPEG(t, 4, 2), 6), 2, 4, nil},
+		{"jpeg with upright exif", withExif(encodeJPEG(t, 4, 2), 1), 4, 2, nil},
+		{"png at the pixel limit", pngHeader(t, MaxPixels/10, 10), 0, 0, nil},
+		{"png over the pixel limit", pngHeader(t, MaxPixels/10, 11), 0, 0, ErrImageTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img, err := Decode(tt.data)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Decode() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if tt.wantWidth == 0 {
+				// within the limit, so it got past the check and failed on
+				// the missing pixel data instead
+				if errors.Is(err, ErrImageTooLarge) {
+					t.Fatalf("Decode() error = %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if img.Bounds().Dx() != tt.wantWidth || img.Bounds().Dy() != tt.wantHeight {
+				t.Errorf("Decode() size = %dx%d, want %dx%d", img.Bounds().Dx(), img.Bounds().Dy(), tt.wantWidth, tt.wantHeight)
+			}
+		})
+	}
+}
+
+func TestDecodeRejectsUnknownFormats(t *testing.T) {
+	if _, err := Decode([]byte("not an image")); err == nil {
+		t.Error("Decode() error = nil, want an error")
+	}
+}
+
+func TestFit(t *testing.T) {
+	tests := []struct {
+		name                string
+		width, height       int
+		maxWidth, maxHeight int
+		wantWidth           int
+		wantHeight          int
+	}{
+		{"already fits", 100, 50, 200, 200, 100, 50},
+		{"exact fit", 200, 200, 200, 200, 200, 200},
+		{"landscape", 400, 200, 200, 200, 200, 100},
+		{"portrait", 200, 400, 200, 200, 100, 200},
+		{"wide box", 400, 400, 300, 100, 100, 100},
+		{"thin landscape", 3000, 2, 100, 100, 100, 1},
+		{"thin portrait", 2, 3000, 100, 100, 1, 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := image.NewRGBA(image.Rect(0, 0, tt.width, tt.height))
+			got := Fit(src, tt.maxWidth, tt.maxHeight)
+			if got.Bounds().Dx() != tt.wantWidth || got.Bounds().Dy() != tt.wantHeight {
+				t.Errorf("Fit() size = %dx%d, want %dx%d", got.Bounds().Dx(), got.Bounds().Dy(), tt.wantWidth, tt.wantHeight)
+			}
+			if tt.width <= tt.maxWidth && tt.height <= tt.maxHeight && got != src {
+				t.Error("Fit() copied an image that already fits")
+			}
+		})
+	}
+}
+
+func TestResizeAveragesPixels(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	src.Set(0, 0, color.RGBA{200, 0, 0, 255})
+	src.Set(1, 0, color.RGBA{0, 200, 0, 255})
+	src.Set(0, 1, color.RGBA{0, 0, 200, 255})
+	src.Set(1, 1, color.RGBA{0, 0, 0, 255})
+
+	got := resize(src, 1, 1).RGBAAt(0, 0)
+	want := color.RGBA{50, 50, 50, 255}
+	if got != want {
+		t.Errorf("resize() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,144 @@
+package imaging
+
+import (
+	"encoding/binary"
+	"image"
+	"testing"
+)
+
+// exifJPEG builds the start of a JPEG holding only an APP1 segment with the
+// given TIFF block, which is all exifOrientation reads.
+func exifJPEG(tiff []byte) []byte {
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+	data := []byte{0xFF, 0xD8, 0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(data[4:], uint16(len(segment)+2))
+	data = append(data, segment...)
+	return append(data, 0xFF, 0xD9)
+}
+
+// tiffWithOrientation builds a TIFF block whose first IFD has a single
+// orientation entry.
+func tiffWithOrientation(order binary.ByteOrder, orientation uint16) []byte {
+	tiff := make([]byte, 8+2+12+4)
+	if order == binary.LittleEndian {
+		copy(tiff, "II")
+	} else {
+		copy(tiff, "MM")
+	}
+	order.PutUint16(tiff[2:], 42)
+	order.PutUint32(tiff[4:], 8)
+	order.PutUint16(tiff[8:], 1)
+	order.PutUint16(tiff[10:], orientationTag)
+	order.PutUint16(tiff[12:], 3) // SHORT
+	order.PutUint32(tiff[14:], 1)
+	order.PutUint16(tiff[18:], orientation)
+	return tiff
+}
+
+func TestExifOrientation(t *testing.T) {
+	valid := tiffWithOrientation(binary.BigEndian, 6)
+
+	badMagic := tiffWithOrientation(binary.BigEndian, 6)
+	badMagic[3] = 43
+
+	badOffset := tiffWithOrientation(binary.BigEndian, 6)
+	binary.BigEndian.PutUint32(badOffset[4:], 1000)
+
+	otherTag := tiffWithOrientation(binary.BigEndian, 6)
+	binary.BigEndian.PutUint16(otherTag[10:], 0x0100)
+
+	truncatedSegment := exifJPEG(valid)
+	binary.BigEndian.PutUint16(truncatedSegment[4:], 0xFFFF)
+
+	scanFirst := append([]byte{0xFF, 0xD8, 0xFF, 0xDA, 0, 2}, exifJPEG(valid)[2:]...)
+
+	tests := []struct {
+		name string
+		data []byte
+		want int
+	}{
+		{"big endian", exifJPEG(valid), 6},
+		{"little endian", exifJPEG(tiffWithOrientation(binary.LittleEndian, 8)), 8},
+		{"upright", exifJPEG(tiffWithOrientation(binary.BigEndian, 1)), 1},
+		{"orientation 0", exifJPEG(tiffWithOrientation(binary.BigEndian, 0)), 1},
+		{"orientation 9", exifJPEG(tiffWithOrientation(binary.BigEndian, 9)), 1},
+		{"not a jpeg", []byte("\x89PNG\r\n\x1a\n"), 1},
+		{"empty", nil, 1},
+		{"no exif", []byte{0xFF, 0xD8, 0xFF, 0xD9}, 1},
+		{"app1 without exif header", append([]byte{0xFF, 0xD8, 0xFF, 0xE1, 0, 6}, "XMP\x00"...), 1},
+		{"segment longer than the file", truncatedSegment, 1},
+		{"exif after the image data", scanFirst, 1},
+		{"truncated tiff", exifJPEG(valid[:6]), 1},
+		{"truncated ifd entry", exifJPEG(valid[:16]), 1},
+		{"unknown byte order", exifJPEG(append([]byte("XX"), valid[2:]...)), 1},
+		{"bad tiff magic", exifJPEG(badMagic), 1},
+		{"ifd outside the block", exifJPEG(badOffset), 1},
+		{"no orientation tag", exifJPEG(otherTag), 1},
+		{"garbage between segments", []byte{0xFF, 0xD8, 0x00, 0x00, 0x00, 0x00}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exifOrientation(tt.data); got != tt.want {
+				t.Errorf("exifOrientation() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+// grid builds an image whose pixels are labelled by their red channel, one
+// string per row.
+func grid(rows ...string) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, len(rows[0]), len(rows)))
+	for y, row := range rows {
+		for x := range row {
+			img.Pix[img.PixOffset(x, y)] = row[x]
+			img.Pix[img.PixOffset(x, y)+3] = 0xFF
+		}
+	}
+	return img
+}
+
+func labels(img *image.RGBA) []string {
+	rows := []string{}
+	for y := 0; y < img.Bounds().Dy(); y++ {
+		row := []byte{}
+		for x := 0; x < img.Bounds().Dx(); x++ {
+			row = append(row, img.Pix[img.PixOffset(x, y)])
+		}
+		rows = append(rows, string(row))
+	}
+	return rows
+}
+
+func TestOrient(t *testing.T) {
+	tests := []struct {
+		orientation int
+		want        []string
+	}{
+		{0, []string{"AB", "CD", "EF"}},
+		{1, []string{"AB", "CD", "EF"}},
+		{2, []string{"BA", "DC", "FE"}},
+		{3, []string{"FE", "DC", "BA"}},
+		{4, []string{"EF", "CD", "AB"}},
+		{5, []string{"ACE", "BDF"}},
+		{6, []string{"ECA", "FDB"}},
+		{7, []string{"FDB", "ECA"}},
+		{8, []string{"BDF", "ACE"}},
+		{9, []string{"AB", "CD", "EF"}},
+	}
+
+	for _, tt := range tests {
+		got := labels(orient(grid("AB", "CD", "EF"), tt.orientation))
+		if len(got) != len(tt.want) {
+			t.Errorf("orient(%d) = %q, want %q", tt.orientation, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("orient(%d) = %q, want %q", tt.orientation, got, tt.want)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package jwt
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+	"testing"
+)
+
+func TestJWKS(t *testing.T) {
+	dir := t.TempDir()
+	writePrivateKey(t, dir, "2024-06.pem", testEd25519Key)
+	writePublicKey(t, dir, "2024-01.pem", testRSAKey.Public())
+	loadTestKeyDir(t, dir, "")
+
+	jwks, err := JWKS()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jwks.Keys) != 2 {
+		t.Fatalf("len(Keys) = %d, want 2", len(jwks.Keys))
+	}
+
+	rsaKey := jwks.Keys[0]
+	if rsaKey.Kid != "2024-01" || rsaKey.Kty != "RSA" || rsaKey.Alg != "RS256" || rsaKey.Use != "sig" {
+		t.Errorf("Keys[0] = %+v, want the RS256 key 2024-01", rsaKey)
+	}
+	n, err := base64.RawURLEncoding.DecodeString(rsaKey.N)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := base64.RawURLEncoding.DecodeString(rsaKey.E)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	if !decoded.Equal(testRSAKey.Public()) {
+		t.Error("Keys[0] doesn't decode to the RSA public key")
+	}
+
+	edKey := jwks.Keys[1]
+	if edKey.Kid != "2024-06" || edKey.Kty != "OKP" || edKey.Crv != "Ed25519" || edKey.Alg != "EdDSA" || edKey.Use != "sig" {
+		t.Errorf("Keys[1] = %+v, want the EdDSA key 2024-06", edKey)
+	}
+	x, err := base64.RawURLEncoding.DecodeString(edKey.X)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ed25519.PublicKey(x).Equal(testEd25519Key.Public()) {
+		t.Error("Keys[1] doesn't decode to the Ed25519 public key")
+	}
+	if edKey.N != "" || edKey.E != "" {
+		t.Errorf("Keys[1] has RSA members: %+v", edKey)
+	}
+}
+
+func TestJWKSOmitsSecrets(t *testing.T) {
+	t.Setenv("JWT_KEY_DIR", "")
+	t.Setenv("JWT_SECRET", "legacy secret")
+	if err := LoadKeySet(); err != nil {
+		t.Fatal(err)
+	}
+
+	jwks, err := JWKS()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if jwks.Keys == nil || len(jwks.Keys) != 0 {
+		t.Errorf("Keys = %#v, want an empty list", jwks.Keys)
+	}
+}
@@ -2,26 +2,30 @@ package jwt
 
 import (
 	"fmt"
-	"os"
 	"time"
 
 	"github.com/danzBraham/cats-social/internal/errors/autherror"
 	"github.com/golang-jwt/jwt/v5"
 )
 
-var key = []byte(os.Getenv("JWT_SECRET"))
-
 type CustomClaims struct {
-	UserId string
+	UserId    string
+	SessionId string
 	jwt.RegisteredClaims
 }
 
-func GenerateToken(ttl time.Duration, userId string) (string, error) {
+func GenerateToken(ttl time.Duration, userId, sessionId string) (string, error) {
+	ks, err := currentKeySet()
+	if err != nil {
+		return "", err
+	}
+
 	now := time.Now()
 	expiry := now.Add(ttl)
 
 	claims := &CustomClaims{
-		UserId: userId,
+		UserId:    userId,
+		SessionId: sessionId,
 		RegisteredClaims: jwt.RegisteredClaims{
 			IssuedAt:  jwt.NewNumericDate(now),
 			NotBefore: jwt.NewNumericDate(now),
@@ -29,20 +33,36 @@ func GenerateToken(ttl time.Duration, userId string) (string, error) {
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(key)
+	token := jwt.NewWithClaims(ks.SigningKey.Method, claims)
+	token.Header["kid"] = ks.SigningKey.Id
+	return token.SignedString(ks.SigningKey.PrivateKey)
 }
 
 type JWTPayload struct {
-	UserId string
+	UserId    string
+	SessionId string
 }
 
 func VerifyToken(tokenString string) (*JWTPayload, error) {
+	ks, err := currentKeySet()
+	if err != nil {
+		return nil, err
+	}
+
 	token, err := jwt.ParseWithClaims(tokenString, &CustomClaims{}, func(t *jwt.Token) (interface{}, error) {
-		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+		kid, _ := t.Header["kid"].(string)
+		if kid == "" {
+			// tokens issued before kids were introduced
+			kid = "default"
+		}
+		key, ok := ks.Keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown key id: %s", kid)
+		}
+		if t.Method.Alg() != key.Method.Alg() {
 			return nil, fmt.Errorf("unexpected signing method: %v", t.Method.Alg())
 		}
-		return key, nil
+		return key.PublicKey, nil
 	})
 	if err != nil {
 		return nil, autherror.ErrInvalidToken
@@ -54,6 +74,7 @@ func VerifyToken(tokenString string) (*JWTPayload, error) {
 	}
 
 	return &JWTPayload{
-		UserId: claims.UserId,
+		UserId:    claims.UserId,
+		SessionId: claims.SessionId,
 	}, nil
 }
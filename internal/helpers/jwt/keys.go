@@ -0,0 +1,171 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Key is a single entry of the key set. PrivateKey is nil for keys that are
+// only kept around to verify tokens signed before a rotation.
+type Key struct {
+	Id         string
+	Method     jwt.SigningMethod
+	PrivateKey crypto.PrivateKey
+	PublicKey  crypto.PublicKey
+}
+
+type KeySet struct {
+	SigningKey *Key
+	Keys       map[string]*Key
+}
+
+var (
+	mu     sync.RWMutex
+	keySet *KeySet
+)
+
+// LoadKeySet loads the signing and verification keys. When JWT_KEY_DIR is
+// set every *.pem file in it becomes a key whose kid is the file name
+// without extension. Private keys (RSA or Ed25519) can sign and verify,
+// public keys can only verify. The signing key is JWT_SIGNING_KEY_ID, or the
+// private key with the greatest kid. Without JWT_KEY_DIR the legacy
+// JWT_SECRET is used with HS256.
+func LoadKeySet() error {
+	var (
+		ks  *KeySet
+		err error
+	)
+	if dir := os.Getenv("JWT_KEY_DIR"); dir != "" {
+		ks, err = loadKeyDir(dir, os.Getenv("JWT_SIGNING_KEY_ID"))
+	} else {
+		ks, err = secretKeySet(os.Getenv("JWT_SECRET"))
+	}
+	if err != nil {
+		return err
+	}
+
+	mu.Lock()
+	keySet = ks
+	mu.Unlock()
+	return nil
+}
+
+func currentKeySet() (*KeySet, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+	if keySet == nil {
+		return nil, errors.New("jwt key set is not loaded")
+	}
+	return keySet, nil
+}
+
+func secretKeySet(secret string) (*KeySet, error) {
+	if secret == "" {
+		return nil, errors.New("either JWT_KEY_DIR or JWT_SECRET must be set")
+	}
+	key := &Key{
+		Id:         "default",
+		Method:     jwt.SigningMethodHS256,
+		PrivateKey: []byte(secret),
+		PublicKey:  []byte(secret),
+	}
+	return &KeySet{
+		SigningKey: key,
+		Keys:       map[string]*Key{key.Id: key},
+	}, nil
+}
+
+func loadKeyDir(dir, signingKeyId string) (*KeySet, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+
+	ks := &KeySet{Keys: map[string]*Key{}}
+	signerIds := []string{}
+	for _, path := range paths {
+		key, err := parseKeyFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load jwt key %s: %w", path, err)
+		}
+		ks.Keys[key.Id] = key
+		if key.PrivateKey != nil {
+			signerIds = append(signerIds, key.Id)
+		}
+	}
+
+	if signingKeyId == "" && len(signerIds) > 0 {
+		sort.Strings(signerIds)
+		signingKeyId = signerIds[len(signerIds)-1]
+	}
+
+	signingKey, ok := ks.Keys[signingKeyId]
+	if !ok || signingKey.PrivateKey == nil {
+		return nil, fmt.Errorf("no private jwt key found for kid %q in %s", signingKeyId, dir)
+	}
+	ks.SigningKey = signingKey
+
+	return ks, nil
+}
+
+func parseKeyFile(path string) (*Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	key := &Key{Id: strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))}
+
+	switch block.Type {
+	case "PRIVATE KEY":
+		privateKey, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		key.PrivateKey = privateKey
+		key.PublicKey = privateKey.(crypto.Signer).Public()
+	case "RSA PRIVATE KEY":
+		privateKey, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		key.PrivateKey = privateKey
+		key.PublicKey = privateKey.Public()
+	case "PUBLIC KEY":
+		publicKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		key.PublicKey = publicKey
+	default:
+		return nil, fmt.Errorf("unsupported PEM block type %q", block.Type)
+	}
+
+	switch key.PublicKey.(type) {
+	case *rsa.PublicKey:
+		key.Method = jwt.SigningMethodRS256
+	case ed25519.PublicKey:
+		key.Method = jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", key.PublicKey)
+	}
+
+	return key, nil
+}
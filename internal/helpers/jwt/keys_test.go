@@ -0,0 +1,325 @@
+package jwt
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/danzBraham/cats-social/internal/errors/autherror"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// the test keys are generated once, RSA key generation is slow enough to notice
+var (
+	testRSAKey     *rsa.PrivateKey
+	testEd25519Key ed25519.PrivateKey
+)
+
+func init() {
+	var err error
+	testRSAKey, err = rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+	_, testEd25519Key, err = ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func writePEM(t *testing.T, dir, name, blockType string, der []byte) {
+	t.Helper()
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	if err := os.WriteFile(filepath.Join(dir, name), data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writePrivateKey(t *testing.T, dir, name string, key any) {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePEM(t, dir, name, "PRIVATE KEY", der)
+}
+
+func writePublicKey(t *testing.T, dir, name string, key any) {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePEM(t, dir, name, "PUBLIC KEY", der)
+}
+
+func loadTestKeyDir(t *testing.T, dir, signingKeyId string) {
+	t.Helper()
+	t.Setenv("JWT_KEY_DIR", dir)
+	t.Setenv("JWT_SIGNING_KEY_ID", signingKeyId)
+	if err := LoadKeySet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func tokenKid(t *testing.T, token string) string {
+	t.Helper()
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, &CustomClaims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	kid, _ := parsed.Header["kid"].(string)
+	return kid
+}
+
+func TestLoadKeyDir(t *testing.T) {
+	tests := []struct {
+		name         string
+		files        func(t *testing.T, dir string)
+		signingKeyId string
+		wantSigner   string
+		wantMethod   string
+		wantKeys     int
+		wantErr      bool
+	}{
+		{
+			name: "pkcs8 rsa",
+			files: func(t *testing.T, dir string) {
+				writePrivateKey(t, dir, "2024-01.pem", testRSAKey)
+			},
+			wantSigner: "2024-01",
+			wantMethod: "RS256",
+			wantKeys:   1,
+		},
+		{
+			name: "pkcs1 rsa",
+			files: func(t *testing.T, dir string) {
+				writePEM(t, dir, "2024-01.pem", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(testRSAKey))
+			},
+			wantSigner: "2024-01",
+			wantMethod: "RS256",
+			wantKeys:   1,
+		},
+		{
+			name: "ed25519",
+			files: func(t *testing.T, dir string) {
+				writePrivateKey(t, dir, "2024-01.pem", testEd25519Key)
+			},
+			wantSigner: "2024-01",
+			wantMethod: "EdDSA",
+			wantKeys:   1,
+		},
+		{
+			name: "greatest private kid signs",
+			files: func(t *testing.T, dir string) {
+				writePrivateKey(t, dir, "2024-01.pem", testRSAKey)
+				writePrivateKey(t, dir, "2024-06.pem", testEd25519Key)
+				writePublicKey(t, dir, "2024-12.pem", testRSAKey.Public())
+			},
+			wantSigner: "2024-06",
+			wantMethod: "EdDSA",
+			wantKeys:   3,
+		},
+		{
+			name: "signing key id",
+			files: func(t *testing.T, dir string) {
+				writePrivateKey(t, dir, "2024-01.pem", testRSAKey)
+				writePrivateKey(t, dir, "2024-06.pem", testEd25519Key)
+			},
+			signingKeyId: "2024-01",
+			wantSigner:   "2024-01",
+			wantMethod:   "RS256",
+			wantKeys:     2,
+		},
+		{
+			name: "signing key id is a public key",
+			files: func(t *testing.T, dir string) {
+				writePrivateKey(t, dir, "2024-01.pem", testRSAKey)
+				writePublicKey(t, dir, "2024-06.pem", testEd25519Key.Public())
+			},
+			signingKeyId: "2024-06",
+			wantErr:      true,
+		},
+		{
+			name: "unknown signing key id",
+			files: func(t *testing.T, dir string) {
+				writePrivateKey(t, dir, "2024-01.pem", testRSAKey)
+			},
+			signingKeyId: "2025-01",
+			wantErr:      true,
+		},
+		{
+			name: "only public keys",
+			files: func(t *testing.T, dir string) {
+				writePublicKey(t, dir, "2024-01.pem", testRSAKey.Public())
+			},
+			wantErr: true,
+		},
+		{
+			name:    "empty dir",
+			files:   func(t *testing.T, dir string) {},
+			wantErr: true,
+		},
+		{
+			name: "not pem",
+			files: func(t *testing.T, dir string) {
+				if err := os.WriteFile(filepath.Join(dir, "2024-01.pem"), []byte("not a key"), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantErr: true,
+		},
+		{
+			name: "unsupported block",
+			files: func(t *testing.T, dir string) {
+				writePEM(t, dir, "2024-01.pem", "CERTIFICATE", []byte("cert"))
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			tt.files(t, dir)
+
+			ks, err := loadKeyDir(dir, tt.signingKeyId)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loadKeyDir() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if ks.SigningKey.Id != tt.wantSigner {
+				t.Errorf("signing kid = %q, want %q", ks.SigningKey.Id, tt.wantSigner)
+			}
+			if ks.SigningKey.Method.Alg() != tt.wantMethod {
+				t.Errorf("signing method = %q, want %q", ks.SigningKey.Method.Alg(), tt.wantMethod)
+			}
+			if len(ks.Keys) != tt.wantKeys {
+				t.Errorf("len(Keys) = %d, want %d", len(ks.Keys), tt.wantKeys)
+			}
+		})
+	}
+}
+
+func TestLoadKeySetSecret(t *testing.T) {
+	t.Setenv("JWT_KEY_DIR", "")
+	t.Setenv("JWT_SECRET", "")
+	if err := LoadKeySet(); err == nil {
+		t.Error("LoadKeySet() without a key dir or secret error = nil, want an error")
+	}
+
+	t.Setenv("JWT_SECRET", "legacy secret")
+	if err := LoadKeySet(); err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := GenerateToken(time.Hour, "01J1Z2USER", "01J1Z2SESSION")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kid := tokenKid(t, token); kid != "default" {
+		t.Errorf("kid = %q, want default", kid)
+	}
+
+	// tokens from before kids were introduced carry no kid header
+	legacy, err := jwt.NewWithClaims(jwt.SigningMethodHS256, &CustomClaims{
+		UserId:    "01J1Z2USER",
+		SessionId: "01J1Z2SESSION",
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	}).SignedString([]byte("legacy secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := VerifyToken(legacy)
+	if err != nil {
+		t.Fatalf("VerifyToken() error = %v", err)
+	}
+	if payload.UserId != "01J1Z2USER" || payload.SessionId != "01J1Z2SESSION" {
+		t.Errorf("VerifyToken() = %+v", payload)
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	dir := t.TempDir()
+	writePrivateKey(t, dir, "2024-01.pem", testRSAKey)
+	loadTestKeyDir(t, dir, "")
+
+	before, err := GenerateToken(time.Hour, "01J1Z2USER", "01J1Z2SESSION")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kid := tokenKid(t, before); kid != "2024-01" {
+		t.Fatalf("kid = %q, want 2024-01", kid)
+	}
+
+	// rotate: a new signing key, the old one is kept to verify only
+	os.Remove(filepath.Join(dir, "2024-01.pem"))
+	writePublicKey(t, dir, "2024-01.pem", testRSAKey.Public())
+	writePrivateKey(t, dir, "2024-06.pem", testEd25519Key)
+	loadTestKeyDir(t, dir, "")
+
+	after, err := GenerateToken(time.Hour, "01J1Z2USER", "01J1Z2SESSION")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kid := tokenKid(t, after); kid != "2024-06" {
+		t.Errorf("kid = %q, want 2024-06", kid)
+	}
+
+	for name, token := range map[string]string{"before rotation": before, "after rotation": after} {
+		payload, err := VerifyToken(token)
+		if err != nil {
+			t.Errorf("VerifyToken(%s) error = %v", name, err)
+			continue
+		}
+		if payload.UserId != "01J1Z2USER" || payload.SessionId != "01J1Z2SESSION" {
+			t.Errorf("VerifyToken(%s) = %+v", name, payload)
+		}
+	}
+
+	// retire the old key
+	os.Remove(filepath.Join(dir, "2024-01.pem"))
+	loadTestKeyDir(t, dir, "")
+
+	if _, err := VerifyToken(before); !errors.Is(err, autherror.ErrInvalidToken) {
+		t.Errorf("VerifyToken() with a retired kid error = %v, want %v", err, autherror.ErrInvalidToken)
+	}
+}
+
+func TestVerifyTokenRejectsAlgorithmMismatch(t *testing.T) {
+	dir := t.TempDir()
+	writePrivateKey(t, dir, "2024-01.pem", testRSAKey)
+	loadTestKeyDir(t, dir, "")
+
+	// an HS256 token keyed with the public key must not pass as RS256
+	publicDER, err := x509.MarshalPKIXPublicKey(testRSAKey.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, &CustomClaims{
+		UserId: "01J1Z2USER",
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	})
+	token.Header["kid"] = "2024-01"
+	forged, err := token.SignedString(publicDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := VerifyToken(forged); !errors.Is(err, autherror.ErrInvalidToken) {
+		t.Errorf("VerifyToken() error = %v, want %v", err, autherror.ErrInvalidToken)
+	}
+}
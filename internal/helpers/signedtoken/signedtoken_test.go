@@ -0,0 +1,115 @@
+package signedtoken
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/danzBraham/cats-social/internal/errors/autherror"
+)
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+func loadTestSecret(t *testing.T, value string) {
+	t.Helper()
+	if err := setSecret(value); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	loadTestSecret(t, testSecret)
+
+	valid, err := Generate("email-verification", "user|01J1Z2", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, signature, _ := strings.Cut(valid, ".")
+
+	expired, err := Generate("email-verification", "user|01J1Z2", -time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	forged := base64.RawURLEncoding.EncodeToString([]byte("admin|9999999999")) + "." + signature
+
+	flipped := []byte(signature)
+	if flipped[0] == 'A' {
+		flipped[0] = 'B'
+	} else {
+		flipped[0] = 'A'
+	}
+
+	tests := []struct {
+		name    string
+		purpose string
+		token   string
+		subject string
+		err     error
+	}{
+		{"valid", "email-verification", valid, "user|01J1Z2", nil},
+		{"purpose mismatch", "password-reset", valid, "", autherror.ErrInvalidToken},
+		{"expired", "email-verification", expired, "", autherror.ErrInvalidToken},
+		{"tampered payload", "email-verification", forged, "", autherror.ErrInvalidToken},
+		{"tampered signature", "email-verification", payload + "." + string(flipped), "", autherror.ErrInvalidToken},
+		{"missing signature", "email-verification", payload, "", autherror.ErrInvalidToken},
+		{"empty", "email-verification", "", "", autherror.ErrInvalidToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			subject, err := Verify(tt.purpose, tt.token)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Verify() error = %v, want %v", err, tt.err)
+			}
+			if subject != tt.subject {
+				t.Errorf("Verify() subject = %q, want %q", subject, tt.subject)
+			}
+		})
+	}
+}
+
+func TestVerifyAfterSecretRotation(t *testing.T) {
+	loadTestSecret(t, testSecret)
+	token, err := Generate("email-verification", "01J1Z2", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loadTestSecret(t, strings.Repeat("x", MinSecretLength))
+	if _, err := Verify("email-verification", token); !errors.Is(err, autherror.ErrInvalidToken) {
+		t.Errorf("Verify() error = %v, want %v", err, autherror.ErrInvalidToken)
+	}
+}
+
+func TestSetSecret(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		wantErr bool
+	}{
+		{"empty", "", true},
+		{"too short", strings.Repeat("x", MinSecretLength-1), true},
+		{"long enough", strings.Repeat("x", MinSecretLength), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := setSecret(tt.value); (err != nil) != tt.wantErr {
+				t.Errorf("setSecret() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGenerateWithoutSecret(t *testing.T) {
+	mu.Lock()
+	secret = nil
+	mu.Unlock()
+
+	if _, err := Generate("email-verification", "01J1Z2", time.Hour); !errors.Is(err, ErrSecretNotLoaded) {
+		t.Errorf("Generate() error = %v, want %v", err, ErrSecretNotLoaded)
+	}
+}
@@ -0,0 +1,132 @@
+package totp
+
+import (
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA1 seed of the RFC 6238 test vectors,
+// "12345678901234567890" in base32.
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestValidateRFC6238Vectors(t *testing.T) {
+	// the RFC lists 8 digit codes, the last 6 digits are the 6 digit code
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, tt := range tests {
+		step, ok := Validate(rfcSecret, tt.code, time.Unix(tt.unix, 0))
+		if !ok {
+			t.Errorf("Validate(%q) at %d = false, want true", tt.code, tt.unix)
+			continue
+		}
+		if want := tt.unix / 30; step != want {
+			t.Errorf("Validate(%q) at %d step = %d, want %d", tt.code, tt.unix, step, want)
+		}
+	}
+}
+
+func TestValidateWindow(t *testing.T) {
+	// 081804 is the code of step 37037036, which covers 1111111080 to
+	// 1111111109
+	tests := []struct {
+		name string
+		unix int64
+		ok   bool
+	}{
+		{"two steps early", 1111111049, false},
+		{"one step early", 1111111050, true},
+		{"same step", 1111111109, true},
+		{"one step late", 1111111139, true},
+		{"two steps late", 1111111140, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := Validate(rfcSecret, "081804", time.Unix(tt.unix, 0))
+			if ok != tt.ok {
+				t.Fatalf("Validate at %d = %v, want %v", tt.unix, ok, tt.ok)
+			}
+			if ok && step != 37037036 {
+				t.Errorf("Validate at %d step = %d, want 37037036", tt.unix, step)
+			}
+		})
+	}
+}
+
+func TestValidateReplay(t *testing.T) {
+	// a replayed code matches the step it was first accepted for, which is
+	// what lets callers refuse it
+	first, ok := Validate(rfcSecret, "287082", time.Unix(59, 0))
+	if !ok {
+		t.Fatal("first use was refused")
+	}
+
+	tests := []struct {
+		name string
+		unix int64
+	}{
+		{"same second", 59},
+		{"next step", 75},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := Validate(rfcSecret, "287082", time.Unix(tt.unix, 0))
+			if !ok {
+				t.Fatal("replay was refused by Validate, it should be left to the caller")
+			}
+			if step != first {
+				t.Errorf("replay step = %d, want %d", step, first)
+			}
+		})
+	}
+}
+
+func TestValidateRejectsMalformedInput(t *testing.T) {
+	tests := []struct {
+		name   string
+		secret string
+		code   string
+	}{
+		{"short code", rfcSecret, "28708"},
+		{"long code", rfcSecret, "2870820"},
+		{"wrong code", rfcSecret, "287083"},
+		{"invalid secret", "not base32!", "287082"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, ok := Validate(tt.secret, tt.code, time.Unix(59, 0)); ok {
+				t.Errorf("Validate(%q, %q) = true, want false", tt.secret, tt.code)
+			}
+		})
+	}
+}
+
+func TestGenerateSecretRoundTrip(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := encoding.DecodeString(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Unix(1720000000, 0)
+	code := generate(key, now.Unix()/30)
+	if _, ok := Validate(secret, code, now); !ok {
+		t.Errorf("Validate refused a code of a generated secret")
+	}
+}
@@ -0,0 +1,154 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/danzBraham/cats-social/internal/entities/userentity"
+	"github.com/danzBraham/cats-social/internal/errors/autherror"
+	"github.com/danzBraham/cats-social/internal/errors/caterror"
+	"github.com/danzBraham/cats-social/internal/errors/matcherror"
+	"github.com/danzBraham/cats-social/internal/errors/usererror"
+	"github.com/danzBraham/cats-social/internal/helpers/httphelper"
+	"github.com/danzBraham/cats-social/internal/http/middlewares"
+	"github.com/danzBraham/cats-social/internal/services"
+	"github.com/go-chi/chi/v5"
+)
+
+type AdminController interface {
+	HandleGetUsers(w http.ResponseWriter, r *http.Request)
+	HandleSuspendUser(w http.ResponseWriter, r *http.Request)
+	HandleUnsuspendUser(w http.ResponseWriter, r *http.Request)
+	HandleForceDeleteCat(w http.ResponseWriter, r *http.Request)
+	HandleCancelMatch(w http.ResponseWriter, r *http.Request)
+}
+
+type AdminControllerImpl struct {
+	AdminService services.AdminService
+}
+
+func NewAdminController(adminService services.AdminService) AdminController {
+	return &AdminControllerImpl{AdminService: adminService}
+}
+
+func (c *AdminControllerImpl) HandleGetUsers(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	params := &userentity.UserQueryParams{
+		Limit:  20,
+		Offset: 0,
+		Search: query.Get("search"),
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		params.Limit, _ = strconv.Atoi(limit)
+	}
+
+	if offset := query.Get("offset"); offset != "" {
+		params.Offset, _ = strconv.Atoi(offset)
+	}
+
+	userResponses, err := c.AdminService.GetUsers(r.Context(), params)
+	if err != nil {
+		httphelper.ErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	httphelper.SuccessResponse(w, http.StatusOK, "success", userResponses)
+}
+
+func (c *AdminControllerImpl) HandleSuspendUser(w http.ResponseWriter, r *http.Request) {
+	adminId, ok := r.Context().Value(middlewares.ContextUserIdKey).(string)
+	if !ok {
+		httphelper.ErrorResponse(w, http.StatusUnauthorized, autherror.ErrUserIdNotFoundInTheContext)
+		return
+	}
+
+	userId := chi.URLParam(r, "id")
+	err := c.AdminService.SuspendUser(r.Context(), adminId, userId)
+	if errors.Is(err, usererror.ErrUserNotFound) {
+		httphelper.ErrorResponse(w, http.StatusNotFound, err)
+		return
+	}
+	if errors.Is(err, usererror.ErrCannotSuspendYourself) {
+		httphelper.ErrorResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	if err != nil {
+		httphelper.ErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	httphelper.SuccessResponse(w, http.StatusOK, "successfully suspend user", nil)
+}
+
+func (c *AdminControllerImpl) HandleUnsuspendUser(w http.ResponseWriter, r *http.Request) {
+	adminId, ok := r.Context().Value(middlewares.ContextUserIdKey).(string)
+	if !ok {
+		httphelper.ErrorResponse(w, http.StatusUnauthorized, autherror.ErrUserIdNotFoundInTheContext)
+		return
+	}
+
+	userId := chi.URLParam(r, "id")
+	err := c.AdminService.UnsuspendUser(r.Context(), adminId, userId)
+	if errors.Is(err, usererror.ErrUserNotFound) {
+		httphelper.ErrorResponse(w, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		httphelper.ErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	httphelper.SuccessResponse(w, http.StatusOK, "successfully unsuspend user", nil)
+}
+
+func (c *AdminControllerImpl) HandleForceDeleteCat(w http.ResponseWriter, r *http.Request) {
+	adminId, ok := r.Context().Value(middlewares.ContextUserIdKey).(string)
+	if !ok {
+		httphelper.ErrorResponse(w, http.StatusUnauthorized, autherror.ErrUserIdNotFoundInTheContext)
+		return
+	}
+
+	catId := chi.URLParam(r, "id")
+	err := c.AdminService.ForceDeleteCat(r.Context(), adminId, catId)
+	if errors.Is(err, caterror.ErrCatIdNotFound) {
+		httphelper.ErrorResponse(w, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		httphelper.ErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	httphelper.SuccessResponse(w, http.StatusOK, "successfully delete cat", nil)
+}
+
+func (c *AdminControllerImpl) HandleCancelMatch(w http.ResponseWriter, r *http.Request) {
+	adminId, ok := r.Context().Value(middlewares.ContextUserIdKey).(string)
+	if !ok {
+		httphelper.ErrorResponse(w, http.StatusUnauthorized, autherror.ErrUserIdNotFoundInTheContext)
+		return
+	}
+
+	matchId := chi.URLParam(r, "id")
+	err := c.AdminService.CancelMatch(r.Context(), adminId, matchId)
+	if errors.Is(err, matcherror.ErrMatchIdNotFound) {
+		httphelper.ErrorResponse(w, http.StatusNotFound, err)
+		return
+	}
+	if errors.Is(err, matcherror.ErrMatchRequestExpired) {
+		httphelper.ErrorResponse(w, http.StatusGone, err)
+		return
+	}
+	if errors.Is(err, matcherror.ErrMatchIdIsNoLongerValid) {
+		httphelper.ErrorResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	if err != nil {
+		httphelper.ErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	httphelper.SuccessResponse(w, http.StatusOK, "successfully cancel match request", nil)
+}
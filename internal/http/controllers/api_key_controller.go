@@ -0,0 +1,87 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/danzBraham/cats-social/internal/entities/apikeyentity"
+	"github.com/danzBraham/cats-social/internal/errors/apikeyerror"
+	"github.com/danzBraham/cats-social/internal/errors/autherror"
+	"github.com/danzBraham/cats-social/internal/helpers/httphelper"
+	"github.com/danzBraham/cats-social/internal/http/middlewares"
+	"github.com/danzBraham/cats-social/internal/services"
+	"github.com/go-chi/chi/v5"
+)
+
+type ApiKeyController interface {
+	HandleCreateApiKey(w http.ResponseWriter, r *http.Request)
+	HandleGetApiKeys(w http.ResponseWriter, r *http.Request)
+	HandleRevokeApiKey(w http.ResponseWriter, r *http.Request)
+}
+
+type ApiKeyControllerImpl struct {
+	ApiKeyService services.ApiKeyService
+}
+
+func NewApiKeyController(apiKeyService services.ApiKeyService) ApiKeyController {
+	return &ApiKeyControllerImpl{ApiKeyService: apiKeyService}
+}
+
+func (c *ApiKeyControllerImpl) HandleCreateApiKey(w http.ResponseWriter, r *http.Request) {
+	userId, ok := r.Context().Value(middlewares.ContextUserIdKey).(string)
+	if !ok {
+		httphelper.ErrorResponse(w, http.StatusUnauthorized, autherror.ErrUserIdNotFoundInTheContext)
+		return
+	}
+
+	payload := &apikeyentity.CreateApiKeyRequest{}
+	err := httphelper.DecodeAndValidate(w, r, payload)
+	if err != nil {
+		return
+	}
+
+	apiKeyResponse, err := c.ApiKeyService.CreateApiKey(r.Context(), userId, payload)
+	if err != nil {
+		httphelper.ErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	httphelper.SuccessResponse(w, http.StatusCreated, "successfully create api key", apiKeyResponse)
+}
+
+func (c *ApiKeyControllerImpl) HandleGetApiKeys(w http.ResponseWriter, r *http.Request) {
+	userId, ok := r.Context().Value(middlewares.ContextUserIdKey).(string)
+	if !ok {
+		httphelper.ErrorResponse(w, http.StatusUnauthorized, autherror.ErrUserIdNotFoundInTheContext)
+		return
+	}
+
+	apiKeyResponses, err := c.ApiKeyService.GetApiKeys(r.Context(), userId)
+	if err != nil {
+		httphelper.ErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	httphelper.SuccessResponse(w, http.StatusOK, "success", apiKeyResponses)
+}
+
+func (c *ApiKeyControllerImpl) HandleRevokeApiKey(w http.ResponseWriter, r *http.Request) {
+	userId, ok := r.Context().Value(middlewares.ContextUserIdKey).(string)
+	if !ok {
+		httphelper.ErrorResponse(w, http.StatusUnauthorized, autherror.ErrUserIdNotFoundInTheContext)
+		return
+	}
+
+	apiKeyId := chi.URLParam(r, "id")
+	err := c.ApiKeyService.RevokeApiKey(r.Context(), userId, apiKeyId)
+	if errors.Is(err, apikeyerror.ErrApiKeyIdNotFound) {
+		httphelper.ErrorResponse(w, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		httphelper.ErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	httphelper.SuccessResponse(w, http.StatusOK, "successfully revoke api key", nil)
+}
@@ -2,12 +2,19 @@ package controllers
 
 import (
 	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"net/url"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/danzBraham/cats-social/internal/entities/catentity"
 	"github.com/danzBraham/cats-social/internal/errors/autherror"
 	"github.com/danzBraham/cats-social/internal/errors/caterror"
+	"github.com/danzBraham/cats-social/internal/helpers/cursor"
 	"github.com/danzBraham/cats-social/internal/helpers/httphelper"
 	"github.com/danzBraham/cats-social/internal/http/middlewares"
 	"github.com/danzBraham/cats-social/internal/services"
@@ -17,7 +24,15 @@ import (
 type CatController interface {
 	HandleCreateCat(w http.ResponseWriter, r *http.Request)
 	HandleGetCats(w http.ResponseWriter, r *http.Request)
+	HandleGetCatById(w http.ResponseWriter, r *http.Request)
 	HandleUpdateCatById(w http.ResponseWriter, r *http.Request)
+	HandlePatchCatById(w http.ResponseWriter, r *http.Request)
+	HandleUploadCatImages(w http.ResponseWriter, r *http.Request)
+	HandleGetCatImages(w http.ResponseWriter, r *http.Request)
+	HandleUpdateCatImage(w http.ResponseWriter, r *http.Request)
+	HandleReorderCatImages(w http.ResponseWriter, r *http.Request)
+	HandleDeleteCatImage(w http.ResponseWriter, r *http.Request)
+	HandleGetCatRecommendations(w http.ResponseWriter, r *http.Request)
 	HandleDeleteCatById(w http.ResponseWriter, r *http.Request)
 }
 
@@ -58,57 +73,90 @@ func (c *CatControllerImpl) HandleGetCats(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	query := r.URL.Query()
-	params := &catentity.CatQueryParams{
-		Id:         query.Get("id"),
-		Limit:      5,
-		Offset:     0,
-		Race:       catentity.Race(query.Get("race")),
-		Sex:        catentity.Sex(query.Get("sex")),
-		AgeInMonth: query.Get("ageInMonth"),
-		Search:     query.Get("search"),
+	params, err := parseCatQueryParams(r.URL.Query())
+	if errors.Is(err, caterror.ErrInvalidCatQuery) || errors.Is(err, cursor.ErrInvalidCursor) {
+		httphelper.ErrorResponse(w, http.StatusBadRequest, err)
+		return
 	}
 
-	if limit := query.Get("limit"); limit != "" {
-		params.Limit, _ = strconv.Atoi(limit)
+	catPage, err := c.CatService.GetCats(r.Context(), userId, params)
+	if err != nil {
+		httphelper.ErrorResponse(w, http.StatusInternalServerError, err)
+		return
 	}
 
-	if offset := query.Get("offset"); offset != "" {
-		params.Offset, _ = strconv.Atoi(offset)
+	httphelper.SuccessResponseWithMeta(w, http.StatusOK, "success", catPage.Cats, &httphelper.Meta{
+		NextCursor: catPage.NextCursor,
+		HasMore:    catPage.HasMore,
+		Total:      catPage.Total,
+	})
+}
+
+func (c *CatControllerImpl) HandleGetCatById(w http.ResponseWriter, r *http.Request) {
+	catId := chi.URLParam(r, "id")
+	catResponse, err := c.CatService.GetCatById(r.Context(), catId)
+	if errors.Is(err, caterror.ErrCatIdNotFound) {
+		httphelper.ErrorResponse(w, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		httphelper.ErrorResponse(w, http.StatusInternalServerError, err)
+		return
 	}
 
-	if hasMatched := query.Get("hasMatched"); hasMatched != "" {
-		params.HasMatched, _ = strconv.ParseBool(hasMatched)
+	httphelper.SuccessResponse(w, http.StatusOK, "success", catResponse)
+}
+
+func (c *CatControllerImpl) HandleUpdateCatById(w http.ResponseWriter, r *http.Request) {
+	userId, ok := r.Context().Value(middlewares.ContextUserIdKey).(string)
+	if !ok {
+		httphelper.ErrorResponse(w, http.StatusUnauthorized, autherror.ErrUserIdNotFoundInTheContext)
+		return
 	}
 
-	if owned := query.Get("owned"); owned != "" {
-		params.Owned, _ = strconv.ParseBool(owned)
+	payload := &catentity.UpdateCatRequest{}
+	err := httphelper.DecodeAndValidate(w, r, payload)
+	if err != nil {
+		return
 	}
 
-	catResponses, err := c.CatService.GetCats(r.Context(), userId, params)
+	catId := chi.URLParam(r, "id")
+	err = c.CatService.UpdateCatById(r.Context(), userId, catId, payload)
+	if errors.Is(err, caterror.ErrCatIdNotFound) {
+		httphelper.ErrorResponse(w, http.StatusNotFound, err)
+		return
+	}
+	if errors.Is(err, caterror.ErrNotCatOwner) {
+		httphelper.ErrorResponse(w, http.StatusForbidden, err)
+		return
+	}
+	if errors.Is(err, caterror.ErrSexIsEdited) {
+		httphelper.ErrorResponse(w, http.StatusBadRequest, err)
+		return
+	}
 	if err != nil {
 		httphelper.ErrorResponse(w, http.StatusInternalServerError, err)
 		return
 	}
 
-	httphelper.SuccessResponse(w, http.StatusOK, "success", catResponses)
+	httphelper.SuccessResponse(w, http.StatusOK, "successfully update cat", nil)
 }
 
-func (c *CatControllerImpl) HandleUpdateCatById(w http.ResponseWriter, r *http.Request) {
+func (c *CatControllerImpl) HandlePatchCatById(w http.ResponseWriter, r *http.Request) {
 	userId, ok := r.Context().Value(middlewares.ContextUserIdKey).(string)
 	if !ok {
 		httphelper.ErrorResponse(w, http.StatusUnauthorized, autherror.ErrUserIdNotFoundInTheContext)
 		return
 	}
 
-	payload := &catentity.UpdateCatRequest{}
+	payload := &catentity.PatchCatRequest{}
 	err := httphelper.DecodeAndValidate(w, r, payload)
 	if err != nil {
 		return
 	}
 
 	catId := chi.URLParam(r, "id")
-	err = c.CatService.UpdateCatById(r.Context(), userId, catId, payload)
+	err = c.CatService.PatchCatById(r.Context(), userId, catId, payload)
 	if errors.Is(err, caterror.ErrCatIdNotFound) {
 		httphelper.ErrorResponse(w, http.StatusNotFound, err)
 		return
@@ -129,6 +177,212 @@ func (c *CatControllerImpl) HandleUpdateCatById(w http.ResponseWriter, r *http.R
 	httphelper.SuccessResponse(w, http.StatusOK, "successfully update cat", nil)
 }
 
+func (c *CatControllerImpl) HandleUploadCatImages(w http.ResponseWriter, r *http.Request) {
+	userId, ok := r.Context().Value(middlewares.ContextUserIdKey).(string)
+	if !ok {
+		httphelper.ErrorResponse(w, http.StatusUnauthorized, autherror.ErrUserIdNotFoundInTheContext)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, services.MaxImagesPerUpload*services.MaxImageSize+1<<20)
+	images, err := readImages(r)
+	var maxBytesError *http.MaxBytesError
+	if errors.As(err, &maxBytesError) {
+		httphelper.ErrorResponse(w, http.StatusRequestEntityTooLarge, caterror.ErrImageTooLarge)
+		return
+	}
+	if err != nil {
+		httphelper.ErrorResponse(w, http.StatusBadRequest, err)
+		return
+	}
+
+	catId := chi.URLParam(r, "id")
+	imagesResponse, err := c.CatService.UploadCatImages(r.Context(), userId, catId, images)
+	if errors.Is(err, caterror.ErrNoImagesUploaded) || errors.Is(err, caterror.ErrTooManyImages) {
+		httphelper.ErrorResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	if errors.Is(err, caterror.ErrImageTooLarge) {
+		httphelper.ErrorResponse(w, http.StatusRequestEntityTooLarge, err)
+		return
+	}
+	if errors.Is(err, caterror.ErrUnsupportedImageType) {
+		httphelper.ErrorResponse(w, http.StatusUnsupportedMediaType, err)
+		return
+	}
+	if errors.Is(err, caterror.ErrCatIdNotFound) {
+		httphelper.ErrorResponse(w, http.StatusNotFound, err)
+		return
+	}
+	if errors.Is(err, caterror.ErrNotCatOwner) {
+		httphelper.ErrorResponse(w, http.StatusForbidden, err)
+		return
+	}
+	if err != nil {
+		httphelper.ErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	httphelper.SuccessResponse(w, http.StatusCreated, "successfully upload images", imagesResponse)
+}
+
+func (c *CatControllerImpl) HandleGetCatImages(w http.ResponseWriter, r *http.Request) {
+	catId := chi.URLParam(r, "id")
+	imagesResponse, err := c.CatService.GetCatImages(r.Context(), catId)
+	if errors.Is(err, caterror.ErrCatIdNotFound) {
+		httphelper.ErrorResponse(w, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		httphelper.ErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	httphelper.SuccessResponse(w, http.StatusOK, "success", imagesResponse)
+}
+
+func (c *CatControllerImpl) HandleUpdateCatImage(w http.ResponseWriter, r *http.Request) {
+	userId, ok := r.Context().Value(middlewares.ContextUserIdKey).(string)
+	if !ok {
+		httphelper.ErrorResponse(w, http.StatusUnauthorized, autherror.ErrUserIdNotFoundInTheContext)
+		return
+	}
+
+	payload := &catentity.UpdateCatImageRequest{}
+	err := httphelper.DecodeAndValidate(w, r, payload)
+	if err != nil {
+		return
+	}
+
+	catId := chi.URLParam(r, "id")
+	imageId := chi.URLParam(r, "imageId")
+	err = c.CatService.UpdateCatImage(r.Context(), userId, catId, imageId, payload)
+	if errors.Is(err, caterror.ErrCatIdNotFound) || errors.Is(err, caterror.ErrCatImageIdNotFound) {
+		httphelper.ErrorResponse(w, http.StatusNotFound, err)
+		return
+	}
+	if errors.Is(err, caterror.ErrNotCatOwner) {
+		httphelper.ErrorResponse(w, http.StatusForbidden, err)
+		return
+	}
+	if err != nil {
+		httphelper.ErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	httphelper.SuccessResponse(w, http.StatusOK, "successfully update cat image", nil)
+}
+
+func (c *CatControllerImpl) HandleReorderCatImages(w http.ResponseWriter, r *http.Request) {
+	userId, ok := r.Context().Value(middlewares.ContextUserIdKey).(string)
+	if !ok {
+		httphelper.ErrorResponse(w, http.StatusUnauthorized, autherror.ErrUserIdNotFoundInTheContext)
+		return
+	}
+
+	payload := &catentity.ReorderCatImagesRequest{}
+	err := httphelper.DecodeAndValidate(w, r, payload)
+	if err != nil {
+		return
+	}
+
+	catId := chi.URLParam(r, "id")
+	err = c.CatService.ReorderCatImages(r.Context(), userId, catId, payload)
+	if errors.Is(err, caterror.ErrCatIdNotFound) {
+		httphelper.ErrorResponse(w, http.StatusNotFound, err)
+		return
+	}
+	if errors.Is(err, caterror.ErrNotCatOwner) {
+		httphelper.ErrorResponse(w, http.StatusForbidden, err)
+		return
+	}
+	if errors.Is(err, caterror.ErrInvalidImageOrder) {
+		httphelper.ErrorResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	if err != nil {
+		httphelper.ErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	httphelper.SuccessResponse(w, http.StatusOK, "successfully reorder cat images", nil)
+}
+
+func (c *CatControllerImpl) HandleDeleteCatImage(w http.ResponseWriter, r *http.Request) {
+	userId, ok := r.Context().Value(middlewares.ContextUserIdKey).(string)
+	if !ok {
+		httphelper.ErrorResponse(w, http.StatusUnauthorized, autherror.ErrUserIdNotFoundInTheContext)
+		return
+	}
+
+	catId := chi.URLParam(r, "id")
+	imageId := chi.URLParam(r, "imageId")
+	err := c.CatService.DeleteCatImage(r.Context(), userId, catId, imageId)
+	if errors.Is(err, caterror.ErrCatIdNotFound) || errors.Is(err, caterror.ErrCatImageIdNotFound) {
+		httphelper.ErrorResponse(w, http.StatusNotFound, err)
+		return
+	}
+	if errors.Is(err, caterror.ErrNotCatOwner) {
+		httphelper.ErrorResponse(w, http.StatusForbidden, err)
+		return
+	}
+	if err != nil {
+		httphelper.ErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	httphelper.SuccessResponse(w, http.StatusOK, "successfully delete cat image", nil)
+}
+
+func (c *CatControllerImpl) HandleGetCatRecommendations(w http.ResponseWriter, r *http.Request) {
+	userId, ok := r.Context().Value(middlewares.ContextUserIdKey).(string)
+	if !ok {
+		httphelper.ErrorResponse(w, http.StatusUnauthorized, autherror.ErrUserIdNotFoundInTheContext)
+		return
+	}
+
+	query := r.URL.Query()
+	params := &catentity.RecommendationQueryParams{Limit: 5}
+
+	if limit := query.Get("limit"); limit != "" {
+		var err error
+		params.Limit, err = strconv.Atoi(limit)
+		if err != nil || params.Limit < 1 {
+			httphelper.ErrorResponse(w, http.StatusBadRequest, fmt.Errorf("%w: limit must be a positive number", caterror.ErrInvalidCatQuery))
+			return
+		}
+	}
+
+	if after := query.Get("cursor"); after != "" {
+		params.Cursor = &catentity.RecommendationCursor{}
+		err := cursor.Decode(after, &params.Cursor.AsOf, &params.Cursor.Score, &params.Cursor.Id)
+		if err != nil {
+			httphelper.ErrorResponse(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	catId := chi.URLParam(r, "id")
+	page, err := c.CatService.GetRecommendations(r.Context(), userId, catId, params)
+	if errors.Is(err, caterror.ErrCatIdNotFound) {
+		httphelper.ErrorResponse(w, http.StatusNotFound, err)
+		return
+	}
+	if errors.Is(err, caterror.ErrNotCatOwner) {
+		httphelper.ErrorResponse(w, http.StatusForbidden, err)
+		return
+	}
+	if err != nil {
+		httphelper.ErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	httphelper.SuccessResponseWithMeta(w, http.StatusOK, "success", page.Recommendations, &httphelper.Meta{
+		NextCursor: page.NextCursor,
+		HasMore:    page.HasMore,
+	})
+}
+
 func (c *CatControllerImpl) HandleDeleteCatById(w http.ResponseWriter, r *http.Request) {
 	userId, ok := r.Context().Value(middlewares.ContextUserIdKey).(string)
 	if !ok {
@@ -153,3 +407,339 @@ func (c *CatControllerImpl) HandleDeleteCatById(w http.ResponseWriter, r *http.R
 
 	httphelper.SuccessResponse(w, http.StatusOK, "successfully delete cat", nil)
 }
+
+// readImages reads every file sent in the "images" field of a multipart form.
+// Each file is read one byte past the size limit so the service can reject it.
+func readImages(r *http.Request) ([][]byte, error) {
+	err := r.ParseMultipartForm(1 << 20)
+	if err != nil {
+		return nil, err
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	images := [][]byte{}
+	for _, fileHeader := range r.MultipartForm.File["images"] {
+		file, err := fileHeader.Open()
+		if err != nil {
+			return nil, err
+		}
+		image, err := io.ReadAll(io.LimitReader(file, services.MaxImageSize+1))
+		file.Close()
+		if err != nil {
+			return nil, err
+		}
+		images = append(images, image)
+	}
+	return images, nil
+}
+
+var ageOperators = map[string]string{
+	"eq":  "=",
+	"lt":  "<",
+	"lte": "<=",
+	"gt":  ">",
+	"gte": ">=",
+}
+
+var validRaces = map[catentity.Race]bool{
+	catentity.Persian:          true,
+	catentity.MaineCoon:        true,
+	catentity.Siamese:          true,
+	catentity.Ragdoll:          true,
+	catentity.Bengal:           true,
+	catentity.Sphynx:           true,
+	catentity.BritishShorthair: true,
+	catentity.Abyssinian:       true,
+	catentity.ScottishFold:     true,
+	catentity.Birman:           true,
+}
+
+var validSortFields = map[catentity.CatSortField]bool{
+	catentity.SortByName:       true,
+	catentity.SortByAgeInMonth: true,
+	catentity.SortByCreatedAt:  true,
+	catentity.SortByUpdatedAt:  true,
+	catentity.SortByRelevance:  true,
+	catentity.SortByDistance:   true,
+}
+
+// parseCatQueryParams reads the filters, sort and pagination of GET /v1/cat.
+// Every malformed parameter is rejected with ErrInvalidCatQuery rather than
+// ignored, so a typo doesn't silently return the wrong cats.
+func parseCatQueryParams(query url.Values) (*catentity.CatQueryParams, error) {
+	params := &catentity.CatQueryParams{
+		Id:     query.Get("id"),
+		Limit:  5,
+		Offset: 0,
+		Search: strings.TrimSpace(query.Get("search")),
+		Sort:   catentity.DefaultCatSort,
+	}
+
+	var err error
+	if limit := query.Get("limit"); limit != "" {
+		params.Limit, err = strconv.Atoi(limit)
+		if err != nil || params.Limit < 1 {
+			return nil, fmt.Errorf("%w: limit must be a positive number", caterror.ErrInvalidCatQuery)
+		}
+	}
+
+	if offset := query.Get("offset"); offset != "" {
+		params.Offset, err = strconv.Atoi(offset)
+		if err != nil || params.Offset < 0 {
+			return nil, fmt.Errorf("%w: offset must be zero or a positive number", caterror.ErrInvalidCatQuery)
+		}
+	}
+
+	for _, race := range listQueryValues(query, "race") {
+		if !validRaces[catentity.Race(race)] {
+			return nil, fmt.Errorf("%w: %q is not a valid race", caterror.ErrInvalidCatQuery, race)
+		}
+		params.Races = append(params.Races, catentity.Race(race))
+	}
+
+	for _, sex := range listQueryValues(query, "sex") {
+		if sex != string(catentity.Male) && sex != string(catentity.Female) {
+			return nil, fmt.Errorf("%w: sex must be male or female, got %q", caterror.ErrInvalidCatQuery, sex)
+		}
+		params.Sexes = append(params.Sexes, catentity.Sex(sex))
+	}
+
+	params.HasMatched, err = parseBoolQuery(query, "hasMatched")
+	if err != nil {
+		return nil, err
+	}
+
+	params.Owned, err = parseBoolQuery(query, "owned")
+	if err != nil {
+		return nil, err
+	}
+
+	params.IncludeTotal, err = parseBoolQuery(query, "includeTotal")
+	if err != nil {
+		return nil, err
+	}
+
+	params.AgeInMonth, err = parseAgeFilters(query)
+	if err != nil {
+		return nil, err
+	}
+
+	params.Near, err = parseNearFilter(query)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case params.Search != "":
+		params.Sort = catentity.DefaultCatSearchSort
+	case params.Near != nil:
+		params.Sort = catentity.DefaultCatNearSort
+	}
+
+	if sortQuery := query.Get("sort"); sortQuery != "" {
+		params.Sort, err = parseCatSort(sortQuery)
+		if err != nil {
+			return nil, err
+		}
+		for _, catSort := range params.Sort {
+			if catSort.Field == catentity.SortByRelevance && params.Search == "" {
+				return nil, fmt.Errorf("%w: sorting by relevance needs a search", caterror.ErrInvalidCatQuery)
+			}
+			if catSort.Field == catentity.SortByDistance && params.Near == nil {
+				return nil, fmt.Errorf("%w: sorting by distance needs lat and lng", caterror.ErrInvalidCatQuery)
+			}
+		}
+	}
+
+	if after := query.Get("cursor"); after != "" {
+		params.Cursor, err = parseCatCursor(after, params.Sort)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return params, nil
+}
+
+// listQueryValues accepts both race=Persian,Bengal and race=Persian&race=Bengal.
+func listQueryValues(query url.Values, key string) []string {
+	values := []string{}
+	for _, value := range query[key] {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				values = append(values, item)
+			}
+		}
+	}
+	return values
+}
+
+func parseBoolQuery(query url.Values, key string) (bool, error) {
+	value := query.Get(key)
+	if value == "" {
+		return false, nil
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("%w: %s must be true or false", caterror.ErrInvalidCatQuery, key)
+	}
+	return parsed, nil
+}
+
+// parseAgeFilters reads ageInMonth[gte]=4&ageInMonth[lte]=12 as well as the
+// older single comparison ageInMonth=>4, ageInMonth=<4 or ageInMonth=4.
+func parseAgeFilters(query url.Values) ([]catentity.AgeFilter, error) {
+	filters := []catentity.AgeFilter{}
+
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		values := query[key]
+		if key == "ageInMonth" {
+			for _, value := range values {
+				operator := "="
+				for _, prefix := range []string{"<=", ">=", "<", ">", "="} {
+					if strings.HasPrefix(value, prefix) {
+						operator = prefix
+						value = strings.TrimPrefix(value, prefix)
+						break
+					}
+				}
+				filter, err := newAgeFilter(operator, value)
+				if err != nil {
+					return nil, err
+				}
+				filters = append(filters, filter)
+			}
+			continue
+		}
+
+		name, ok := strings.CutPrefix(key, "ageInMonth[")
+		if !ok {
+			continue
+		}
+		name, ok = strings.CutSuffix(name, "]")
+		operator := ageOperators[name]
+		if !ok || operator == "" {
+			return nil, fmt.Errorf("%w: %s is not a valid age filter, use eq, lt, lte, gt or gte", caterror.ErrInvalidCatQuery, key)
+		}
+		for _, value := range values {
+			filter, err := newAgeFilter(operator, value)
+			if err != nil {
+				return nil, err
+			}
+			filters = append(filters, filter)
+		}
+	}
+
+	return filters, nil
+}
+
+func newAgeFilter(operator, value string) (catentity.AgeFilter, error) {
+	age, err := strconv.Atoi(value)
+	if err != nil {
+		return catentity.AgeFilter{}, fmt.Errorf("%w: ageInMonth must be a number, got %q", caterror.ErrInvalidCatQuery, value)
+	}
+	return catentity.AgeFilter{Operator: operator, Value: age}, nil
+}
+
+// parseNearFilter reads lat and lng, which go together, and the optional
+// radiusKm around them.
+func parseNearFilter(query url.Values) (*catentity.NearFilter, error) {
+	lat, lng, radiusKm := query.Get("lat"), query.Get("lng"), query.Get("radiusKm")
+	if lat == "" && lng == "" {
+		if radiusKm != "" {
+			return nil, fmt.Errorf("%w: radiusKm needs lat and lng", caterror.ErrInvalidCatQuery)
+		}
+		return nil, nil
+	}
+	if lat == "" || lng == "" {
+		return nil, fmt.Errorf("%w: lat and lng must be given together", caterror.ErrInvalidCatQuery)
+	}
+
+	near := &catentity.NearFilter{}
+	var err error
+	near.Latitude, err = strconv.ParseFloat(lat, 64)
+	if err != nil || !(near.Latitude >= -90 && near.Latitude <= 90) {
+		return nil, fmt.Errorf("%w: lat must be a number between -90 and 90", caterror.ErrInvalidCatQuery)
+	}
+	near.Longitude, err = strconv.ParseFloat(lng, 64)
+	if err != nil || !(near.Longitude >= -180 && near.Longitude <= 180) {
+		return nil, fmt.Errorf("%w: lng must be a number between -180 and 180", caterror.ErrInvalidCatQuery)
+	}
+	if radiusKm != "" {
+		near.RadiusKm, err = strconv.ParseFloat(radiusKm, 64)
+		if err != nil || !(near.RadiusKm > 0) {
+			return nil, fmt.Errorf("%w: radiusKm must be a positive number", caterror.ErrInvalidCatQuery)
+		}
+	}
+
+	return near, nil
+}
+
+// parseCatSort reads sort=ageInMonth:desc,name, the direction defaults to asc.
+func parseCatSort(value string) ([]catentity.CatSort, error) {
+	sorts := []catentity.CatSort{}
+	seen := map[catentity.CatSortField]bool{}
+
+	for _, item := range strings.Split(value, ",") {
+		field, direction, _ := strings.Cut(strings.TrimSpace(item), ":")
+		sortField := catentity.CatSortField(field)
+		if !validSortFields[sortField] {
+			return nil, fmt.Errorf("%w: cannot sort by %q, use name, ageInMonth, createdAt, updatedAt, relevance or distance", caterror.ErrInvalidCatQuery, field)
+		}
+		if seen[sortField] {
+			return nil, fmt.Errorf("%w: %s is sorted by twice", caterror.ErrInvalidCatQuery, field)
+		}
+		seen[sortField] = true
+
+		catSort := catentity.CatSort{Field: sortField}
+		switch direction {
+		case "", "asc":
+		case "desc":
+			catSort.Desc = true
+		default:
+			return nil, fmt.Errorf("%w: sort direction must be asc or desc, got %q", caterror.ErrInvalidCatQuery, direction)
+		}
+		sorts = append(sorts, catSort)
+	}
+
+	return sorts, nil
+}
+
+// parseCatCursor decodes a cursor issued by GetCats for the same sort. The
+// cursor starts with the sort it was issued for, then has one value per sort
+// field and the cat id.
+func parseCatCursor(value string, sorts []catentity.CatSort) (*catentity.CatCursor, error) {
+	var sortKey, catId string
+	values := []interface{}{&sortKey}
+	for _, catSort := range sorts {
+		switch catSort.Field {
+		case catentity.SortByName:
+			values = append(values, new(string))
+		case catentity.SortByAgeInMonth:
+			values = append(values, new(int))
+		case catentity.SortByRelevance:
+			values = append(values, new(float32))
+		case catentity.SortByDistance:
+			values = append(values, new(float64))
+		default:
+			values = append(values, new(time.Time))
+		}
+	}
+	values = append(values, &catId)
+
+	err := cursor.Decode(value, values...)
+	if err != nil {
+		return nil, err
+	}
+	if sortKey != catentity.SortKey(sorts) || catId == "" {
+		return nil, cursor.ErrInvalidCursor
+	}
+
+	return &catentity.CatCursor{Values: values[1:]}, nil
+}
@@ -2,11 +2,17 @@ package controllers
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
 
 	"github.com/danzBraham/cats-social/internal/entities/matchentity"
 	"github.com/danzBraham/cats-social/internal/errors/autherror"
 	"github.com/danzBraham/cats-social/internal/errors/matcherror"
+	"github.com/danzBraham/cats-social/internal/errors/usererror"
+	"github.com/danzBraham/cats-social/internal/helpers/cursor"
 	"github.com/danzBraham/cats-social/internal/helpers/httphelper"
 	"github.com/danzBraham/cats-social/internal/http/middlewares"
 	"github.com/danzBraham/cats-social/internal/services"
@@ -16,8 +22,10 @@ import (
 type MatchController interface {
 	HandleCreateMatch(w http.ResponseWriter, r *http.Request)
 	HandleGetMatches(w http.ResponseWriter, r *http.Request)
+	HandleGetMatchById(w http.ResponseWriter, r *http.Request)
 	HandleApproveMatch(w http.ResponseWriter, r *http.Request)
 	HandleRejectMatch(w http.ResponseWriter, r *http.Request)
+	HandleUnmatchMatch(w http.ResponseWriter, r *http.Request)
 	HandleDeleteMatch(w http.ResponseWriter, r *http.Request)
 }
 
@@ -43,6 +51,10 @@ func (c *MatchControllerImpl) HandleCreateMatch(w http.ResponseWriter, r *http.R
 	}
 
 	err = c.MatchService.CreateMatch(r.Context(), userId, payload)
+	if errors.Is(err, usererror.ErrEmailNotVerified) {
+		httphelper.ErrorResponse(w, http.StatusForbidden, err)
+		return
+	}
 	if errors.Is(err, matcherror.ErrMatchCatIdNotFound) {
 		httphelper.ErrorResponse(w, http.StatusNotFound, err)
 		return
@@ -86,13 +98,43 @@ func (c *MatchControllerImpl) HandleGetMatches(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	matchResponses, err := c.MatchService.GetMatches(r.Context(), userId)
+	params, err := parseMatchQueryParams(r.URL.Query())
+	if errors.Is(err, matcherror.ErrInvalidMatchQuery) || errors.Is(err, cursor.ErrInvalidCursor) {
+		httphelper.ErrorResponse(w, http.StatusBadRequest, err)
+		return
+	}
+
+	matchPage, err := c.MatchService.GetMatches(r.Context(), userId, params)
 	if err != nil {
 		httphelper.ErrorResponse(w, http.StatusInternalServerError, err)
 		return
 	}
 
-	httphelper.SuccessResponse(w, http.StatusOK, "successfully get match requests", matchResponses)
+	httphelper.SuccessResponseWithMeta(w, http.StatusOK, "successfully get match requests", matchPage.Matches, &httphelper.Meta{
+		NextCursor: matchPage.NextCursor,
+		HasMore:    matchPage.HasMore,
+	})
+}
+
+func (c *MatchControllerImpl) HandleGetMatchById(w http.ResponseWriter, r *http.Request) {
+	userId, ok := r.Context().Value(middlewares.ContextUserIdKey).(string)
+	if !ok {
+		httphelper.ErrorResponse(w, http.StatusUnauthorized, autherror.ErrUserIdNotFoundInTheContext)
+		return
+	}
+
+	matchId := chi.URLParam(r, "id")
+	matchResponse, err := c.MatchService.GetMatchById(r.Context(), userId, matchId)
+	if errors.Is(err, matcherror.ErrMatchIdNotFound) {
+		httphelper.ErrorResponse(w, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		httphelper.ErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	httphelper.SuccessResponse(w, http.StatusOK, "successfully get match request", matchResponse)
 }
 
 func (c *MatchControllerImpl) HandleApproveMatch(w http.ResponseWriter, r *http.Request) {
@@ -113,6 +155,10 @@ func (c *MatchControllerImpl) HandleApproveMatch(w http.ResponseWriter, r *http.
 		httphelper.ErrorResponse(w, http.StatusNotFound, err)
 		return
 	}
+	if errors.Is(err, matcherror.ErrMatchRequestExpired) {
+		httphelper.ErrorResponse(w, http.StatusGone, err)
+		return
+	}
 	if errors.Is(err, matcherror.ErrMatchIdIsNoLongerValid) {
 		httphelper.ErrorResponse(w, http.StatusBadRequest, err)
 		return
@@ -147,6 +193,10 @@ func (c *MatchControllerImpl) HandleRejectMatch(w http.ResponseWriter, r *http.R
 		httphelper.ErrorResponse(w, http.StatusNotFound, err)
 		return
 	}
+	if errors.Is(err, matcherror.ErrMatchRequestExpired) {
+		httphelper.ErrorResponse(w, http.StatusGone, err)
+		return
+	}
 	if errors.Is(err, matcherror.ErrMatchIdIsNoLongerValid) {
 		httphelper.ErrorResponse(w, http.StatusBadRequest, err)
 		return
@@ -163,6 +213,40 @@ func (c *MatchControllerImpl) HandleRejectMatch(w http.ResponseWriter, r *http.R
 	httphelper.SuccessResponse(w, http.StatusOK, "successfully reject the cat match request", nil)
 }
 
+func (c *MatchControllerImpl) HandleUnmatchMatch(w http.ResponseWriter, r *http.Request) {
+	userId, ok := r.Context().Value(middlewares.ContextUserIdKey).(string)
+	if !ok {
+		httphelper.ErrorResponse(w, http.StatusUnauthorized, autherror.ErrUserIdNotFoundInTheContext)
+		return
+	}
+
+	payload := &matchentity.UnmatchRequest{}
+	err := httphelper.DecodeAndValidate(w, r, payload)
+	if err != nil {
+		return
+	}
+
+	err = c.MatchService.UnmatchMatch(r.Context(), userId, payload)
+	if errors.Is(err, matcherror.ErrMatchIdNotFound) {
+		httphelper.ErrorResponse(w, http.StatusNotFound, err)
+		return
+	}
+	if errors.Is(err, matcherror.ErrNotMatchParticipant) {
+		httphelper.ErrorResponse(w, http.StatusForbidden, err)
+		return
+	}
+	if errors.Is(err, matcherror.ErrMatchIsNotApproved) {
+		httphelper.ErrorResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	if err != nil {
+		httphelper.ErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	httphelper.SuccessResponse(w, http.StatusOK, "successfully unmatch the cats", nil)
+}
+
 func (c *MatchControllerImpl) HandleDeleteMatch(w http.ResponseWriter, r *http.Request) {
 	userId, ok := r.Context().Value(middlewares.ContextUserIdKey).(string)
 	if !ok {
@@ -180,6 +264,10 @@ func (c *MatchControllerImpl) HandleDeleteMatch(w http.ResponseWriter, r *http.R
 		httphelper.ErrorResponse(w, http.StatusForbidden, err)
 		return
 	}
+	if errors.Is(err, matcherror.ErrMatchRequestExpired) {
+		httphelper.ErrorResponse(w, http.StatusGone, err)
+		return
+	}
 	if errors.Is(err, matcherror.ErrMatchIdIsNoLongerValid) {
 		httphelper.ErrorResponse(w, http.StatusBadRequest, err)
 		return
@@ -191,3 +279,55 @@ func (c *MatchControllerImpl) HandleDeleteMatch(w http.ResponseWriter, r *http.R
 
 	httphelper.SuccessResponse(w, http.StatusOK, "successfully remove a cat match request", nil)
 }
+
+var matchStatuses = []matchentity.Status{
+	matchentity.Pending,
+	matchentity.Approved,
+	matchentity.Rejected,
+	matchentity.Expired,
+	matchentity.Unmatched,
+}
+
+// parseMatchQueryParams reads the filters and page of GET /v1/cat/match.
+// status takes a comma separated list, the other filters a single value.
+func parseMatchQueryParams(query url.Values) (*matchentity.MatchQueryParams, error) {
+	params := &matchentity.MatchQueryParams{Limit: 5}
+
+	if limit := query.Get("limit"); limit != "" {
+		var err error
+		params.Limit, err = strconv.Atoi(limit)
+		if err != nil || params.Limit < 1 {
+			return nil, fmt.Errorf("%w: limit must be a positive number", matcherror.ErrInvalidMatchQuery)
+		}
+	}
+
+	for _, value := range listQueryValues(query, "status") {
+		status := matchentity.Status(value)
+		if !slices.Contains(matchStatuses, status) {
+			return nil, fmt.Errorf("%w: status must be pending, approved, rejected, expired or unmatched", matcherror.ErrInvalidMatchQuery)
+		}
+		params.Statuses = append(params.Statuses, status)
+	}
+
+	if direction := query.Get("direction"); direction != "" {
+		params.Direction = matchentity.Direction(direction)
+		if params.Direction != matchentity.Incoming && params.Direction != matchentity.Outgoing {
+			return nil, fmt.Errorf("%w: direction must be incoming or outgoing", matcherror.ErrInvalidMatchQuery)
+		}
+	}
+
+	params.CatId = query.Get("catId")
+
+	if after := query.Get("cursor"); after != "" {
+		params.Cursor = &matchentity.MatchCursor{}
+		err := cursor.Decode(after, &params.Cursor.CreatedAt, &params.Cursor.Id)
+		if err != nil {
+			return nil, err
+		}
+		if params.Cursor.Id == "" {
+			return nil, cursor.ErrInvalidCursor
+		}
+	}
+
+	return params, nil
+}
@@ -0,0 +1,118 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/danzBraham/cats-social/internal/entities/apikeyentity"
+	"github.com/danzBraham/cats-social/internal/entities/messageentity"
+	"github.com/danzBraham/cats-social/internal/errors/autherror"
+	"github.com/danzBraham/cats-social/internal/errors/matcherror"
+	"github.com/danzBraham/cats-social/internal/errors/messageerror"
+	"github.com/danzBraham/cats-social/internal/helpers/cursor"
+	"github.com/danzBraham/cats-social/internal/helpers/httphelper"
+	"github.com/danzBraham/cats-social/internal/http/middlewares"
+	"github.com/danzBraham/cats-social/internal/services"
+	"github.com/go-chi/chi/v5"
+)
+
+type MessageController interface {
+	HandleCreateMessage(w http.ResponseWriter, r *http.Request)
+	HandleGetMessages(w http.ResponseWriter, r *http.Request)
+}
+
+type MessageControllerImpl struct {
+	MessageService services.MessageService
+}
+
+func NewMessageController(messageService services.MessageService) MessageController {
+	return &MessageControllerImpl{MessageService: messageService}
+}
+
+func (c *MessageControllerImpl) HandleCreateMessage(w http.ResponseWriter, r *http.Request) {
+	userId, ok := r.Context().Value(middlewares.ContextUserIdKey).(string)
+	if !ok {
+		httphelper.ErrorResponse(w, http.StatusUnauthorized, autherror.ErrUserIdNotFoundInTheContext)
+		return
+	}
+
+	payload := &messageentity.CreateMessageRequest{}
+	err := httphelper.DecodeAndValidate(w, r, payload)
+	if err != nil {
+		return
+	}
+
+	matchId := chi.URLParam(r, "id")
+	messageResponse, err := c.MessageService.CreateMessage(r.Context(), userId, matchId, payload)
+	if errors.Is(err, matcherror.ErrMatchIdNotFound) {
+		httphelper.ErrorResponse(w, http.StatusNotFound, err)
+		return
+	}
+	if errors.Is(err, messageerror.ErrThreadIsReadOnly) {
+		httphelper.ErrorResponse(w, http.StatusForbidden, err)
+		return
+	}
+	if errors.Is(err, messageerror.ErrMessageIsEmpty) {
+		httphelper.ErrorResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	if err != nil {
+		httphelper.ErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	httphelper.SuccessResponse(w, http.StatusCreated, "successfully send message", messageResponse)
+}
+
+func (c *MessageControllerImpl) HandleGetMessages(w http.ResponseWriter, r *http.Request) {
+	userId, ok := r.Context().Value(middlewares.ContextUserIdKey).(string)
+	if !ok {
+		httphelper.ErrorResponse(w, http.StatusUnauthorized, autherror.ErrUserIdNotFoundInTheContext)
+		return
+	}
+
+	query := r.URL.Query()
+	params := &messageentity.MessageQueryParams{
+		Limit:      20,
+		MarkAsRead: middlewares.HasScope(r.Context(), apikeyentity.MatchesWrite),
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		var err error
+		params.Limit, err = strconv.Atoi(limit)
+		if err != nil || params.Limit < 1 {
+			httphelper.ErrorResponse(w, http.StatusBadRequest, fmt.Errorf("%w: limit must be a positive number", messageerror.ErrInvalidMessageQuery))
+			return
+		}
+	}
+
+	if after := query.Get("cursor"); after != "" {
+		params.Cursor = &messageentity.MessageCursor{}
+		err := cursor.Decode(after, &params.Cursor.CreatedAt, &params.Cursor.Id)
+		if err == nil && params.Cursor.Id == "" {
+			err = cursor.ErrInvalidCursor
+		}
+		if err != nil {
+			httphelper.ErrorResponse(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	matchId := chi.URLParam(r, "id")
+	messagePage, err := c.MessageService.GetMessages(r.Context(), userId, matchId, params)
+	if errors.Is(err, matcherror.ErrMatchIdNotFound) {
+		httphelper.ErrorResponse(w, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		httphelper.ErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	httphelper.SuccessResponseWithMeta(w, http.StatusOK, "successfully get messages", messagePage.Messages, &httphelper.Meta{
+		NextCursor: messagePage.NextCursor,
+		HasMore:    messagePage.HasMore,
+	})
+}
@@ -0,0 +1,329 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/danzBraham/cats-social/internal/entities/notificationentity"
+	"github.com/danzBraham/cats-social/internal/errors/autherror"
+	"github.com/danzBraham/cats-social/internal/errors/notificationerror"
+	"github.com/danzBraham/cats-social/internal/helpers/cursor"
+	"github.com/danzBraham/cats-social/internal/helpers/httphelper"
+	"github.com/danzBraham/cats-social/internal/http/middlewares"
+	"github.com/danzBraham/cats-social/internal/services"
+	"github.com/go-chi/chi/v5"
+	"golang.org/x/net/websocket"
+)
+
+// heartbeatInterval keeps idle streams from being closed by proxies. The
+// access of the user is checked again on every heartbeat.
+const heartbeatInterval = 30 * time.Second
+
+type NotificationController interface {
+	HandleGetNotifications(w http.ResponseWriter, r *http.Request)
+	HandleReadNotification(w http.ResponseWriter, r *http.Request)
+	HandleReadAllNotifications(w http.ResponseWriter, r *http.Request)
+	HandleCreateStreamTicket(w http.ResponseWriter, r *http.Request)
+	HandleStreamNotifications(w http.ResponseWriter, r *http.Request)
+	HandleNotificationsWebSocket(w http.ResponseWriter, r *http.Request)
+}
+
+type NotificationControllerImpl struct {
+	NotificationService services.NotificationService
+}
+
+func NewNotificationController(notificationService services.NotificationService) NotificationController {
+	return &NotificationControllerImpl{NotificationService: notificationService}
+}
+
+func (c *NotificationControllerImpl) HandleGetNotifications(w http.ResponseWriter, r *http.Request) {
+	userId, ok := r.Context().Value(middlewares.ContextUserIdKey).(string)
+	if !ok {
+		httphelper.ErrorResponse(w, http.StatusUnauthorized, autherror.ErrUserIdNotFoundInTheContext)
+		return
+	}
+
+	query := r.URL.Query()
+	params := &notificationentity.NotificationQueryParams{Limit: 20}
+
+	if limit := query.Get("limit"); limit != "" {
+		var err error
+		params.Limit, err = strconv.Atoi(limit)
+		if err != nil || params.Limit < 1 {
+			httphelper.ErrorResponse(w, http.StatusBadRequest, fmt.Errorf("%w: limit must be a positive number", notificationerror.ErrInvalidNotificationQuery))
+			return
+		}
+	}
+
+	if unread := query.Get("unread"); unread != "" {
+		var err error
+		params.Unread, err = strconv.ParseBool(unread)
+		if err != nil {
+			httphelper.ErrorResponse(w, http.StatusBadRequest, fmt.Errorf("%w: unread must be true or false", notificationerror.ErrInvalidNotificationQuery))
+			return
+		}
+	}
+
+	if after := query.Get("cursor"); after != "" {
+		params.Cursor = &notificationentity.NotificationCursor{}
+		err := cursor.Decode(after, &params.Cursor.CreatedAt, &params.Cursor.Id)
+		if err == nil && params.Cursor.Id == "" {
+			err = cursor.ErrInvalidCursor
+		}
+		if err != nil {
+			httphelper.ErrorResponse(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	notificationPage, err := c.NotificationService.GetNotifications(r.Context(), userId, params)
+	if err != nil {
+		httphelper.ErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	httphelper.SuccessResponseWithMeta(w, http.StatusOK, "successfully get notifications", notificationPage.Notifications, &httphelper.Meta{
+		NextCursor: notificationPage.NextCursor,
+		HasMore:    notificationPage.HasMore,
+	})
+}
+
+func (c *NotificationControllerImpl) HandleReadNotification(w http.ResponseWriter, r *http.Request) {
+	userId, ok := r.Context().Value(middlewares.ContextUserIdKey).(string)
+	if !ok {
+		httphelper.ErrorResponse(w, http.StatusUnauthorized, autherror.ErrUserIdNotFoundInTheContext)
+		return
+	}
+
+	notificationId := chi.URLParam(r, "id")
+	err := c.NotificationService.ReadNotification(r.Context(), userId, notificationId)
+	if errors.Is(err, notificationerror.ErrNotificationIdNotFound) {
+		httphelper.ErrorResponse(w, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		httphelper.ErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	httphelper.SuccessResponse(w, http.StatusOK, "successfully read notification", nil)
+}
+
+func (c *NotificationControllerImpl) HandleReadAllNotifications(w http.ResponseWriter, r *http.Request) {
+	userId, ok := r.Context().Value(middlewares.ContextUserIdKey).(string)
+	if !ok {
+		httphelper.ErrorResponse(w, http.StatusUnauthorized, autherror.ErrUserIdNotFoundInTheContext)
+		return
+	}
+
+	err := c.NotificationService.ReadAllNotifications(r.Context(), userId)
+	if err != nil {
+		httphelper.ErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	httphelper.SuccessResponse(w, http.StatusOK, "successfully read all notifications", nil)
+}
+
+func (c *NotificationControllerImpl) HandleCreateStreamTicket(w http.ResponseWriter, r *http.Request) {
+	userId, ok := r.Context().Value(middlewares.ContextUserIdKey).(string)
+	if !ok {
+		httphelper.ErrorResponse(w, http.StatusUnauthorized, autherror.ErrUserIdNotFoundInTheContext)
+		return
+	}
+
+	sessionId, ok := r.Context().Value(middlewares.ContextSessionIdKey).(string)
+	if !ok {
+		httphelper.ErrorResponse(w, http.StatusUnauthorized, autherror.ErrSessionIdNotFoundInTheContext)
+		return
+	}
+
+	streamTicket, err := c.NotificationService.CreateStreamTicket(r.Context(), userId, sessionId)
+	if err != nil {
+		httphelper.ErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	httphelper.SuccessResponse(w, http.StatusCreated, "successfully create stream ticket", streamTicket)
+}
+
+// HandleStreamNotifications streams the notifications of the user as
+// Server-Sent Events. A client reconnecting with Last-Event-ID first gets
+// the notifications it missed.
+func (c *NotificationControllerImpl) HandleStreamNotifications(w http.ResponseWriter, r *http.Request) {
+	userId, ok := r.Context().Value(middlewares.ContextUserIdKey).(string)
+	if !ok {
+		httphelper.ErrorResponse(w, http.StatusUnauthorized, autherror.ErrUserIdNotFoundInTheContext)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		httphelper.ErrorResponse(w, http.StatusInternalServerError, notificationerror.ErrStreamingUnsupported)
+		return
+	}
+
+	// subscribe before the replay so nothing created in between is lost
+	notifications, unsubscribe := c.NotificationService.Subscribe(userId)
+	defer unsubscribe()
+
+	replayed := map[string]bool{}
+	var missed []*notificationentity.GetNotificationResponse
+	if lastEventId := r.Header.Get("Last-Event-ID"); lastEventId != "" {
+		var err error
+		missed, err = c.NotificationService.GetNotificationsAfter(r.Context(), userId, lastEventId)
+		if err != nil {
+			httphelper.ErrorResponse(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	for _, notification := range missed {
+		if err := writeEvent(w, notification); err != nil {
+			return
+		}
+		replayed[notification.Id] = true
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case notification := <-notifications:
+			if replayed[notification.Id] {
+				continue
+			}
+			if err := writeEvent(w, notification); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-heartbeat.C:
+			if err := c.checkStreamAccess(r.Context(), userId); err != nil {
+				return
+			}
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// HandleNotificationsWebSocket streams the notifications of the user over a
+// WebSocket, one JSON text message per notification.
+func (c *NotificationControllerImpl) HandleNotificationsWebSocket(w http.ResponseWriter, r *http.Request) {
+	userId, ok := r.Context().Value(middlewares.ContextUserIdKey).(string)
+	if !ok {
+		httphelper.ErrorResponse(w, http.StatusUnauthorized, autherror.ErrUserIdNotFoundInTheContext)
+		return
+	}
+
+	server := websocket.Server{
+		// a stream ticket in the query string, unlike a bearer token, could
+		// be replayed by another site, so browsers must come from an allowed
+		// origin
+		Handshake: func(config *websocket.Config, r *http.Request) error {
+			if !isAllowedOrigin(config.Origin, r) {
+				return notificationerror.ErrOriginNotAllowed
+			}
+			return nil
+		},
+		Handler: func(ws *websocket.Conn) {
+			c.streamWebSocket(ws, userId)
+		},
+	}
+	server.ServeHTTP(w, r)
+}
+
+func (c *NotificationControllerImpl) streamWebSocket(ws *websocket.Conn, userId string) {
+	notifications, unsubscribe := c.NotificationService.Subscribe(userId)
+	defer unsubscribe()
+
+	// the client isn't expected to send anything, reading only tells when it
+	// has gone away
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			var message string
+			if err := websocket.Message.Receive(ws, &message); err != nil {
+				return
+			}
+		}
+	}()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-closed:
+			return
+		case notification := <-notifications:
+			if err := websocket.JSON.Send(ws, notification); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if err := c.checkStreamAccess(ws.Request().Context(), userId); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// checkStreamAccess closes streams of users who logged out, revoked the API
+// key of the stream or got suspended since the stream was opened.
+func (c *NotificationControllerImpl) checkStreamAccess(ctx context.Context, userId string) error {
+	sessionId, _ := ctx.Value(middlewares.ContextSessionIdKey).(string)
+	apiKeyId, _ := ctx.Value(middlewares.ContextApiKeyIdKey).(string)
+	return c.NotificationService.CheckStreamAccess(ctx, userId, sessionId, apiKeyId)
+}
+
+// isAllowedOrigin accepts the host the request was sent to, the origin of
+// APP_URL and the origins listed in WS_ALLOWED_ORIGINS. Clients that aren't
+// browsers usually send no origin at all and are let through.
+func isAllowedOrigin(origin *url.URL, r *http.Request) bool {
+	if origin == nil || origin.Host == r.Host {
+		return true
+	}
+
+	allowedOrigins := strings.Split(os.Getenv("WS_ALLOWED_ORIGINS"), ",")
+	allowedOrigins = append(allowedOrigins, os.Getenv("APP_URL"))
+	for _, allowedOrigin := range allowedOrigins {
+		allowed, err := url.Parse(strings.TrimSpace(allowedOrigin))
+		if err != nil || allowed.Host == "" {
+			continue
+		}
+		if allowed.Scheme == origin.Scheme && allowed.Host == origin.Host {
+			return true
+		}
+	}
+	return false
+}
+
+func writeEvent(w http.ResponseWriter, notification *notificationentity.GetNotificationResponse) error {
+	data, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", notification.Id, notification.Type, data)
+	return err
+}
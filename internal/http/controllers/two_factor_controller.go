@@ -0,0 +1,127 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/danzBraham/cats-social/internal/entities/userentity"
+	"github.com/danzBraham/cats-social/internal/errors/autherror"
+	"github.com/danzBraham/cats-social/internal/errors/usererror"
+	"github.com/danzBraham/cats-social/internal/helpers/httphelper"
+	"github.com/danzBraham/cats-social/internal/http/middlewares"
+	"github.com/danzBraham/cats-social/internal/services"
+)
+
+type TwoFactorController interface {
+	HandleSetupTwoFactor(w http.ResponseWriter, r *http.Request)
+	HandleEnableTwoFactor(w http.ResponseWriter, r *http.Request)
+	HandleDisableTwoFactor(w http.ResponseWriter, r *http.Request)
+}
+
+type TwoFactorControllerImpl struct {
+	TwoFactorService services.TwoFactorService
+}
+
+func NewTwoFactorController(twoFactorService services.TwoFactorService) TwoFactorController {
+	return &TwoFactorControllerImpl{TwoFactorService: twoFactorService}
+}
+
+func (c *TwoFactorControllerImpl) HandleSetupTwoFactor(w http.ResponseWriter, r *http.Request) {
+	userId, ok := r.Context().Value(middlewares.ContextUserIdKey).(string)
+	if !ok {
+		httphelper.ErrorResponse(w, http.StatusUnauthorized, autherror.ErrUserIdNotFoundInTheContext)
+		return
+	}
+
+	setupResponse, err := c.TwoFactorService.SetupTwoFactor(r.Context(), userId)
+	if errors.Is(err, usererror.ErrUserNotFound) {
+		httphelper.ErrorResponse(w, http.StatusNotFound, err)
+		return
+	}
+	if errors.Is(err, usererror.ErrTwoFactorAlreadyEnabled) {
+		httphelper.ErrorResponse(w, http.StatusConflict, err)
+		return
+	}
+	if err != nil {
+		httphelper.ErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	httphelper.SuccessResponse(w, http.StatusOK, "scan the provisioning uri with an authenticator app", setupResponse)
+}
+
+func (c *TwoFactorControllerImpl) HandleEnableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	userId, ok := r.Context().Value(middlewares.ContextUserIdKey).(string)
+	if !ok {
+		httphelper.ErrorResponse(w, http.StatusUnauthorized, autherror.ErrUserIdNotFoundInTheContext)
+		return
+	}
+
+	payload := &userentity.EnableTwoFactorRequest{}
+	err := httphelper.DecodeAndValidate(w, r, payload)
+	if err != nil {
+		return
+	}
+
+	enableResponse, err := c.TwoFactorService.EnableTwoFactor(r.Context(), userId, payload)
+	if errors.Is(err, usererror.ErrUserNotFound) {
+		httphelper.ErrorResponse(w, http.StatusNotFound, err)
+		return
+	}
+	if errors.Is(err, usererror.ErrTwoFactorAlreadyEnabled) {
+		httphelper.ErrorResponse(w, http.StatusConflict, err)
+		return
+	}
+	if errors.Is(err, usererror.ErrTwoFactorNotSetUp) {
+		httphelper.ErrorResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	if errors.Is(err, usererror.ErrInvalidTwoFactorCode) {
+		httphelper.ErrorResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	if err != nil {
+		httphelper.ErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	httphelper.SuccessResponse(w, http.StatusOK, "successfully enable two-factor authentication", enableResponse)
+}
+
+func (c *TwoFactorControllerImpl) HandleDisableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	userId, ok := r.Context().Value(middlewares.ContextUserIdKey).(string)
+	if !ok {
+		httphelper.ErrorResponse(w, http.StatusUnauthorized, autherror.ErrUserIdNotFoundInTheContext)
+		return
+	}
+
+	payload := &userentity.DisableTwoFactorRequest{}
+	err := httphelper.DecodeAndValidate(w, r, payload)
+	if err != nil {
+		return
+	}
+
+	err = c.TwoFactorService.DisableTwoFactor(r.Context(), userId, payload)
+	if errors.Is(err, usererror.ErrUserNotFound) {
+		httphelper.ErrorResponse(w, http.StatusNotFound, err)
+		return
+	}
+	if errors.Is(err, usererror.ErrTwoFactorNotEnabled) {
+		httphelper.ErrorResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	if errors.Is(err, usererror.ErrInvalidPassword) {
+		httphelper.ErrorResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	if errors.Is(err, usererror.ErrInvalidTwoFactorCode) {
+		httphelper.ErrorResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	if err != nil {
+		httphelper.ErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	httphelper.SuccessResponse(w, http.StatusOK, "successfully disable two-factor authentication", nil)
+}
@@ -2,18 +2,36 @@ package controllers
 
 import (
 	"errors"
+	"math"
 	"net/http"
+	"strconv"
 	"time"
 
+	"github.com/danzBraham/cats-social/internal/entities/sessionentity"
 	"github.com/danzBraham/cats-social/internal/entities/userentity"
+	"github.com/danzBraham/cats-social/internal/errors/autherror"
 	"github.com/danzBraham/cats-social/internal/errors/usererror"
 	"github.com/danzBraham/cats-social/internal/helpers/httphelper"
+	"github.com/danzBraham/cats-social/internal/helpers/jwt"
+	"github.com/danzBraham/cats-social/internal/http/middlewares"
 	"github.com/danzBraham/cats-social/internal/services"
 )
 
 type UserController interface {
 	HandleRegisterUser(w http.ResponseWriter, r *http.Request)
 	HandleLoginUser(w http.ResponseWriter, r *http.Request)
+	HandleLoginTwoFactor(w http.ResponseWriter, r *http.Request)
+	HandleRefreshToken(w http.ResponseWriter, r *http.Request)
+	HandleLogoutUser(w http.ResponseWriter, r *http.Request)
+	HandleGetCurrentUser(w http.ResponseWriter, r *http.Request)
+	HandleUpdateCurrentUser(w http.ResponseWriter, r *http.Request)
+	HandleDeleteCurrentUser(w http.ResponseWriter, r *http.Request)
+	HandleChangePassword(w http.ResponseWriter, r *http.Request)
+	HandleForgotPassword(w http.ResponseWriter, r *http.Request)
+	HandleResetPassword(w http.ResponseWriter, r *http.Request)
+	HandleVerifyEmail(w http.ResponseWriter, r *http.Request)
+	HandleResendVerificationEmail(w http.ResponseWriter, r *http.Request)
+	HandleGetJWKS(w http.ResponseWriter, r *http.Request)
 }
 
 type UserControllerImpl struct {
@@ -44,7 +62,7 @@ func (c *UserControllerImpl) HandleRegisterUser(w http.ResponseWriter, r *http.R
 	cookie := &http.Cookie{
 		Name:    "Authorization",
 		Value:   userResponse.AccessToken,
-		Expires: time.Now().Add(8 * time.Hour),
+		Expires: time.Now().Add(services.AccessTokenTTL),
 	}
 	http.SetCookie(w, cookie)
 
@@ -58,13 +76,19 @@ func (c *UserControllerImpl) HandleLoginUser(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	userResponse, err := c.UserService.LoginUser(r.Context(), payload)
-	if errors.Is(err, usererror.ErrUserNotFound) {
-		httphelper.ErrorResponse(w, http.StatusNotFound, err)
+	userResponse, err := c.UserService.LoginUser(r.Context(), payload, httphelper.ClientIP(r))
+	if errors.Is(err, usererror.ErrInvalidCredentials) {
+		httphelper.ErrorResponse(w, http.StatusBadRequest, err)
 		return
 	}
-	if errors.Is(err, usererror.ErrInvalidPassword) {
-		httphelper.ErrorResponse(w, http.StatusBadRequest, err)
+	if errors.Is(err, usererror.ErrUserSuspended) {
+		httphelper.ErrorResponse(w, http.StatusForbidden, err)
+		return
+	}
+	var lockedErr *usererror.LoginLockedError
+	if errors.As(err, &lockedErr) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(lockedErr.RetryAfter.Seconds()))))
+		httphelper.ErrorResponse(w, http.StatusTooManyRequests, err)
 		return
 	}
 	if err != nil {
@@ -72,12 +96,320 @@ func (c *UserControllerImpl) HandleLoginUser(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	if userResponse.TwoFactorRequired {
+		httphelper.SuccessResponse(w, http.StatusOK, "Two-factor authentication required", userResponse)
+		return
+	}
+
 	cookie := &http.Cookie{
 		Name:    "Authorizaiton",
 		Value:   userResponse.AccessToken,
-		Expires: time.Now().Add(8 * time.Hour),
+		Expires: time.Now().Add(services.AccessTokenTTL),
 	}
 	http.SetCookie(w, cookie)
 
 	httphelper.SuccessResponse(w, http.StatusOK, "User logged successfully", userResponse)
 }
+
+func (c *UserControllerImpl) HandleLoginTwoFactor(w http.ResponseWriter, r *http.Request) {
+	payload := &userentity.LoginTwoFactorRequest{}
+	err := httphelper.DecodeAndValidate(w, r, payload)
+	if err != nil {
+		return
+	}
+
+	userResponse, err := c.UserService.LoginTwoFactor(r.Context(), payload, httphelper.ClientIP(r))
+	if errors.Is(err, usererror.ErrInvalidChallengeToken) {
+		httphelper.ErrorResponse(w, http.StatusUnauthorized, err)
+		return
+	}
+	if errors.Is(err, usererror.ErrInvalidTwoFactorCode) {
+		httphelper.ErrorResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	if errors.Is(err, usererror.ErrUserSuspended) {
+		httphelper.ErrorResponse(w, http.StatusForbidden, err)
+		return
+	}
+	var lockedErr *usererror.LoginLockedError
+	if errors.As(err, &lockedErr) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(lockedErr.RetryAfter.Seconds()))))
+		httphelper.ErrorResponse(w, http.StatusTooManyRequests, err)
+		return
+	}
+	if err != nil {
+		httphelper.ErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	cookie := &http.Cookie{
+		Name:    "Authorization",
+		Value:   userResponse.AccessToken,
+		Expires: time.Now().Add(services.AccessTokenTTL),
+	}
+	http.SetCookie(w, cookie)
+
+	httphelper.SuccessResponse(w, http.StatusOK, "User logged successfully", userResponse)
+}
+
+func (c *UserControllerImpl) HandleRefreshToken(w http.ResponseWriter, r *http.Request) {
+	payload := &sessionentity.RefreshTokenRequest{}
+	err := httphelper.DecodeAndValidate(w, r, payload)
+	if err != nil {
+		return
+	}
+
+	tokenResponse, err := c.UserService.RefreshToken(r.Context(), payload)
+	if errors.Is(err, autherror.ErrInvalidRefreshToken) {
+		httphelper.ErrorResponse(w, http.StatusUnauthorized, err)
+		return
+	}
+	if errors.Is(err, usererror.ErrUserNotFound) {
+		httphelper.ErrorResponse(w, http.StatusUnauthorized, err)
+		return
+	}
+	if errors.Is(err, usererror.ErrUserSuspended) {
+		httphelper.ErrorResponse(w, http.StatusForbidden, err)
+		return
+	}
+	if err != nil {
+		httphelper.ErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	cookie := &http.Cookie{
+		Name:    "Authorization",
+		Value:   tokenResponse.AccessToken,
+		Expires: time.Now().Add(services.AccessTokenTTL),
+	}
+	http.SetCookie(w, cookie)
+
+	httphelper.SuccessResponse(w, http.StatusOK, "Token refreshed successfully", tokenResponse)
+}
+
+func (c *UserControllerImpl) HandleLogoutUser(w http.ResponseWriter, r *http.Request) {
+	sessionId, ok := r.Context().Value(middlewares.ContextSessionIdKey).(string)
+	if !ok {
+		httphelper.ErrorResponse(w, http.StatusUnauthorized, autherror.ErrSessionIdNotFoundInTheContext)
+		return
+	}
+
+	err := c.UserService.LogoutUser(r.Context(), sessionId)
+	if err != nil {
+		httphelper.ErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:    "Authorization",
+		Value:   "",
+		Expires: time.Unix(0, 0),
+	})
+
+	httphelper.SuccessResponse(w, http.StatusOK, "User logged out successfully", nil)
+}
+
+func (c *UserControllerImpl) HandleGetCurrentUser(w http.ResponseWriter, r *http.Request) {
+	userId, ok := r.Context().Value(middlewares.ContextUserIdKey).(string)
+	if !ok {
+		httphelper.ErrorResponse(w, http.StatusUnauthorized, autherror.ErrUserIdNotFoundInTheContext)
+		return
+	}
+
+	userResponse, err := c.UserService.GetCurrentUser(r.Context(), userId)
+	if errors.Is(err, usererror.ErrUserNotFound) {
+		httphelper.ErrorResponse(w, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		httphelper.ErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	httphelper.SuccessResponse(w, http.StatusOK, "success", userResponse)
+}
+
+func (c *UserControllerImpl) HandleUpdateCurrentUser(w http.ResponseWriter, r *http.Request) {
+	userId, ok := r.Context().Value(middlewares.ContextUserIdKey).(string)
+	if !ok {
+		httphelper.ErrorResponse(w, http.StatusUnauthorized, autherror.ErrUserIdNotFoundInTheContext)
+		return
+	}
+
+	payload := &userentity.UpdateUserRequest{}
+	err := httphelper.DecodeAndValidate(w, r, payload)
+	if err != nil {
+		return
+	}
+
+	userResponse, err := c.UserService.UpdateCurrentUser(r.Context(), userId, payload)
+	if errors.Is(err, usererror.ErrUserNotFound) {
+		httphelper.ErrorResponse(w, http.StatusNotFound, err)
+		return
+	}
+	if errors.Is(err, usererror.ErrEmailAlreadyExists) {
+		httphelper.ErrorResponse(w, http.StatusConflict, err)
+		return
+	}
+	if err != nil {
+		httphelper.ErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	httphelper.SuccessResponse(w, http.StatusOK, "successfully update user", userResponse)
+}
+
+func (c *UserControllerImpl) HandleDeleteCurrentUser(w http.ResponseWriter, r *http.Request) {
+	userId, ok := r.Context().Value(middlewares.ContextUserIdKey).(string)
+	if !ok {
+		httphelper.ErrorResponse(w, http.StatusUnauthorized, autherror.ErrUserIdNotFoundInTheContext)
+		return
+	}
+
+	err := c.UserService.DeleteCurrentUser(r.Context(), userId)
+	if errors.Is(err, usererror.ErrUserNotFound) {
+		httphelper.ErrorResponse(w, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		httphelper.ErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:    "Authorization",
+		Value:   "",
+		Expires: time.Unix(0, 0),
+	})
+
+	httphelper.SuccessResponse(w, http.StatusOK, "successfully delete user", nil)
+}
+
+func (c *UserControllerImpl) HandleChangePassword(w http.ResponseWriter, r *http.Request) {
+	userId, ok := r.Context().Value(middlewares.ContextUserIdKey).(string)
+	if !ok {
+		httphelper.ErrorResponse(w, http.StatusUnauthorized, autherror.ErrUserIdNotFoundInTheContext)
+		return
+	}
+
+	sessionId, ok := r.Context().Value(middlewares.ContextSessionIdKey).(string)
+	if !ok {
+		httphelper.ErrorResponse(w, http.StatusUnauthorized, autherror.ErrSessionIdNotFoundInTheContext)
+		return
+	}
+
+	payload := &userentity.ChangePasswordRequest{}
+	err := httphelper.DecodeAndValidate(w, r, payload)
+	if err != nil {
+		return
+	}
+
+	err = c.UserService.ChangePassword(r.Context(), userId, sessionId, payload)
+	if errors.Is(err, usererror.ErrUserNotFound) {
+		httphelper.ErrorResponse(w, http.StatusNotFound, err)
+		return
+	}
+	if errors.Is(err, usererror.ErrInvalidPassword) {
+		httphelper.ErrorResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	if err != nil {
+		httphelper.ErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	httphelper.SuccessResponse(w, http.StatusOK, "successfully change password", nil)
+}
+
+func (c *UserControllerImpl) HandleForgotPassword(w http.ResponseWriter, r *http.Request) {
+	payload := &userentity.ForgotPasswordRequest{}
+	err := httphelper.DecodeAndValidate(w, r, payload)
+	if err != nil {
+		return
+	}
+
+	err = c.UserService.ForgotPassword(r.Context(), payload)
+	if err != nil {
+		httphelper.ErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	httphelper.SuccessResponse(w, http.StatusOK, "if the email is registered, a password reset link has been sent", nil)
+}
+
+func (c *UserControllerImpl) HandleResetPassword(w http.ResponseWriter, r *http.Request) {
+	payload := &userentity.ResetPasswordRequest{}
+	err := httphelper.DecodeAndValidate(w, r, payload)
+	if err != nil {
+		return
+	}
+
+	err = c.UserService.ResetPassword(r.Context(), payload)
+	if errors.Is(err, usererror.ErrInvalidResetToken) {
+		httphelper.ErrorResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	if err != nil {
+		httphelper.ErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	httphelper.SuccessResponse(w, http.StatusOK, "successfully reset password", nil)
+}
+
+func (c *UserControllerImpl) HandleVerifyEmail(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		httphelper.ErrorResponse(w, http.StatusBadRequest, usererror.ErrInvalidVerificationToken)
+		return
+	}
+
+	err := c.UserService.VerifyEmail(r.Context(), token)
+	if errors.Is(err, usererror.ErrInvalidVerificationToken) {
+		httphelper.ErrorResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	if err != nil {
+		httphelper.ErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	httphelper.SuccessResponse(w, http.StatusOK, "successfully verify email", nil)
+}
+
+func (c *UserControllerImpl) HandleResendVerificationEmail(w http.ResponseWriter, r *http.Request) {
+	userId, ok := r.Context().Value(middlewares.ContextUserIdKey).(string)
+	if !ok {
+		httphelper.ErrorResponse(w, http.StatusUnauthorized, autherror.ErrUserIdNotFoundInTheContext)
+		return
+	}
+
+	err := c.UserService.ResendVerificationEmail(r.Context(), userId)
+	if errors.Is(err, usererror.ErrUserNotFound) {
+		httphelper.ErrorResponse(w, http.StatusNotFound, err)
+		return
+	}
+	if errors.Is(err, usererror.ErrEmailAlreadyVerified) {
+		httphelper.ErrorResponse(w, http.StatusConflict, err)
+		return
+	}
+	if err != nil {
+		httphelper.ErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	httphelper.SuccessResponse(w, http.StatusOK, "verification email sent", nil)
+}
+
+// HandleGetJWKS publishes the public keys access tokens are signed with.
+// The body is the bare key set, as JWKS clients expect.
+func (c *UserControllerImpl) HandleGetJWKS(w http.ResponseWriter, r *http.Request) {
+	jwks, err := jwt.JWKS()
+	if err != nil {
+		httphelper.ErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	httphelper.EncodeJSON(w, http.StatusOK, jwks)
+}
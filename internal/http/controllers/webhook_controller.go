@@ -0,0 +1,172 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/danzBraham/cats-social/internal/entities/userentity"
+	"github.com/danzBraham/cats-social/internal/entities/webhookentity"
+	"github.com/danzBraham/cats-social/internal/errors/autherror"
+	"github.com/danzBraham/cats-social/internal/errors/webhookerror"
+	"github.com/danzBraham/cats-social/internal/helpers/cursor"
+	"github.com/danzBraham/cats-social/internal/helpers/httphelper"
+	"github.com/danzBraham/cats-social/internal/http/middlewares"
+	"github.com/danzBraham/cats-social/internal/services"
+	"github.com/go-chi/chi/v5"
+)
+
+type WebhookController interface {
+	HandleCreateWebhook(w http.ResponseWriter, r *http.Request)
+	HandleGetWebhooks(w http.ResponseWriter, r *http.Request)
+	HandleDeleteWebhook(w http.ResponseWriter, r *http.Request)
+	HandleGetDeliveries(w http.ResponseWriter, r *http.Request)
+	HandleRedeliver(w http.ResponseWriter, r *http.Request)
+}
+
+type WebhookControllerImpl struct {
+	WebhookService services.WebhookService
+}
+
+func NewWebhookController(webhookService services.WebhookService) WebhookController {
+	return &WebhookControllerImpl{WebhookService: webhookService}
+}
+
+func (c *WebhookControllerImpl) HandleCreateWebhook(w http.ResponseWriter, r *http.Request) {
+	userId, ok := r.Context().Value(middlewares.ContextUserIdKey).(string)
+	if !ok {
+		httphelper.ErrorResponse(w, http.StatusUnauthorized, autherror.ErrUserIdNotFoundInTheContext)
+		return
+	}
+	role, _ := r.Context().Value(middlewares.ContextUserRoleKey).(userentity.Role)
+
+	payload := &webhookentity.CreateWebhookRequest{}
+	err := httphelper.DecodeAndValidate(w, r, payload)
+	if err != nil {
+		return
+	}
+
+	webhookResponse, err := c.WebhookService.CreateWebhook(r.Context(), userId, role, payload)
+	if errors.Is(err, webhookerror.ErrGlobalWebhookForbidden) {
+		httphelper.ErrorResponse(w, http.StatusForbidden, err)
+		return
+	}
+	if err != nil {
+		httphelper.ErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	httphelper.SuccessResponse(w, http.StatusCreated, "successfully create webhook", webhookResponse)
+}
+
+func (c *WebhookControllerImpl) HandleGetWebhooks(w http.ResponseWriter, r *http.Request) {
+	userId, ok := r.Context().Value(middlewares.ContextUserIdKey).(string)
+	if !ok {
+		httphelper.ErrorResponse(w, http.StatusUnauthorized, autherror.ErrUserIdNotFoundInTheContext)
+		return
+	}
+
+	webhookResponses, err := c.WebhookService.GetWebhooks(r.Context(), userId)
+	if err != nil {
+		httphelper.ErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	httphelper.SuccessResponse(w, http.StatusOK, "success", webhookResponses)
+}
+
+func (c *WebhookControllerImpl) HandleDeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	userId, ok := r.Context().Value(middlewares.ContextUserIdKey).(string)
+	if !ok {
+		httphelper.ErrorResponse(w, http.StatusUnauthorized, autherror.ErrUserIdNotFoundInTheContext)
+		return
+	}
+
+	webhookId := chi.URLParam(r, "id")
+	err := c.WebhookService.DeleteWebhook(r.Context(), userId, webhookId)
+	if errors.Is(err, webhookerror.ErrWebhookIdNotFound) {
+		httphelper.ErrorResponse(w, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		httphelper.ErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	httphelper.SuccessResponse(w, http.StatusOK, "successfully delete webhook", nil)
+}
+
+func (c *WebhookControllerImpl) HandleGetDeliveries(w http.ResponseWriter, r *http.Request) {
+	userId, ok := r.Context().Value(middlewares.ContextUserIdKey).(string)
+	if !ok {
+		httphelper.ErrorResponse(w, http.StatusUnauthorized, autherror.ErrUserIdNotFoundInTheContext)
+		return
+	}
+
+	query := r.URL.Query()
+	params := &webhookentity.DeliveryQueryParams{Limit: 20}
+
+	if limit := query.Get("limit"); limit != "" {
+		var err error
+		params.Limit, err = strconv.Atoi(limit)
+		if err != nil || params.Limit < 1 {
+			httphelper.ErrorResponse(w, http.StatusBadRequest, fmt.Errorf("%w: limit must be a positive number", webhookerror.ErrInvalidWebhookQuery))
+			return
+		}
+	}
+
+	if after := query.Get("cursor"); after != "" {
+		params.Cursor = &webhookentity.DeliveryCursor{}
+		err := cursor.Decode(after, &params.Cursor.CreatedAt, &params.Cursor.Id)
+		if err == nil && params.Cursor.Id == "" {
+			err = cursor.ErrInvalidCursor
+		}
+		if err != nil {
+			httphelper.ErrorResponse(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	webhookId := chi.URLParam(r, "id")
+	deliveryPage, err := c.WebhookService.GetDeliveries(r.Context(), userId, webhookId, params)
+	if errors.Is(err, webhookerror.ErrWebhookIdNotFound) {
+		httphelper.ErrorResponse(w, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		httphelper.ErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	httphelper.SuccessResponseWithMeta(w, http.StatusOK, "successfully get webhook deliveries", deliveryPage.Deliveries, &httphelper.Meta{
+		NextCursor: deliveryPage.NextCursor,
+		HasMore:    deliveryPage.HasMore,
+	})
+}
+
+func (c *WebhookControllerImpl) HandleRedeliver(w http.ResponseWriter, r *http.Request) {
+	userId, ok := r.Context().Value(middlewares.ContextUserIdKey).(string)
+	if !ok {
+		httphelper.ErrorResponse(w, http.StatusUnauthorized, autherror.ErrUserIdNotFoundInTheContext)
+		return
+	}
+
+	webhookId := chi.URLParam(r, "id")
+	deliveryId := chi.URLParam(r, "deliveryId")
+	deliveryResponse, err := c.WebhookService.Redeliver(r.Context(), userId, webhookId, deliveryId)
+	if errors.Is(err, webhookerror.ErrWebhookIdNotFound) {
+		httphelper.ErrorResponse(w, http.StatusNotFound, err)
+		return
+	}
+	if errors.Is(err, webhookerror.ErrDeliveryIdNotFound) {
+		httphelper.ErrorResponse(w, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		httphelper.ErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	httphelper.SuccessResponse(w, http.StatusAccepted, "successfully queue webhook redelivery", deliveryResponse)
+}
@@ -2,20 +2,60 @@ package middlewares
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"strings"
 
+	"github.com/danzBraham/cats-social/internal/entities/userentity"
 	"github.com/danzBraham/cats-social/internal/errors/autherror"
+	"github.com/danzBraham/cats-social/internal/errors/notificationerror"
+	"github.com/danzBraham/cats-social/internal/errors/usererror"
 	"github.com/danzBraham/cats-social/internal/helpers/httphelper"
 	"github.com/danzBraham/cats-social/internal/helpers/jwt"
+	"github.com/danzBraham/cats-social/internal/helpers/randtoken"
+	"github.com/danzBraham/cats-social/internal/repositories"
 )
 
 type ContextKey string
 
-var ContextUserIdKey ContextKey = "userId"
+var (
+	ContextUserIdKey    ContextKey = "userId"
+	ContextSessionIdKey ContextKey = "sessionId"
+	ContextUserRoleKey  ContextKey = "userRole"
+	// ContextApiKeyScopesKey is only set when the request was authenticated
+	// with an API key instead of an access token.
+	ContextApiKeyScopesKey ContextKey = "apiKeyScopes"
+	ContextApiKeyIdKey     ContextKey = "apiKeyId"
+)
+
+type AuthMiddleware struct {
+	SessionRepository      repositories.SessionRepository
+	UserRepository         repositories.UserRepository
+	ApiKeyRepository       repositories.ApiKeyRepository
+	StreamTicketRepository repositories.StreamTicketRepository
+}
+
+func NewAuthMiddleware(
+	sessionRepository repositories.SessionRepository,
+	userRepository repositories.UserRepository,
+	apiKeyRepository repositories.ApiKeyRepository,
+	streamTicketRepository repositories.StreamTicketRepository,
+) *AuthMiddleware {
+	return &AuthMiddleware{
+		SessionRepository:      sessionRepository,
+		UserRepository:         userRepository,
+		ApiKeyRepository:       apiKeyRepository,
+		StreamTicketRepository: streamTicketRepository,
+	}
+}
 
-func Auth(next http.Handler) http.Handler {
+func (m *AuthMiddleware) Auth(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if apiKeyHeader := r.Header.Get("X-API-Key"); apiKeyHeader != "" {
+			m.authApiKey(w, r, next, apiKeyHeader)
+			return
+		}
+
 		authHeader := r.Header.Get("Authorization")
 		if authHeader == "" {
 			httphelper.ErrorResponse(w, http.StatusUnauthorized, autherror.ErrMissingAuthHeader)
@@ -36,8 +76,100 @@ func Auth(next http.Handler) http.Handler {
 			return
 		}
 
-		ctx := context.WithValue(r.Context(), ContextUserIdKey, token.UserId)
+		m.authSession(w, r, next, token.UserId, token.SessionId)
+	})
+}
+
+// StreamAuth is Auth for the notification streams. EventSource and WebSocket
+// in a browser can't set headers, so a stream ticket in the query string is
+// accepted too. The ticket is used up by the request that carries it.
+func (m *AuthMiddleware) StreamAuth(next http.Handler) http.Handler {
+	auth := m.Auth(next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ticket := r.URL.Query().Get("ticket")
+		if ticket == "" {
+			auth.ServeHTTP(w, r)
+			return
+		}
 
-		next.ServeHTTP(w, r.WithContext(ctx))
+		streamTicket, err := m.StreamTicketRepository.ConsumeStreamTicket(r.Context(), randtoken.Hash(ticket))
+		if errors.Is(err, notificationerror.ErrInvalidStreamTicket) {
+			httphelper.ErrorResponse(w, http.StatusUnauthorized, err)
+			return
+		}
+		if err != nil {
+			httphelper.ErrorResponse(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		m.authSession(w, r, next, streamTicket.UserId, streamTicket.SessionId)
 	})
 }
+
+func (m *AuthMiddleware) authSession(w http.ResponseWriter, r *http.Request, next http.Handler, userId, sessionId string) {
+	isSessionActive, err := m.SessionRepository.IsSessionActive(r.Context(), sessionId)
+	if err != nil {
+		httphelper.ErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+	if !isSessionActive {
+		httphelper.ErrorResponse(w, http.StatusUnauthorized, autherror.ErrSessionRevoked)
+		return
+	}
+
+	role, ok := m.checkUser(w, r, userId)
+	if !ok {
+		return
+	}
+
+	// the role comes from the database, not the token, so a demotion
+	// takes effect right away
+	ctx := context.WithValue(r.Context(), ContextUserIdKey, userId)
+	ctx = context.WithValue(ctx, ContextSessionIdKey, sessionId)
+	ctx = context.WithValue(ctx, ContextUserRoleKey, role)
+
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
+func (m *AuthMiddleware) authApiKey(w http.ResponseWriter, r *http.Request, next http.Handler, key string) {
+	apiKey, err := m.ApiKeyRepository.UseApiKey(r.Context(), randtoken.Hash(key))
+	if errors.Is(err, autherror.ErrInvalidApiKey) {
+		httphelper.ErrorResponse(w, http.StatusUnauthorized, err)
+		return
+	}
+	if err != nil {
+		httphelper.ErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	role, ok := m.checkUser(w, r, apiKey.UserId)
+	if !ok {
+		return
+	}
+
+	ctx := context.WithValue(r.Context(), ContextUserIdKey, apiKey.UserId)
+	ctx = context.WithValue(ctx, ContextUserRoleKey, role)
+	ctx = context.WithValue(ctx, ContextApiKeyScopesKey, apiKey.Scopes)
+	ctx = context.WithValue(ctx, ContextApiKeyIdKey, apiKey.Id)
+
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// checkUser loads the current role of the user and rejects deleted and
+// suspended users. It writes the error response itself when it reports false.
+func (m *AuthMiddleware) checkUser(w http.ResponseWriter, r *http.Request, userId string) (userentity.Role, bool) {
+	access, err := m.UserRepository.GetUserAccess(r.Context(), userId)
+	if errors.Is(err, usererror.ErrUserNotFound) {
+		httphelper.ErrorResponse(w, http.StatusUnauthorized, err)
+		return "", false
+	}
+	if err != nil {
+		httphelper.ErrorResponse(w, http.StatusInternalServerError, err)
+		return "", false
+	}
+	if access.IsSuspended {
+		httphelper.ErrorResponse(w, http.StatusForbidden, usererror.ErrUserSuspended)
+		return "", false
+	}
+	return access.Role, true
+}
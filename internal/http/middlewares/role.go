@@ -0,0 +1,26 @@
+package middlewares
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/danzBraham/cats-social/internal/entities/userentity"
+	"github.com/danzBraham/cats-social/internal/errors/autherror"
+	"github.com/danzBraham/cats-social/internal/helpers/httphelper"
+)
+
+// RequireRole only lets requests through when the authenticated user has one
+// of the given roles. It must run after Auth.
+func RequireRole(roles ...userentity.Role) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			role, ok := r.Context().Value(ContextUserRoleKey).(userentity.Role)
+			if !ok || !slices.Contains(roles, role) {
+				httphelper.ErrorResponse(w, http.StatusForbidden, autherror.ErrForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
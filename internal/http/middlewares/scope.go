@@ -0,0 +1,49 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+	"slices"
+
+	"github.com/danzBraham/cats-social/internal/entities/apikeyentity"
+	"github.com/danzBraham/cats-social/internal/errors/autherror"
+	"github.com/danzBraham/cats-social/internal/helpers/httphelper"
+)
+
+// RequireScope only lets API key requests through when the key was granted
+// the given scope. Requests authenticated with an access token are not
+// restricted. It must run after Auth.
+func RequireScope(scope apikeyentity.Scope) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !HasScope(r.Context(), scope) {
+				httphelper.ErrorResponse(w, http.StatusForbidden, autherror.ErrInsufficientScope)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// HasScope tells whether the request may do what the scope allows, for
+// handlers whose side effects need more than the scope of their route.
+// Requests authenticated with an access token have every scope.
+func HasScope(ctx context.Context, scope apikeyentity.Scope) bool {
+	scopes, ok := ctx.Value(ContextApiKeyScopesKey).([]apikeyentity.Scope)
+	return !ok || slices.Contains(scopes, scope)
+}
+
+// RequireSession rejects requests authenticated with an API key, so account
+// and admin endpoints can only be reached with an access token. It must run
+// after Auth.
+func RequireSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Value(ContextApiKeyScopesKey).([]apikeyentity.Scope); ok {
+			httphelper.ErrorResponse(w, http.StatusForbidden, autherror.ErrApiKeyNotAllowed)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -2,13 +2,17 @@ package http
 
 import (
 	"net/http"
+	"os"
+	"strconv"
 
+	"github.com/danzBraham/cats-social/internal/blobstore"
+	"github.com/danzBraham/cats-social/internal/entities/apikeyentity"
+	"github.com/danzBraham/cats-social/internal/entities/userentity"
 	"github.com/danzBraham/cats-social/internal/errors/commonerror"
 	"github.com/danzBraham/cats-social/internal/helpers/httphelper"
 	"github.com/danzBraham/cats-social/internal/http/controllers"
 	"github.com/danzBraham/cats-social/internal/http/middlewares"
 	"github.com/danzBraham/cats-social/internal/repositories"
-	"github.com/danzBraham/cats-social/internal/services"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 )
@@ -16,6 +20,9 @@ import (
 func (s *Server) RegisterRoutes() http.Handler {
 	r := chi.NewRouter()
 
+	if trustProxy, _ := strconv.ParseBool(os.Getenv("TRUST_PROXY")); trustProxy {
+		r.Use(middleware.RealIP)
+	}
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
 
@@ -25,42 +32,131 @@ func (s *Server) RegisterRoutes() http.Handler {
 		})
 	})
 
-	// repositories
-	userRepository := repositories.NewUserRepository(s.DB)
-	catRepository := repositories.NewCatRepository(s.DB)
-	matchRepository := repositories.NewMatchRepository(s.DB)
+	// images uploaded to the local blob store are served by the api itself
+	if handler, ok := s.BlobStore.(http.Handler); ok {
+		r.Handle(blobstore.LocalRoutePrefix+"/*", http.StripPrefix(blobstore.LocalRoutePrefix, handler))
+	}
 
-	// services
-	userService := services.NewUserService(userRepository)
-	catService := services.NewCatService(catRepository, matchRepository)
-	matchService := services.NewMatchService(matchRepository, catRepository, userRepository)
+	// middlewares
+	authMiddleware := middlewares.NewAuthMiddleware(
+		repositories.NewSessionRepository(s.DB),
+		repositories.NewUserRepository(s.DB),
+		repositories.NewApiKeyRepository(s.DB),
+		repositories.NewStreamTicketRepository(s.DB),
+	)
 
 	// controllers
-	userController := controllers.NewUserController(userService)
-	catController := controllers.NewCatController(catService)
-	matchController := controllers.NewMatchController(matchService)
+	userController := controllers.NewUserController(s.Services.User)
+	twoFactorController := controllers.NewTwoFactorController(s.Services.TwoFactor)
+	catController := controllers.NewCatController(s.Services.Cat)
+	matchController := controllers.NewMatchController(s.Services.Match)
+	adminController := controllers.NewAdminController(s.Services.Admin)
+	apiKeyController := controllers.NewApiKeyController(s.Services.ApiKey)
+	messageController := controllers.NewMessageController(s.Services.Message)
+	notificationController := controllers.NewNotificationController(s.Services.Notification)
+	webhookController := controllers.NewWebhookController(s.Services.Webhook)
+
+	r.Get("/.well-known/jwks.json", userController.HandleGetJWKS)
 
 	r.Route("/v1", func(r chi.Router) {
 		r.Route("/user", func(r chi.Router) {
 			r.Post("/register", userController.HandleRegisterUser)
 			r.Post("/login", userController.HandleLoginUser)
+			r.Post("/login/2fa", userController.HandleLoginTwoFactor)
+			r.Post("/token/refresh", userController.HandleRefreshToken)
+			r.Post("/password/forgot", userController.HandleForgotPassword)
+			r.Post("/password/reset", userController.HandleResetPassword)
+			r.Get("/verify", userController.HandleVerifyEmail)
+
+			r.Group(func(r chi.Router) {
+				r.Use(authMiddleware.Auth)
+				r.Use(middlewares.RequireSession)
+
+				r.Post("/logout", userController.HandleLogoutUser)
+				r.Get("/me", userController.HandleGetCurrentUser)
+				r.Patch("/me", userController.HandleUpdateCurrentUser)
+				r.Delete("/me", userController.HandleDeleteCurrentUser)
+				r.Post("/password", userController.HandleChangePassword)
+				r.Post("/verify/resend", userController.HandleResendVerificationEmail)
+				r.Post("/2fa/setup", twoFactorController.HandleSetupTwoFactor)
+				r.Post("/2fa/enable", twoFactorController.HandleEnableTwoFactor)
+				r.Post("/2fa/disable", twoFactorController.HandleDisableTwoFactor)
+				r.Post("/api-keys", apiKeyController.HandleCreateApiKey)
+				r.Get("/api-keys", apiKeyController.HandleGetApiKeys)
+				r.Delete("/api-keys/{id}", apiKeyController.HandleRevokeApiKey)
+			})
+		})
+
+		// the streams also take a stream ticket, so they can't share the Auth
+		// of the routes below
+		r.Group(func(r chi.Router) {
+			r.Use(authMiddleware.StreamAuth)
+			r.Use(middlewares.RequireScope(apikeyentity.MatchesRead))
+
+			r.Get("/notifications/stream", notificationController.HandleStreamNotifications)
+			r.Get("/notifications/ws", notificationController.HandleNotificationsWebSocket)
 		})
 
 		r.Group(func(r chi.Router) {
-			r.Use(middlewares.Auth)
+			r.Use(authMiddleware.Auth)
 
 			r.Route("/cat", func(r chi.Router) {
-				r.Post("/", catController.HandleCreateCat)
-				r.Get("/", catController.HandleGetCats)
-				r.Put("/{id}", catController.HandleUpdateCatById)
-				r.Delete("/{id}", catController.HandleDeleteCatById)
+				r.With(middlewares.RequireScope(apikeyentity.CatsWrite)).Post("/", catController.HandleCreateCat)
+				r.With(middlewares.RequireScope(apikeyentity.CatsRead)).Get("/", catController.HandleGetCats)
+				r.With(middlewares.RequireScope(apikeyentity.CatsRead)).Get("/{id}", catController.HandleGetCatById)
+				r.With(middlewares.RequireScope(apikeyentity.CatsWrite)).Put("/{id}", catController.HandleUpdateCatById)
+				r.With(middlewares.RequireScope(apikeyentity.CatsWrite)).Patch("/{id}", catController.HandlePatchCatById)
+				r.With(middlewares.RequireScope(apikeyentity.CatsWrite)).Delete("/{id}", catController.HandleDeleteCatById)
+				r.With(middlewares.RequireScope(apikeyentity.CatsWrite)).Post("/{id}/images", catController.HandleUploadCatImages)
+				r.With(middlewares.RequireScope(apikeyentity.CatsRead)).Get("/{id}/images", catController.HandleGetCatImages)
+				r.With(middlewares.RequireScope(apikeyentity.CatsWrite)).Put("/{id}/images/order", catController.HandleReorderCatImages)
+				r.With(middlewares.RequireScope(apikeyentity.CatsWrite)).Patch("/{id}/images/{imageId}", catController.HandleUpdateCatImage)
+				r.With(middlewares.RequireScope(apikeyentity.CatsWrite)).Delete("/{id}/images/{imageId}", catController.HandleDeleteCatImage)
+				r.With(middlewares.RequireScope(apikeyentity.CatsRead)).Get("/{id}/recommendations", catController.HandleGetCatRecommendations)
 
 				r.Route("/match", func(r chi.Router) {
-					r.Post("/", matchController.HandleCreateMatch)
-					r.Get("/", matchController.HandleGetMatches)
-					r.Post("/approve", matchController.HandleApproveMatch)
-					r.Post("/reject", matchController.HandleRejectMatch)
-					r.Delete("/{id}", matchController.HandleDeleteMatch)
+					r.With(middlewares.RequireScope(apikeyentity.MatchesWrite)).Post("/", matchController.HandleCreateMatch)
+					r.With(middlewares.RequireScope(apikeyentity.MatchesRead)).Get("/", matchController.HandleGetMatches)
+					r.With(middlewares.RequireScope(apikeyentity.MatchesRead)).Get("/{id}", matchController.HandleGetMatchById)
+					r.With(middlewares.RequireScope(apikeyentity.MatchesWrite)).Post("/approve", matchController.HandleApproveMatch)
+					r.With(middlewares.RequireScope(apikeyentity.MatchesWrite)).Post("/reject", matchController.HandleRejectMatch)
+					r.With(middlewares.RequireScope(apikeyentity.MatchesWrite)).Post("/unmatch", matchController.HandleUnmatchMatch)
+					r.With(middlewares.RequireScope(apikeyentity.MatchesWrite)).Delete("/{id}", matchController.HandleDeleteMatch)
+					r.With(middlewares.RequireScope(apikeyentity.MatchesWrite)).Post("/{id}/messages", messageController.HandleCreateMessage)
+					r.With(middlewares.RequireScope(apikeyentity.MatchesRead)).Get("/{id}/messages", messageController.HandleGetMessages)
+				})
+			})
+
+			r.Route("/notifications", func(r chi.Router) {
+				r.With(middlewares.RequireScope(apikeyentity.MatchesRead)).Get("/", notificationController.HandleGetNotifications)
+				r.With(middlewares.RequireSession).Post("/ticket", notificationController.HandleCreateStreamTicket)
+				r.With(middlewares.RequireScope(apikeyentity.MatchesWrite)).Post("/read", notificationController.HandleReadAllNotifications)
+				r.With(middlewares.RequireScope(apikeyentity.MatchesWrite)).Post("/{id}/read", notificationController.HandleReadNotification)
+			})
+
+			r.Route("/webhooks", func(r chi.Router) {
+				r.Use(middlewares.RequireSession)
+
+				r.Post("/", webhookController.HandleCreateWebhook)
+				r.Get("/", webhookController.HandleGetWebhooks)
+				r.Delete("/{id}", webhookController.HandleDeleteWebhook)
+				r.Get("/{id}/deliveries", webhookController.HandleGetDeliveries)
+				r.Post("/{id}/deliveries/{deliveryId}/redeliver", webhookController.HandleRedeliver)
+			})
+
+			r.Route("/admin", func(r chi.Router) {
+				r.Use(middlewares.RequireSession)
+				r.Use(middlewares.RequireRole(userentity.RoleModerator, userentity.RoleAdmin))
+
+				r.Delete("/cat/{id}", adminController.HandleForceDeleteCat)
+				r.Delete("/cat/match/{id}", adminController.HandleCancelMatch)
+
+				r.Group(func(r chi.Router) {
+					r.Use(middlewares.RequireRole(userentity.RoleAdmin))
+
+					r.Get("/user", adminController.HandleGetUsers)
+					r.Post("/user/{id}/suspend", adminController.HandleSuspendUser)
+					r.Post("/user/{id}/unsuspend", adminController.HandleUnsuspendUser)
 				})
 			})
 		})
@@ -1,30 +1,69 @@
 package http
 
 import (
+	"context"
+	"errors"
 	"log"
 	"net/http"
+	"time"
 
+	"github.com/danzBraham/cats-social/internal/blobstore"
+	"github.com/danzBraham/cats-social/internal/services"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// shutdownTimeout is how long in-flight requests get to finish once the
+// server is asked to stop. Streams that are still open after it are closed.
+const shutdownTimeout = 10 * time.Second
+
 type Server struct {
-	Addr string
-	DB   *pgxpool.Pool
+	Addr      string
+	DB        *pgxpool.Pool
+	BlobStore blobstore.BlobStore
+	Services  *services.Services
 }
 
-func NewServer(addr string, db *pgxpool.Pool) *Server {
+func NewServer(
+	addr string,
+	db *pgxpool.Pool,
+	blobStore blobstore.BlobStore,
+	services *services.Services,
+) *Server {
 	return &Server{
-		Addr: addr,
-		DB:   db,
+		Addr:      addr,
+		DB:        db,
+		BlobStore: blobStore,
+		Services:  services,
 	}
 }
 
-func (s *Server) Launch() error {
+// Launch serves until ctx is cancelled, then stops accepting connections and
+// waits for the in-flight requests before returning.
+func (s *Server) Launch(ctx context.Context) error {
 	server := &http.Server{
 		Addr:    s.Addr,
 		Handler: s.RegisterRoutes(),
 	}
 
+	shutdownErr := make(chan error, 1)
+	go func() {
+		<-ctx.Done()
+		log.Println("Server shutting down")
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+
+		err := server.Shutdown(shutdownCtx)
+		if errors.Is(err, context.DeadlineExceeded) {
+			err = server.Close()
+		}
+		shutdownErr <- err
+	}()
+
 	log.Printf("Server listening on %s\n", server.Addr)
-	return server.ListenAndServe()
+	err := server.ListenAndServe()
+	if !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return <-shutdownErr
 }
@@ -0,0 +1,46 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+type Mailer interface {
+	Send(ctx context.Context, message *Message) error
+}
+
+// NewMailer builds the mailer selected by MAIL_DRIVER ("smtp", "file" or
+// "memory"). The driver has to be set, so a deployment missing it doesn't
+// silently drop every email.
+func NewMailer() (Mailer, error) {
+	from := os.Getenv("MAIL_FROM")
+
+	switch driver := os.Getenv("MAIL_DRIVER"); driver {
+	case "smtp":
+		return NewSMTPMailer(
+			os.Getenv("SMTP_HOST"),
+			os.Getenv("SMTP_PORT"),
+			os.Getenv("SMTP_USERNAME"),
+			os.Getenv("SMTP_PASSWORD"),
+			from,
+		), nil
+	case "file":
+		return NewFileMailer(os.Getenv("MAIL_DIR"), from)
+	case "memory":
+		log.Println("WARNING: MAIL_DRIVER=memory, emails are kept in memory and never sent")
+		return NewMemoryMailer(), nil
+	case "":
+		return nil, errors.New("MAIL_DRIVER is not set, use smtp, file or memory")
+	default:
+		return nil, fmt.Errorf("unknown mail driver: %s", driver)
+	}
+}
@@ -0,0 +1,117 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/danzBraham/cats-social/internal/entities/notificationentity"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// subscriberBuffer is how many notifications a slow stream can fall behind
+// before new ones are dropped for it; the inbox still has them.
+const subscriberBuffer = 16
+
+// Hub pushes the notifications announced on the Postgres notifications
+// channel to the streams opened on this replica.
+type Hub struct {
+	mu          sync.RWMutex
+	subscribers map[string]map[chan *notificationentity.GetNotificationResponse]struct{}
+}
+
+func NewHub() *Hub {
+	return &Hub{
+		subscribers: map[string]map[chan *notificationentity.GetNotificationResponse]struct{}{},
+	}
+}
+
+// Subscribe returns the notifications of the user as they arrive. The
+// returned function must be called once the stream is closed.
+func (h *Hub) Subscribe(userId string) (<-chan *notificationentity.GetNotificationResponse, func()) {
+	ch := make(chan *notificationentity.GetNotificationResponse, subscriberBuffer)
+
+	h.mu.Lock()
+	if h.subscribers[userId] == nil {
+		h.subscribers[userId] = map[chan *notificationentity.GetNotificationResponse]struct{}{}
+	}
+	h.subscribers[userId][ch] = struct{}{}
+	h.mu.Unlock()
+
+	return ch, func() {
+		h.mu.Lock()
+		delete(h.subscribers[userId], ch)
+		if len(h.subscribers[userId]) == 0 {
+			delete(h.subscribers, userId)
+		}
+		h.mu.Unlock()
+	}
+}
+
+func (h *Hub) publish(event *notificationentity.Event) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for ch := range h.subscribers[event.UserId] {
+		select {
+		case ch <- event.Notification:
+		default:
+		}
+	}
+}
+
+// Listen holds a connection of the pool listening on the notifications
+// channel until ctx is cancelled, reconnecting when it's lost.
+func (h *Hub) Listen(ctx context.Context, db *pgxpool.Pool) {
+	backoff := time.Second
+	for ctx.Err() == nil {
+		start := time.Now()
+		err := h.listen(ctx, db)
+		if ctx.Err() != nil {
+			return
+		}
+		if time.Since(start) > time.Minute {
+			backoff = time.Second
+		}
+		log.Printf("notification listener: %v, reconnecting in %s", err, backoff)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, time.Minute)
+	}
+}
+
+func (h *Hub) listen(ctx context.Context, db *pgxpool.Pool) error {
+	pooled, err := db.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	// a connection that was listening must not go back to the pool
+	conn := pooled.Hijack()
+	defer conn.Close(context.Background())
+
+	_, err = conn.Exec(ctx, "LISTEN "+pgx.Identifier{notificationentity.Channel}.Sanitize())
+	if err != nil {
+		return err
+	}
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+
+		event := &notificationentity.Event{}
+		if err := json.Unmarshal([]byte(notification.Payload), event); err != nil {
+			log.Printf("notification listener: invalid payload: %v", err)
+			continue
+		}
+		h.publish(event)
+	}
+}
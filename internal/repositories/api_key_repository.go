@@ -0,0 +1,175 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/danzBraham/cats-social/internal/entities/apikeyentity"
+	"github.com/danzBraham/cats-social/internal/errors/autherror"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type ApiKeyRepository interface {
+	IsApiKeyOwner(ctx context.Context, apiKeyId, userId string) (bool, error)
+	CreateApiKey(ctx context.Context, apiKey *apikeyentity.ApiKey) (string, error)
+	GetApiKeys(ctx context.Context, userId string) ([]*apikeyentity.GetApiKeyResponse, error)
+	UseApiKey(ctx context.Context, keyHash string) (*apikeyentity.ApiKey, error)
+	RevokeApiKeyById(ctx context.Context, apiKeyId string) error
+}
+
+type ApiKeyRepositoryImpl struct {
+	DB *pgxpool.Pool
+}
+
+func NewApiKeyRepository(db *pgxpool.Pool) ApiKeyRepository {
+	return &ApiKeyRepositoryImpl{DB: db}
+}
+
+func (r *ApiKeyRepositoryImpl) IsApiKeyOwner(ctx context.Context, apiKeyId, userId string) (bool, error) {
+	query := `
+		SELECT
+			1
+		FROM
+			api_keys
+		WHERE
+			id = $1
+			AND user_id = $2
+			AND revoked_at IS NULL
+	`
+	var exists int
+	err := r.DB.QueryRow(ctx, query, apiKeyId, userId).Scan(&exists)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (r *ApiKeyRepositoryImpl) CreateApiKey(ctx context.Context, apiKey *apikeyentity.ApiKey) (string, error) {
+	query := `
+		INSERT INTO
+			api_keys (id, user_id, name, prefix, key_hash, scopes)
+		VALUES
+			($1, $2, $3, $4, $5, $6)
+		RETURNING
+			created_at
+	`
+	var createdAt time.Time
+	err := r.DB.QueryRow(ctx, query,
+		&apiKey.Id,
+		&apiKey.UserId,
+		&apiKey.Name,
+		&apiKey.Prefix,
+		&apiKey.KeyHash,
+		&apiKey.Scopes,
+	).Scan(&createdAt)
+	if err != nil {
+		return "", err
+	}
+	return createdAt.Format(time.RFC3339), nil
+}
+
+func (r *ApiKeyRepositoryImpl) GetApiKeys(ctx context.Context, userId string) ([]*apikeyentity.GetApiKeyResponse, error) {
+	query := `
+		SELECT
+			id,
+			name,
+			prefix,
+			scopes,
+			last_used_at,
+			created_at
+		FROM
+			api_keys
+		WHERE
+			user_id = $1
+			AND revoked_at IS NULL
+		ORDER BY
+			created_at DESC
+	`
+	rows, err := r.DB.Query(ctx, query, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	apiKeys := []*apikeyentity.GetApiKeyResponse{}
+	for rows.Next() {
+		var apiKey apikeyentity.GetApiKeyResponse
+		var lastUsedAt *time.Time
+		var createdAt time.Time
+		err := rows.Scan(
+			&apiKey.Id,
+			&apiKey.Name,
+			&apiKey.Prefix,
+			&apiKey.Scopes,
+			&lastUsedAt,
+			&createdAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if lastUsedAt != nil {
+			formatted := lastUsedAt.Format(time.RFC3339)
+			apiKey.LastUsedAt = &formatted
+		}
+		apiKey.CreatedAt = createdAt.Format(time.RFC3339)
+		apiKeys = append(apiKeys, &apiKey)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return apiKeys, nil
+}
+
+// UseApiKey looks up an active key by its hash and records that it was used
+// in the same statement.
+func (r *ApiKeyRepositoryImpl) UseApiKey(ctx context.Context, keyHash string) (*apikeyentity.ApiKey, error) {
+	query := `
+		UPDATE
+			api_keys
+		SET
+			last_used_at = NOW()
+		WHERE
+			key_hash = $1
+			AND revoked_at IS NULL
+		RETURNING
+			id, user_id, name, scopes
+	`
+	var apiKey apikeyentity.ApiKey
+	err := r.DB.QueryRow(ctx, query, keyHash).Scan(
+		&apiKey.Id,
+		&apiKey.UserId,
+		&apiKey.Name,
+		&apiKey.Scopes,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, autherror.ErrInvalidApiKey
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &apiKey, nil
+}
+
+func (r *ApiKeyRepositoryImpl) RevokeApiKeyById(ctx context.Context, apiKeyId string) error {
+	query := `
+		UPDATE
+			api_keys
+		SET
+			revoked_at = NOW()
+		WHERE
+			id = $1
+			AND revoked_at IS NULL
+	`
+	_, err := r.DB.Exec(ctx, query, apiKeyId)
+	if err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/danzBraham/cats-social/internal/entities/auditentity"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type AuditRepository interface {
+	CreateAuditLog(ctx context.Context, auditLog *auditentity.AuditLog) error
+}
+
+type AuditRepositoryImpl struct {
+	DB *pgxpool.Pool
+}
+
+func NewAuditRepository(db *pgxpool.Pool) AuditRepository {
+	return &AuditRepositoryImpl{DB: db}
+}
+
+func (r *AuditRepositoryImpl) CreateAuditLog(ctx context.Context, auditLog *auditentity.AuditLog) error {
+	query := `
+		INSERT INTO
+			audit_logs (id, user_id, action, subject, ip_address)
+		VALUES
+			($1, NULLIF($2, ''), $3, $4, $5)
+	`
+	_, err := r.DB.Exec(ctx, query,
+		&auditLog.Id,
+		&auditLog.UserId,
+		&auditLog.Action,
+		&auditLog.Subject,
+		&auditLog.IpAddress,
+	)
+	if err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,443 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+
+	"github.com/danzBraham/cats-social/internal/entities/catentity"
+	"github.com/danzBraham/cats-social/internal/errors/caterror"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/oklog/ulid/v2"
+)
+
+// CatImageRepository manages cat_images. Every write also rewrites
+// cats.image_urls in the same transaction, so readers of the old column keep
+// seeing the images in order.
+type CatImageRepository interface {
+	IsCatImageExists(ctx context.Context, catId, imageId string) (bool, error)
+	GetCatImages(ctx context.Context, catIds []string) (map[string][]*catentity.CatImage, error)
+	AddCatImages(ctx context.Context, catId string, imageUrls []string) error
+	UpdateCatImage(ctx context.Context, catId, imageId string, patch *catentity.UpdateCatImageRequest) error
+	ReorderCatImages(ctx context.Context, catId string, imageIds []string) error
+	DeleteCatImage(ctx context.Context, catId, imageId string) (string, error)
+}
+
+type CatImageRepositoryImpl struct {
+	DB *pgxpool.Pool
+}
+
+func NewCatImageRepository(db *pgxpool.Pool) CatImageRepository {
+	return &CatImageRepositoryImpl{DB: db}
+}
+
+func (r *CatImageRepositoryImpl) IsCatImageExists(ctx context.Context, catId, imageId string) (bool, error) {
+	query := `
+		SELECT
+			1
+		FROM
+			cat_images
+		WHERE
+			id = $1
+			AND cat_id = $2
+	`
+	var exists int
+	err := r.DB.QueryRow(ctx, query, imageId, catId).Scan(&exists)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// GetCatImages returns the ordered images of every given cat, keyed by cat id.
+func (r *CatImageRepositoryImpl) GetCatImages(ctx context.Context, catIds []string) (map[string][]*catentity.CatImage, error) {
+	catImages := map[string][]*catentity.CatImage{}
+	if len(catIds) == 0 {
+		return catImages, nil
+	}
+
+	query := `
+		SELECT
+			cat_id,
+			id,
+			url,
+			caption,
+			is_primary
+		FROM
+			cat_images
+		WHERE
+			cat_id = ANY($1)
+		ORDER BY
+			cat_id, position
+	`
+	rows, err := r.DB.Query(ctx, query, catIds)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var catId string
+		var image catentity.CatImage
+		err := rows.Scan(
+			&catId,
+			&image.Id,
+			&image.Url,
+			&image.Caption,
+			&image.IsPrimary,
+		)
+		if err != nil {
+			return nil, err
+		}
+		catImages[catId] = append(catImages[catId], &image)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return catImages, nil
+}
+
+// AddCatImages appends the images after the last one of the cat. The cat row
+// is locked first, so concurrent uploads take turns and don't read the same
+// last position.
+func (r *CatImageRepositoryImpl) AddCatImages(ctx context.Context, catId string, imageUrls []string) error {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	lockQuery := `
+		SELECT
+			1
+		FROM
+			cats
+		WHERE
+			id = $1
+		FOR UPDATE
+	`
+	_, err = tx.Exec(ctx, lockQuery, catId)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO
+			cat_images (id, cat_id, url, position)
+		SELECT
+			$1, $2, $3, COALESCE(MAX(position) + 1, 0)
+		FROM
+			cat_images
+		WHERE
+			cat_id = $2
+	`
+	for _, imageUrl := range imageUrls {
+		_, err = tx.Exec(ctx, query, ulid.Make().String(), catId, imageUrl)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = syncCatImageUrls(ctx, tx, catId)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (r *CatImageRepositoryImpl) UpdateCatImage(ctx context.Context, catId, imageId string, patch *catentity.UpdateCatImageRequest) error {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if patch.Caption != nil {
+		query := `
+			UPDATE
+				cat_images
+			SET
+				caption = $1,
+				updated_at = NOW()
+			WHERE
+				id = $2
+				AND cat_id = $3
+		`
+		_, err = tx.Exec(ctx, query, *patch.Caption, imageId, catId)
+		if err != nil {
+			return err
+		}
+	}
+
+	if patch.IsPrimary != nil && *patch.IsPrimary {
+		// unset the old primary first, the unique index allows only one
+		unsetQuery := `
+			UPDATE
+				cat_images
+			SET
+				is_primary = false,
+				updated_at = NOW()
+			WHERE
+				cat_id = $1
+				AND is_primary = true
+				AND id <> $2
+		`
+		_, err = tx.Exec(ctx, unsetQuery, catId, imageId)
+		if err != nil {
+			return err
+		}
+
+		setQuery := `
+			UPDATE
+				cat_images
+			SET
+				is_primary = true,
+				updated_at = NOW()
+			WHERE
+				id = $1
+				AND cat_id = $2
+		`
+		_, err = tx.Exec(ctx, setQuery, imageId, catId)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+// ReorderCatImages moves the images into the order of imageIds, which must
+// hold every image of the cat exactly once.
+func (r *CatImageRepositoryImpl) ReorderCatImages(ctx context.Context, catId string, imageIds []string) error {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+		UPDATE
+			cat_images ci
+		SET
+			position = o.position - 1,
+			updated_at = NOW()
+		FROM
+			UNNEST($1::TEXT[]) WITH ORDINALITY AS o(id, position)
+		WHERE
+			ci.id = o.id
+			AND ci.cat_id = $2
+	`
+	commandTag, err := tx.Exec(ctx, query, imageIds, catId)
+	if err != nil {
+		return err
+	}
+
+	countQuery := `
+		SELECT
+			COUNT(*)
+		FROM
+			cat_images
+		WHERE
+			cat_id = $1
+	`
+	var imageCount int
+	err = tx.QueryRow(ctx, countQuery, catId).Scan(&imageCount)
+	if err != nil {
+		return err
+	}
+	if int(commandTag.RowsAffected()) != len(imageIds) || imageCount != len(imageIds) {
+		return caterror.ErrInvalidImageOrder
+	}
+
+	err = syncCatImageUrls(ctx, tx, catId)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// DeleteCatImage returns the url of the deleted image so the caller can clean
+// up the stored file.
+func (r *CatImageRepositoryImpl) DeleteCatImage(ctx context.Context, catId, imageId string) (string, error) {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+		DELETE FROM
+			cat_images
+		WHERE
+			id = $1
+			AND cat_id = $2
+		RETURNING
+			url
+	`
+	var imageUrl string
+	err = tx.QueryRow(ctx, query, imageId, catId).Scan(&imageUrl)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", caterror.ErrCatImageIdNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+
+	err = syncCatImageUrls(ctx, tx, catId)
+	if err != nil {
+		return "", err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return "", err
+	}
+
+	return imageUrl, nil
+}
+
+// replaceCatImages makes the images of a cat match imageUrls, in that order.
+// Images that stay keep their id, caption and primary flag.
+func replaceCatImages(ctx context.Context, tx pgx.Tx, catId string, imageUrls []string) error {
+	query := `
+		SELECT
+			id,
+			url
+		FROM
+			cat_images
+		WHERE
+			cat_id = $1
+		ORDER BY
+			position
+	`
+	rows, err := tx.Query(ctx, query, catId)
+	if err != nil {
+		return err
+	}
+	existingIds := map[string][]string{}
+	for rows.Next() {
+		var id, url string
+		if err := rows.Scan(&id, &url); err != nil {
+			rows.Close()
+			return err
+		}
+		existingIds[url] = append(existingIds[url], id)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	moveQuery := `
+		UPDATE
+			cat_images
+		SET
+			position = $1,
+			updated_at = NOW()
+		WHERE
+			id = $2
+	`
+	insertQuery := `
+		INSERT INTO
+			cat_images (id, cat_id, url, position)
+		VALUES
+			($1, $2, $3, $4)
+	`
+	keptIds := []string{}
+	for position, imageUrl := range imageUrls {
+		if ids := existingIds[imageUrl]; len(ids) > 0 {
+			existingIds[imageUrl] = ids[1:]
+			keptIds = append(keptIds, ids[0])
+			_, err = tx.Exec(ctx, moveQuery, position, ids[0])
+		} else {
+			id := ulid.Make().String()
+			keptIds = append(keptIds, id)
+			_, err = tx.Exec(ctx, insertQuery, id, catId, imageUrl, position)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	removeQuery := `
+		DELETE FROM
+			cat_images
+		WHERE
+			cat_id = $1
+			AND id <> ALL($2)
+	`
+	_, err = tx.Exec(ctx, removeQuery, catId, keptIds)
+	if err != nil {
+		return err
+	}
+
+	return syncCatImageUrls(ctx, tx, catId)
+}
+
+// syncCatImageUrls makes sure the cat has a primary image while it has any
+// and copies the ordered urls into cats.image_urls.
+func syncCatImageUrls(ctx context.Context, tx pgx.Tx, catId string) error {
+	primaryQuery := `
+		UPDATE
+			cat_images
+		SET
+			is_primary = true,
+			updated_at = NOW()
+		WHERE
+			id = (
+				SELECT
+					id
+				FROM
+					cat_images
+				WHERE
+					cat_id = $1
+				ORDER BY
+					position
+				LIMIT 1
+			)
+			AND NOT EXISTS (
+				SELECT
+					1
+				FROM
+					cat_images
+				WHERE
+					cat_id = $1
+					AND is_primary = true
+			)
+	`
+	_, err := tx.Exec(ctx, primaryQuery, catId)
+	if err != nil {
+		return err
+	}
+
+	imageUrlsQuery := `
+		UPDATE
+			cats
+		SET
+			image_urls = ARRAY(
+				SELECT
+					url
+				FROM
+					cat_images
+				WHERE
+					cat_id = $1
+				ORDER BY
+					position
+			),
+			updated_at = NOW()
+		WHERE
+			id = $1
+	`
+	_, err = tx.Exec(ctx, imageUrlsQuery, catId)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
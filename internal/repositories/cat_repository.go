@@ -3,11 +3,15 @@ package repositories
 import (
 	"context"
 	"errors"
+	"math"
 	"strconv"
 	"strings"
 	"time"
+	"unicode"
 
 	"github.com/danzBraham/cats-social/internal/entities/catentity"
+	"github.com/danzBraham/cats-social/internal/entities/evententity"
+	"github.com/danzBraham/cats-social/internal/entities/locationentity"
 	"github.com/danzBraham/cats-social/internal/errors/caterror"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -16,11 +20,14 @@ import (
 type CatRepository interface {
 	IsCatIdExists(ctx context.Context, catId string) (bool, error)
 	IsCatOwner(ctx context.Context, catId, ownerId string) (bool, error)
-	CreateCat(ctx context.Context, cat *catentity.Cat) (string, error)
-	GetCats(ctx context.Context, ownerId string, params *catentity.CatQueryParams) ([]*catentity.GetCatResponse, error)
+	CreateCat(ctx context.Context, cat *catentity.Cat, event *evententity.Event) (string, error)
+	GetCats(ctx context.Context, ownerId string, params *catentity.CatQueryParams) ([]*catentity.GetCatResponse, *catentity.CatCursor, error)
+	CountCats(ctx context.Context, ownerId string, params *catentity.CatQueryParams) (int, error)
+	GetRecommendations(ctx context.Context, catId string, params *catentity.RecommendationQueryParams) ([]*catentity.Recommendation, *catentity.RecommendationCursor, error)
 	GetCatById(ctx context.Context, catId string) (*catentity.Cat, error)
-	UpdateCatById(ctx context.Context, catId string, cat *catentity.Cat) error
-	DeleteCatById(ctx context.Context, catId string) error
+	UpdateCatById(ctx context.Context, catId string, cat *catentity.Cat, event *evententity.Event) error
+	PatchCatById(ctx context.Context, catId string, patch *catentity.PatchCatRequest, event *evententity.Event) error
+	DeleteCatById(ctx context.Context, catId string, event *evententity.Event) ([]string, error)
 }
 
 type CatRepositoryImpl struct {
@@ -74,17 +81,24 @@ func (r *CatRepositoryImpl) IsCatOwner(ctx context.Context, catId, ownerId strin
 	return true, nil
 }
 
-func (r *CatRepositoryImpl) CreateCat(ctx context.Context, cat *catentity.Cat) (string, error) {
+func (r *CatRepositoryImpl) CreateCat(ctx context.Context, cat *catentity.Cat, event *evententity.Event) (string, error) {
 	query := `
 		INSERT INTO
-			cats (id, name, race, sex, age_in_month, description, image_urls, owner_id)
+			cats (id, name, race, sex, age_in_month, description, image_urls, owner_id, latitude, longitude, city)
 		VALUES
-			($1, $2, $3, $4, $5, $6, $7, $8)
+			($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
 		RETURNING
 			created_at
 	`
+	latitude, longitude, city := locationValues(cat.Location)
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback(ctx)
+
 	var createdAt time.Time
-	err := r.DB.QueryRow(ctx, query,
+	err = tx.QueryRow(ctx, query,
 		&cat.Id,
 		&cat.Name,
 		&cat.Race,
@@ -93,14 +107,36 @@ func (r *CatRepositoryImpl) CreateCat(ctx context.Context, cat *catentity.Cat) (
 		&cat.Description,
 		&cat.ImageUrls,
 		&cat.OwnerId,
+		latitude,
+		longitude,
+		city,
 	).Scan(&createdAt)
 	if err != nil {
 		return "", err
 	}
+
+	err = replaceCatImages(ctx, tx, cat.Id, cat.ImageUrls)
+	if err != nil {
+		return "", err
+	}
+
+	err = insertEvent(ctx, tx, event)
+	if err != nil {
+		return "", err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return "", err
+	}
+
 	return createdAt.Format(time.RFC3339), nil
 }
 
-func (r *CatRepositoryImpl) GetCats(ctx context.Context, ownerId string, params *catentity.CatQueryParams) ([]*catentity.GetCatResponse, error) {
+// GetCats returns one page of cats in the order of params.Sort, ties broken by
+// id, and the cursor of its last cat when there are more cats after it. A
+// cursor continues right after the cat it points at, so edits between page
+// loads don't skip or repeat cats the way offsets do.
+func (r *CatRepositoryImpl) GetCats(ctx context.Context, ownerId string, params *catentity.CatQueryParams) ([]*catentity.GetCatResponse, *catentity.CatCursor, error) {
 	query := `
 		SELECT
 			id,
@@ -111,110 +147,288 @@ func (r *CatRepositoryImpl) GetCats(ctx context.Context, ownerId string, params
 			description,
 			image_urls,
 			has_matched,
-			created_at
+			city,
+			created_at,
+			updated_at
+	`
+	conditions, args := catConditions(ownerId, params)
+	argId := len(args) + 1
+
+	distance := ""
+	if params.Near != nil {
+		distance = catDistance(argId)
+		query += `,
+			` + distance + `
+		`
+		args = append(args, params.Near.Latitude, params.Near.Longitude)
+		argId += 2
+	}
+
+	rank := ""
+	if params.Search != "" {
+		searchQuery := `to_tsquery('simple', $` + strconv.Itoa(argId) + `)`
+		rank = `ts_rank(search_vector, ` + searchQuery + `)`
+		query += `,
+			` + rank + `,
+			ts_headline('simple', ` + htmlEscaped("name") + `, ` + searchQuery + `, 'HighlightAll=true, StartSel=<mark>, StopSel=</mark>'),
+			ts_headline('simple', ` + htmlEscaped("description") + `, ` + searchQuery + `, 'StartSel=<mark>, StopSel=</mark>, MaxWords=20, MinWords=5')
+		`
+		args = append(args, catSearchQuery(params.Search))
+		argId++
+	}
+
+	query += `
 		FROM
 			cats
 		WHERE
 			is_deleted = false
-	`
-	args := []interface{}{}
-	argId := 1
+	` + conditions
 
-	if params.Id != "" {
-		query += ` AND id = $` + strconv.Itoa(argId)
-		args = append(args, params.Id)
-		argId++
+	sorts := params.Sort
+	if len(sorts) == 0 {
+		sorts = catentity.DefaultCatSort
 	}
 
-	validRace := map[catentity.Race]bool{
-		catentity.Persian:          true,
-		catentity.MaineCoon:        true,
-		catentity.Siamese:          true,
-		catentity.Ragdoll:          true,
-		catentity.Bengal:           true,
-		catentity.Sphynx:           true,
-		catentity.BritishShorthair: true,
-		catentity.Abyssinian:       true,
-		catentity.ScottishFold:     true,
-		catentity.Birman:           true,
+	columns := make([]string, 0, len(sorts)+1)
+	descending := make([]bool, 0, len(sorts)+1)
+	for _, sort := range sorts {
+		column := catSortColumns[sort.Field]
+		switch sort.Field {
+		case catentity.SortByRelevance:
+			column = rank
+		case catentity.SortByDistance:
+			column = distance
+		}
+		columns = append(columns, column)
+		descending = append(descending, sort.Desc)
 	}
+	columns = append(columns, "id")
+	descending = append(descending, descending[len(descending)-1])
 
-	if params.Race != "" {
-		if !validRace[params.Race] {
-			return []*catentity.GetCatResponse{}, nil
+	if params.Cursor != nil {
+		// (a, b) after (x, y) is a > x OR (a = x AND b > y) for any mix of
+		// directions
+		after := []string{}
+		for i := range columns {
+			condition := ""
+			for j := 0; j < i; j++ {
+				condition += columns[j] + ` = $` + strconv.Itoa(argId+j) + ` AND `
+			}
+			operator := ` > $`
+			if descending[i] {
+				operator = ` < $`
+			}
+			after = append(after, `(`+condition+columns[i]+operator+strconv.Itoa(argId+i)+`)`)
 		}
-		query += ` AND race = $` + strconv.Itoa(argId)
-		args = append(args, params.Race)
-		argId++
+		query += ` AND (` + strings.Join(after, ` OR `) + `)`
+		args = append(args, params.Cursor.Values...)
+		argId += len(columns)
 	}
 
-	validSex := map[catentity.Sex]bool{
-		catentity.Male:   true,
-		catentity.Female: true,
+	orderBy := make([]string, 0, len(columns))
+	for i, column := range columns {
+		if descending[i] {
+			column += ` DESC`
+		}
+		orderBy = append(orderBy, column)
 	}
 
-	if params.Sex != "" {
-		if !validSex[params.Sex] {
-			return []*catentity.GetCatResponse{}, nil
-		}
-		query += ` AND sex = $` + strconv.Itoa(argId)
-		args = append(args, params.Sex)
-		argId++
+	// one extra row tells whether there is a next page
+	query += ` ORDER BY ` + strings.Join(orderBy, `, `) + ` LIMIT $` + strconv.Itoa(argId)
+	args = append(args, params.Limit+1)
+	argId++
+
+	if params.Cursor == nil {
+		query += ` OFFSET $` + strconv.Itoa(argId)
+		args = append(args, params.Offset)
 	}
 
-	if params.HasMatched {
-		query += ` AND has_matched = $` + strconv.Itoa(argId)
-		args = append(args, params.HasMatched)
-		argId++
+	rows, err := r.DB.Query(ctx, query, args...)
+	if err != nil {
+		return nil, nil, err
 	}
+	defer rows.Close()
 
-	if params.AgeInMonth != "" {
-		var ageCondition string
-		switch {
-		case strings.HasPrefix(params.AgeInMonth, "<"):
-			ageCondition = "<"
-		case strings.HasPrefix(params.AgeInMonth, ">"):
-			ageCondition = ">"
-		default:
-			ageCondition = "="
+	cats := make([]*catentity.GetCatResponse, 0, params.Limit+1)
+	var next *catentity.CatCursor
+	for rows.Next() {
+		var cat catentity.GetCatResponse
+		var createdAt, updatedAt time.Time
+		var relevance float32
+		var distanceKm float64
+		dest := []interface{}{
+			&cat.Id,
+			&cat.Name,
+			&cat.Race,
+			&cat.Sex,
+			&cat.AgeInMonth,
+			&cat.Description,
+			&cat.ImageUrls,
+			&cat.HasMatched,
+			&cat.City,
+			&createdAt,
+			&updatedAt,
 		}
-
-		ageValue := strings.TrimLeft(params.AgeInMonth, "<=>")
-		age, err := strconv.Atoi(ageValue)
+		if params.Near != nil {
+			dest = append(dest, &distanceKm)
+		}
+		if params.Search != "" {
+			cat.Highlights = &catentity.CatHighlights{}
+			dest = append(dest, &relevance, &cat.Highlights.Name, &cat.Highlights.Description)
+		}
+		err := rows.Scan(dest...)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
+		}
+		cat.CreatedAt = createdAt.Format(time.RFC3339)
+		if params.Near != nil {
+			rounded := math.Round(distanceKm*10) / 10
+			cat.DistanceKm = &rounded
 		}
 
-		query += ` AND age_in_month ` + ageCondition + ` $` + strconv.Itoa(argId)
-		args = append(args, age)
-		argId++
+		if len(cats) == params.Limit-1 {
+			next = &catentity.CatCursor{}
+			for _, sort := range sorts {
+				switch sort.Field {
+				case catentity.SortByName:
+					next.Values = append(next.Values, cat.Name)
+				case catentity.SortByAgeInMonth:
+					next.Values = append(next.Values, cat.AgeInMonth)
+				case catentity.SortByCreatedAt:
+					next.Values = append(next.Values, createdAt)
+				case catentity.SortByUpdatedAt:
+					next.Values = append(next.Values, updatedAt)
+				case catentity.SortByRelevance:
+					next.Values = append(next.Values, relevance)
+				case catentity.SortByDistance:
+					next.Values = append(next.Values, distanceKm)
+				}
+			}
+			next.Values = append(next.Values, cat.Id)
+		}
+
+		cats = append(cats, &cat)
 	}
 
-	if params.Owned {
-		query += ` AND owner_id = $` + strconv.Itoa(argId)
-		args = append(args, ownerId)
-		argId++
+	if err = rows.Err(); err != nil {
+		return nil, nil, err
 	}
 
-	if params.Search != "" {
-		query += ` AND name ILIKE $` + strconv.Itoa(argId)
-		args = append(args, `%`+params.Search+`%`)
-		argId++
+	if len(cats) <= params.Limit {
+		return cats, nil, nil
+	}
+
+	return cats[:params.Limit], next, nil
+}
+
+// GetRecommendations scores the cats that the cat could request a match with,
+// under the same rules as creating a match request: opposite sex, another
+// owner, not matched yet and no approved or pending request between the two.
+// Each factor decays with the difference: age halves at 12 months apart,
+// distance at 10 km and recency at 30 days since the candidate was updated.
+func (r *CatRepositoryImpl) GetRecommendations(ctx context.Context, catId string, params *catentity.RecommendationQueryParams) ([]*catentity.Recommendation, *catentity.RecommendationCursor, error) {
+	query := `
+		WITH candidates AS (
+			SELECT
+				c.id,
+				c.name,
+				c.race,
+				c.sex,
+				c.age_in_month,
+				c.description,
+				c.image_urls,
+				c.has_matched,
+				c.city,
+				c.created_at,
+				(CASE WHEN c.race = s.race THEN 1 ELSE 0 END)::DOUBLE PRECISION AS same_race,
+				(1 / (1 + ABS(c.age_in_month - s.age_in_month) / 12.0))::DOUBLE PRECISION AS age_proximity,
+				(CASE
+					WHEN c.latitude IS NULL OR s.latitude IS NULL THEN 0
+					ELSE 1 / (1 + ` + greatCircleDistance("c.latitude", "c.longitude", "s.latitude", "s.longitude") + ` / 10)
+				END)::DOUBLE PRECISION AS distance,
+				(1 / (1 + GREATEST(0, EXTRACT(EPOCH FROM (COALESCE($2, LOCALTIMESTAMP) - c.updated_at)) / 86400) / 30))::DOUBLE PRECISION AS recency
+			FROM
+				cats c,
+				cats s
+			WHERE
+				s.id = $1
+				AND c.is_deleted = false
+				AND c.id != s.id
+				AND c.sex != s.sex
+				AND c.owner_id != s.owner_id
+				AND c.has_matched = false
+				AND NOT EXISTS (
+					SELECT
+						1
+					FROM
+						match_requests m
+					WHERE
+						((m.match_cat_id = c.id AND m.user_cat_id = s.id) OR (m.match_cat_id = s.id AND m.user_cat_id = c.id))
+						AND (m.status = 'approved' OR (m.status = 'pending' AND m.is_deleted = false))
+				)
+		), scored AS (
+			SELECT
+				*,
+				$3 * same_race + $4 * age_proximity + $5 * distance + $6 * recency AS score
+			FROM
+				candidates
+		)
+		SELECT
+			id,
+			name,
+			race,
+			sex,
+			age_in_month,
+			description,
+			image_urls,
+			has_matched,
+			city,
+			created_at,
+			same_race,
+			age_proximity,
+			distance,
+			recency,
+			score,
+			COALESCE($2, LOCALTIMESTAMP)
+		FROM
+			scored
+	`
+	var asOf *time.Time
+	if params.Cursor != nil {
+		asOf = &params.Cursor.AsOf
+	}
+	args := []interface{}{
+		catId,
+		asOf,
+		params.Weights.SameRace,
+		params.Weights.AgeProximity,
+		params.Weights.Distance,
+		params.Weights.Recency,
 	}
+	argId := len(args) + 1
 
-	query += ` ORDER BY updated_at DESC LIMIT $` + strconv.Itoa(argId) + ` OFFSET $` + strconv.Itoa(argId+1)
-	args = append(args, params.Limit, params.Offset)
+	if params.Cursor != nil {
+		query += ` WHERE (score, id) < ($` + strconv.Itoa(argId) + `, $` + strconv.Itoa(argId+1) + `)`
+		args = append(args, params.Cursor.Score, params.Cursor.Id)
+		argId += 2
+	}
+
+	// one extra row tells whether there is a next page
+	query += ` ORDER BY score DESC, id DESC LIMIT $` + strconv.Itoa(argId)
+	args = append(args, params.Limit+1)
 
 	rows, err := r.DB.Query(ctx, query, args...)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer rows.Close()
 
-	cats := make([]*catentity.GetCatResponse, 0, params.Limit)
+	recommendations := make([]*catentity.Recommendation, 0, params.Limit+1)
+	var next *catentity.RecommendationCursor
 	for rows.Next() {
 		var cat catentity.GetCatResponse
-		var createdAt time.Time
+		var recommendation catentity.Recommendation
+		var createdAt, rowAsOf time.Time
 		err := rows.Scan(
 			&cat.Id,
 			&cat.Name,
@@ -224,16 +438,218 @@ func (r *CatRepositoryImpl) GetCats(ctx context.Context, ownerId string, params
 			&cat.Description,
 			&cat.ImageUrls,
 			&cat.HasMatched,
+			&cat.City,
 			&createdAt,
+			&recommendation.Factors.SameRace,
+			&recommendation.Factors.AgeProximity,
+			&recommendation.Factors.Distance,
+			&recommendation.Factors.Recency,
+			&recommendation.Score,
+			&rowAsOf,
 		)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		cat.CreatedAt = createdAt.Format(time.RFC3339)
-		cats = append(cats, &cat)
+		recommendation.Cat = &cat
+
+		if len(recommendations) == params.Limit-1 {
+			next = &catentity.RecommendationCursor{
+				AsOf:  rowAsOf,
+				Score: recommendation.Score,
+				Id:    cat.Id,
+			}
+		}
+
+		recommendations = append(recommendations, &recommendation)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	if len(recommendations) <= params.Limit {
+		return recommendations, nil, nil
+	}
+
+	return recommendations[:params.Limit], next, nil
+}
+
+var catSortColumns = map[catentity.CatSortField]string{
+	catentity.SortByName:       "name",
+	catentity.SortByAgeInMonth: "age_in_month",
+	catentity.SortByCreatedAt:  "created_at",
+	catentity.SortByUpdatedAt:  "updated_at",
+}
+
+// CountCats counts every cat matching the filters of params, ignoring the
+// pagination.
+func (r *CatRepositoryImpl) CountCats(ctx context.Context, ownerId string, params *catentity.CatQueryParams) (int, error) {
+	query := `
+		SELECT
+			COUNT(*)
+		FROM
+			cats
+		WHERE
+			is_deleted = false
+	`
+	conditions, args := catConditions(ownerId, params)
+	query += conditions
+
+	var total int
+	err := r.DB.QueryRow(ctx, query, args...).Scan(&total)
+	if err != nil {
+		return 0, err
+	}
+	return total, nil
+}
+
+// catConditions builds the WHERE conditions for the filters of params, which
+// the controller has already validated.
+func catConditions(ownerId string, params *catentity.CatQueryParams) (string, []interface{}) {
+	conditions := ""
+	args := []interface{}{}
+	argId := 1
+
+	if params.Id != "" {
+		conditions += ` AND id = $` + strconv.Itoa(argId)
+		args = append(args, params.Id)
+		argId++
+	}
+
+	if len(params.Races) > 0 {
+		placeholders := make([]string, 0, len(params.Races))
+		for _, race := range params.Races {
+			placeholders = append(placeholders, `$`+strconv.Itoa(argId))
+			args = append(args, race)
+			argId++
+		}
+		conditions += ` AND race IN (` + strings.Join(placeholders, `, `) + `)`
+	}
+
+	if len(params.Sexes) > 0 {
+		placeholders := make([]string, 0, len(params.Sexes))
+		for _, sex := range params.Sexes {
+			placeholders = append(placeholders, `$`+strconv.Itoa(argId))
+			args = append(args, sex)
+			argId++
+		}
+		conditions += ` AND sex IN (` + strings.Join(placeholders, `, `) + `)`
+	}
+
+	if params.HasMatched {
+		conditions += ` AND has_matched = $` + strconv.Itoa(argId)
+		args = append(args, params.HasMatched)
+		argId++
+	}
+
+	for _, filter := range params.AgeInMonth {
+		conditions += ` AND age_in_month ` + filter.Operator + ` $` + strconv.Itoa(argId)
+		args = append(args, filter.Value)
+		argId++
+	}
+
+	if params.Owned {
+		conditions += ` AND owner_id = $` + strconv.Itoa(argId)
+		args = append(args, ownerId)
+		argId++
+	}
+
+	if params.Near != nil {
+		conditions += ` AND latitude IS NOT NULL`
+	}
+
+	if params.Near != nil && params.Near.RadiusKm > 0 {
+		minLatitude, maxLatitude, minLongitude, maxLongitude, ok := boundingBox(params.Near)
+		if ok {
+			conditions += ` AND latitude BETWEEN $` + strconv.Itoa(argId) + ` AND $` + strconv.Itoa(argId+1)
+			args = append(args, minLatitude, maxLatitude)
+			argId += 2
+		}
+		if ok && minLongitude >= -180 && maxLongitude <= 180 {
+			conditions += ` AND longitude BETWEEN $` + strconv.Itoa(argId) + ` AND $` + strconv.Itoa(argId+1)
+			args = append(args, minLongitude, maxLongitude)
+			argId += 2
+		}
+
+		conditions += ` AND ` + catDistance(argId) + ` <= $` + strconv.Itoa(argId+2)
+		args = append(args, params.Near.Latitude, params.Near.Longitude, params.Near.RadiusKm)
+		argId += 3
+	}
+
+	if params.Search != "" {
+		searchQuery := catSearchQuery(params.Search)
+		if searchQuery == "" {
+			// nothing searchable, like only punctuation, matches no cat
+			conditions += ` AND false`
+		} else {
+			conditions += ` AND search_vector @@ to_tsquery('simple', $` + strconv.Itoa(argId) + `)`
+			args = append(args, searchQuery)
+			argId++
+		}
 	}
 
-	return cats, nil
+	return conditions, args
+}
+
+const earthRadiusKm = 6371.0
+
+// catDistance is the great-circle distance in km between a cat and the point
+// at $argId, $argId+1.
+func catDistance(argId int) string {
+	return greatCircleDistance("latitude", "longitude", `$`+strconv.Itoa(argId), `$`+strconv.Itoa(argId+1))
+}
+
+// greatCircleDistance is the distance in km between two points by the
+// haversine formula. LEAST keeps rounding errors out of the domain of ASIN.
+func greatCircleDistance(latitude1, longitude1, latitude2, longitude2 string) string {
+	return `(2 * ` + strconv.FormatFloat(earthRadiusKm, 'f', -1, 64) + ` * ASIN(LEAST(1, SQRT(` +
+		`POWER(SIN(RADIANS(` + latitude1 + ` - ` + latitude2 + `) / 2), 2) + ` +
+		`COS(RADIANS(` + latitude2 + `)) * COS(RADIANS(` + latitude1 + `)) * POWER(SIN(RADIANS(` + longitude1 + ` - ` + longitude2 + `) / 2), 2)` +
+		`))))`
+}
+
+// boundingBox returns the smallest latitude and longitude ranges holding every
+// point within the radius, so idx_cats_location can narrow the cats down
+// before distances are computed. It reports false when the radius reaches a
+// pole, the longitude range then is the whole world. A longitude range past
+// ±180 crosses the antimeridian and can't be used as is either.
+func boundingBox(near *catentity.NearFilter) (float64, float64, float64, float64, bool) {
+	angle := near.RadiusKm / earthRadiusKm
+	latitude := near.Latitude * math.Pi / 180
+	minLatitude := latitude - angle
+	maxLatitude := latitude + angle
+	if minLatitude <= -math.Pi/2 || maxLatitude >= math.Pi/2 {
+		return 0, 0, 0, 0, false
+	}
+
+	longitudeDelta := math.Asin(math.Sin(angle) / math.Cos(latitude))
+	toDegrees := 180 / math.Pi
+	return minLatitude * toDegrees,
+		maxLatitude * toDegrees,
+		near.Longitude - longitudeDelta*toDegrees,
+		near.Longitude + longitudeDelta*toDegrees,
+		true
+}
+
+// htmlEscaped escapes the markup characters of a text column, so the <mark>
+// tags added by ts_headline are the only markup in a highlight. The parser
+// reads the escapes as entities, not words, so they are never highlighted.
+func htmlEscaped(column string) string {
+	return `replace(replace(replace(replace(` + column + `, '&', '&amp;'), '<', '&lt;'), '>', '&gt;'), '"', '&quot;')`
+}
+
+// catSearchQuery turns search into a tsquery matching cats that have every
+// word of it, each word as a prefix so results show up while typing. Only
+// letters and digits are kept, so the query can't contain tsquery operators.
+func catSearchQuery(search string) string {
+	words := strings.FieldsFunc(strings.ToLower(search), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for i, word := range words {
+		words[i] = word + ":*"
+	}
+	return strings.Join(words, " & ")
 }
 
 func (r *CatRepositoryImpl) GetCatById(ctx context.Context, catId string) (*catentity.Cat, error) {
@@ -248,6 +664,9 @@ func (r *CatRepositoryImpl) GetCatById(ctx context.Context, catId string) (*cate
 			image_urls,
 			has_matched,
 			owner_id,
+			latitude,
+			longitude,
+			city,
 			created_at
 		FROM
 			cats
@@ -256,6 +675,8 @@ func (r *CatRepositoryImpl) GetCatById(ctx context.Context, catId string) (*cate
 			AND is_deleted = false
 	`
 	var cat catentity.Cat
+	var latitude, longitude *float64
+	var city string
 	var createdAt time.Time
 	err := r.DB.QueryRow(ctx, query, catId).Scan(
 		&cat.Id,
@@ -267,6 +688,9 @@ func (r *CatRepositoryImpl) GetCatById(ctx context.Context, catId string) (*cate
 		&cat.ImageUrls,
 		&cat.HasMatched,
 		&cat.OwnerId,
+		&latitude,
+		&longitude,
+		&city,
 		&createdAt,
 	)
 	if errors.Is(err, pgx.ErrNoRows) {
@@ -275,11 +699,12 @@ func (r *CatRepositoryImpl) GetCatById(ctx context.Context, catId string) (*cate
 	if err != nil {
 		return nil, err
 	}
+	cat.Location = newLocation(latitude, longitude, city)
 	cat.CreatedAt = createdAt.Format(time.RFC3339)
 	return &cat, nil
 }
 
-func (r *CatRepositoryImpl) UpdateCatById(ctx context.Context, catId string, cat *catentity.Cat) error {
+func (r *CatRepositoryImpl) UpdateCatById(ctx context.Context, catId string, cat *catentity.Cat, event *evententity.Event) error {
 	query := `
 		UPDATE 
 			cats
@@ -290,27 +715,137 @@ func (r *CatRepositoryImpl) UpdateCatById(ctx context.Context, catId string, cat
 			age_in_month = $4, 
 			description = $5, 
 			image_urls = $6,
+			latitude = $7,
+			longitude = $8,
+			city = $9,
 			updated_at = NOW()
 		WHERE
-			id = $7
+			id = $10
 			AND is_deleted = false
 	`
-	_, err := r.DB.Exec(ctx, query,
+	latitude, longitude, city := locationValues(cat.Location)
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, query,
 		&cat.Name,
 		&cat.Race,
 		&cat.Sex,
 		&cat.AgeInMonth,
 		&cat.Description,
 		&cat.ImageUrls,
+		latitude,
+		longitude,
+		city,
 		catId,
 	)
 	if err != nil {
 		return err
 	}
-	return nil
+
+	err = replaceCatImages(ctx, tx, catId, cat.ImageUrls)
+	if err != nil {
+		return err
+	}
+
+	err = insertEvent(ctx, tx, event)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// PatchCatById only sets the columns present in the patch, so concurrent
+// patches of different fields don't overwrite each other.
+func (r *CatRepositoryImpl) PatchCatById(ctx context.Context, catId string, patch *catentity.PatchCatRequest, event *evententity.Event) error {
+	query := `
+		UPDATE
+			cats
+		SET
+			updated_at = NOW()
+	`
+	args := []interface{}{}
+	argId := 1
+
+	if patch.Name != nil {
+		query += `, name = $` + strconv.Itoa(argId)
+		args = append(args, *patch.Name)
+		argId++
+	}
+
+	if patch.Race != nil {
+		query += `, race = $` + strconv.Itoa(argId)
+		args = append(args, *patch.Race)
+		argId++
+	}
+
+	if patch.Sex != nil {
+		query += `, sex = $` + strconv.Itoa(argId)
+		args = append(args, *patch.Sex)
+		argId++
+	}
+
+	if patch.AgeInMonth != nil {
+		query += `, age_in_month = $` + strconv.Itoa(argId)
+		args = append(args, *patch.AgeInMonth)
+		argId++
+	}
+
+	if patch.Description != nil {
+		query += `, description = $` + strconv.Itoa(argId)
+		args = append(args, *patch.Description)
+		argId++
+	}
+
+	if patch.ImageUrls != nil {
+		query += `, image_urls = $` + strconv.Itoa(argId)
+		args = append(args, *patch.ImageUrls)
+		argId++
+	}
+
+	if patch.Location != nil || patch.ClearLocation {
+		latitude, longitude, city := locationValues(patch.Location)
+		query += `, latitude = $` + strconv.Itoa(argId) + `, longitude = $` + strconv.Itoa(argId+1) + `, city = $` + strconv.Itoa(argId+2)
+		args = append(args, latitude, longitude, city)
+		argId += 3
+	}
+
+	query += ` WHERE id = $` + strconv.Itoa(argId) + ` AND is_deleted = false`
+	args = append(args, catId)
+
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	if patch.ImageUrls != nil {
+		err = replaceCatImages(ctx, tx, catId, *patch.ImageUrls)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = insertEvent(ctx, tx, event)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
 }
 
-func (r *CatRepositoryImpl) DeleteCatById(ctx context.Context, catId string) error {
+// DeleteCatById returns the image urls of the deleted cat so the caller can
+// clean up the stored images.
+func (r *CatRepositoryImpl) DeleteCatById(ctx context.Context, catId string, event *evententity.Event) ([]string, error) {
 	query := `
 		UPDATE
 			cats
@@ -318,10 +853,53 @@ func (r *CatRepositoryImpl) DeleteCatById(ctx context.Context, catId string) err
 			is_deleted = true
 		WHERE
 			id = $1
+			AND is_deleted = false
+		RETURNING
+			image_urls
 	`
-	_, err := r.DB.Exec(ctx, query, catId)
+	tx, err := r.DB.Begin(ctx)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	var imageUrls []string
+	err = tx.QueryRow(ctx, query, catId).Scan(&imageUrls)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = insertEvent(ctx, tx, event)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	return imageUrls, nil
+}
+
+// locationValues splits a location into its columns, all null but the city
+// when there is none.
+func locationValues(location *locationentity.Location) (*float64, *float64, string) {
+	if location == nil {
+		return nil, nil, ""
+	}
+	return location.Latitude, location.Longitude, location.City
+}
+
+func newLocation(latitude, longitude *float64, city string) *locationentity.Location {
+	if latitude == nil || longitude == nil {
+		return nil
+	}
+	return &locationentity.Location{
+		Latitude:  latitude,
+		Longitude: longitude,
+		City:      city,
 	}
-	return nil
 }
@@ -0,0 +1,270 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+
+	"github.com/danzBraham/cats-social/internal/entities/imageentity"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type ImageVariantRepository interface {
+	CreateVariantJob(ctx context.Context, job *imageentity.VariantJob) error
+	ClaimVariantJob(ctx context.Context, maxAttempts int) (*imageentity.VariantJob, error)
+	CompleteVariantJob(ctx context.Context, sourceUrl string, original *imageentity.Image) (bool, error)
+	FailVariantJob(ctx context.Context, sourceUrl string, retry bool) error
+	GetImages(ctx context.Context, sourceUrls []string) (map[string]*imageentity.Image, error)
+	DeleteImageVariants(ctx context.Context, catId string, sourceUrls []string) ([]string, error)
+}
+
+type ImageVariantRepositoryImpl struct {
+	DB *pgxpool.Pool
+}
+
+func NewImageVariantRepository(db *pgxpool.Pool) ImageVariantRepository {
+	return &ImageVariantRepositoryImpl{DB: db}
+}
+
+func (r *ImageVariantRepositoryImpl) CreateVariantJob(ctx context.Context, job *imageentity.VariantJob) error {
+	query := `
+		INSERT INTO
+			image_variants (source_url, source_key, cat_id)
+		VALUES
+			($1, $2, $3)
+	`
+	_, err := r.DB.Exec(ctx, query, job.SourceUrl, job.SourceKey, job.CatId)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// ClaimVariantJob marks the oldest pending job as processing and returns it,
+// or nil when there is nothing to do. Jobs stuck in processing, because a
+// worker died halfway, are picked up again after a few minutes, or given up
+// on when that was their last attempt. SKIP LOCKED lets several workers
+// claim jobs at the same time.
+func (r *ImageVariantRepositoryImpl) ClaimVariantJob(ctx context.Context, maxAttempts int) (*imageentity.VariantJob, error) {
+	failStaleQuery := `
+		UPDATE
+			image_variants
+		SET
+			status = 'failed',
+			updated_at = NOW()
+		WHERE
+			attempts >= $1
+			AND status = 'processing'
+			AND updated_at < NOW() - INTERVAL '5 minutes'
+	`
+	_, err := r.DB.Exec(ctx, failStaleQuery, maxAttempts)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		UPDATE
+			image_variants
+		SET
+			status = 'processing',
+			attempts = attempts + 1,
+			updated_at = NOW()
+		WHERE
+			source_url = (
+				SELECT
+					source_url
+				FROM
+					image_variants
+				WHERE
+					attempts < $1
+					AND (
+						status = 'pending'
+						OR (status = 'processing' AND updated_at < NOW() - INTERVAL '5 minutes')
+					)
+				ORDER BY
+					created_at
+				LIMIT 1
+				FOR UPDATE SKIP LOCKED
+			)
+		RETURNING
+			source_url, source_key, cat_id, attempts
+	`
+	var job imageentity.VariantJob
+	err = r.DB.QueryRow(ctx, query, maxAttempts).Scan(
+		&job.SourceUrl,
+		&job.SourceKey,
+		&job.CatId,
+		&job.Attempts,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// CompleteVariantJob reports false when the image was removed while its
+// variants were being generated.
+func (r *ImageVariantRepositoryImpl) CompleteVariantJob(ctx context.Context, sourceUrl string, original *imageentity.Image) (bool, error) {
+	query := `
+		UPDATE
+			image_variants
+		SET
+			status = 'ready',
+			width = $1,
+			height = $2,
+			thumbnail_url = $3,
+			thumbnail_width = $4,
+			thumbnail_height = $5,
+			medium_url = $6,
+			medium_width = $7,
+			medium_height = $8,
+			updated_at = NOW()
+		WHERE
+			source_url = $9
+	`
+	commandTag, err := r.DB.Exec(ctx, query,
+		original.Width,
+		original.Height,
+		original.Thumbnail.Url,
+		original.Thumbnail.Width,
+		original.Thumbnail.Height,
+		original.Medium.Url,
+		original.Medium.Width,
+		original.Medium.Height,
+		sourceUrl,
+	)
+	if err != nil {
+		return false, err
+	}
+	return commandTag.RowsAffected() > 0, nil
+}
+
+// FailVariantJob puts the job back in the queue when retry is set, otherwise
+// it is given up on and the image is served without variants.
+func (r *ImageVariantRepositoryImpl) FailVariantJob(ctx context.Context, sourceUrl string, retry bool) error {
+	query := `
+		UPDATE
+			image_variants
+		SET
+			status = $1,
+			updated_at = NOW()
+		WHERE
+			source_url = $2
+	`
+	status := imageentity.Failed
+	if retry {
+		status = imageentity.Pending
+	}
+	_, err := r.DB.Exec(ctx, query, status, sourceUrl)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetImages returns the processed images among sourceUrls, keyed by url.
+func (r *ImageVariantRepositoryImpl) GetImages(ctx context.Context, sourceUrls []string) (map[string]*imageentity.Image, error) {
+	images := map[string]*imageentity.Image{}
+	if len(sourceUrls) == 0 {
+		return images, nil
+	}
+
+	query := `
+		SELECT
+			source_url,
+			width,
+			height,
+			thumbnail_url,
+			thumbnail_width,
+			thumbnail_height,
+			medium_url,
+			medium_width,
+			medium_height
+		FROM
+			image_variants
+		WHERE
+			source_url = ANY($1)
+			AND status = 'ready'
+	`
+	rows, err := r.DB.Query(ctx, query, sourceUrls)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		image := imageentity.Image{
+			Thumbnail: &imageentity.Variant{},
+			Medium:    &imageentity.Variant{},
+		}
+		err := rows.Scan(
+			&image.Url,
+			&image.Width,
+			&image.Height,
+			&image.Thumbnail.Url,
+			&image.Thumbnail.Width,
+			&image.Thumbnail.Height,
+			&image.Medium.Url,
+			&image.Medium.Width,
+			&image.Medium.Height,
+		)
+		if err != nil {
+			return nil, err
+		}
+		images[image.Url] = &image
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return images, nil
+}
+
+// DeleteImageVariants removes the rows of the given images uploaded for the
+// cat and returns the urls of their generated variants, so the caller can
+// delete the blobs.
+func (r *ImageVariantRepositoryImpl) DeleteImageVariants(ctx context.Context, catId string, sourceUrls []string) ([]string, error) {
+	variantUrls := []string{}
+	if len(sourceUrls) == 0 {
+		return variantUrls, nil
+	}
+
+	query := `
+		DELETE FROM
+			image_variants
+		WHERE
+			source_url = ANY($1)
+			AND cat_id = $2
+		RETURNING
+			COALESCE(thumbnail_url, ''),
+			COALESCE(medium_url, '')
+	`
+	rows, err := r.DB.Query(ctx, query, sourceUrls, catId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var thumbnailUrl, mediumUrl string
+		if err := rows.Scan(&thumbnailUrl, &mediumUrl); err != nil {
+			return nil, err
+		}
+		if thumbnailUrl != "" {
+			variantUrls = append(variantUrls, thumbnailUrl)
+		}
+		if mediumUrl != "" {
+			variantUrls = append(variantUrls, mediumUrl)
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return variantUrls, nil
+}
@@ -0,0 +1,185 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/danzBraham/cats-social/internal/entities/loginattemptentity"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type LoginAttemptRepository interface {
+	GetLoginAttempt(ctx context.Context, key string) (*loginattemptentity.LoginAttempt, error)
+	RecordFailedLoginAttempt(ctx context.Context, key string, window time.Duration) (*loginattemptentity.LoginAttempt, error)
+	LockLoginAttempt(ctx context.Context, key string, duration time.Duration) error
+	ResetLoginAttempt(ctx context.Context, key string) error
+}
+
+// LoginAttemptRepositoryImpl keeps the counters in Postgres so every replica
+// sees the same failures.
+type LoginAttemptRepositoryImpl struct {
+	DB *pgxpool.Pool
+}
+
+func NewLoginAttemptRepository(db *pgxpool.Pool) LoginAttemptRepository {
+	return &LoginAttemptRepositoryImpl{DB: db}
+}
+
+func (r *LoginAttemptRepositoryImpl) GetLoginAttempt(ctx context.Context, key string) (*loginattemptentity.LoginAttempt, error) {
+	query := `
+		SELECT
+			failures,
+			COALESCE(GREATEST(EXTRACT(EPOCH FROM locked_until - NOW()), 0), 0)
+		FROM
+			login_attempts
+		WHERE
+			key = $1
+	`
+	attempt := &loginattemptentity.LoginAttempt{Key: key}
+	var lockedForSeconds float64
+	err := r.DB.QueryRow(ctx, query, key).Scan(&attempt.Failures, &lockedForSeconds)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return attempt, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	attempt.LockedFor = time.Duration(lockedForSeconds * float64(time.Second))
+	return attempt, nil
+}
+
+func (r *LoginAttemptRepositoryImpl) RecordFailedLoginAttempt(ctx context.Context, key string, window time.Duration) (*loginattemptentity.LoginAttempt, error) {
+	query := `
+		INSERT INTO
+			login_attempts (key, failures, last_failed_at)
+		VALUES
+			($1, 1, NOW())
+		ON CONFLICT (key) DO UPDATE SET
+			failures = CASE
+				WHEN login_attempts.last_failed_at < NOW() - make_interval(secs => $2) THEN 1
+				ELSE login_attempts.failures + 1
+			END,
+			last_failed_at = NOW()
+		RETURNING
+			failures
+	`
+	attempt := &loginattemptentity.LoginAttempt{Key: key}
+	err := r.DB.QueryRow(ctx, query, key, window.Seconds()).Scan(&attempt.Failures)
+	if err != nil {
+		return nil, err
+	}
+	return attempt, nil
+}
+
+func (r *LoginAttemptRepositoryImpl) LockLoginAttempt(ctx context.Context, key string, duration time.Duration) error {
+	query := `
+		UPDATE
+			login_attempts
+		SET
+			locked_until = NOW() + make_interval(secs => $1)
+		WHERE
+			key = $2
+	`
+	_, err := r.DB.Exec(ctx, query, duration.Seconds(), key)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *LoginAttemptRepositoryImpl) ResetLoginAttempt(ctx context.Context, key string) error {
+	query := `
+		DELETE FROM
+			login_attempts
+		WHERE
+			key = $1
+	`
+	_, err := r.DB.Exec(ctx, query, key)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// MemoryLoginAttemptRepository keeps the counters in process memory. It is
+// only suitable when a single server instance is running.
+type MemoryLoginAttemptRepository struct {
+	mu       sync.Mutex
+	attempts map[string]*memoryLoginAttempt
+}
+
+type memoryLoginAttempt struct {
+	failures     int
+	lastFailedAt time.Time
+	lockedUntil  time.Time
+}
+
+// memoryLoginAttemptPruneSize is the number of tracked keys above which stale
+// entries are dropped, so a flood of distinct IPs can't grow the map forever.
+const memoryLoginAttemptPruneSize = 10000
+
+func NewMemoryLoginAttemptRepository() LoginAttemptRepository {
+	return &MemoryLoginAttemptRepository{attempts: map[string]*memoryLoginAttempt{}}
+}
+
+func (r *MemoryLoginAttemptRepository) GetLoginAttempt(ctx context.Context, key string) (*loginattemptentity.LoginAttempt, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	attempt := &loginattemptentity.LoginAttempt{Key: key}
+	if entry, ok := r.attempts[key]; ok {
+		attempt.Failures = entry.failures
+		if lockedFor := time.Until(entry.lockedUntil); lockedFor > 0 {
+			attempt.LockedFor = lockedFor
+		}
+	}
+	return attempt, nil
+}
+
+func (r *MemoryLoginAttemptRepository) RecordFailedLoginAttempt(ctx context.Context, key string, window time.Duration) (*loginattemptentity.LoginAttempt, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	if len(r.attempts) > memoryLoginAttemptPruneSize {
+		for k, entry := range r.attempts {
+			if now.Sub(entry.lastFailedAt) > window && now.After(entry.lockedUntil) {
+				delete(r.attempts, k)
+			}
+		}
+	}
+
+	entry, ok := r.attempts[key]
+	if !ok {
+		entry = &memoryLoginAttempt{}
+		r.attempts[key] = entry
+	}
+	if now.Sub(entry.lastFailedAt) > window {
+		entry.failures = 0
+	}
+	entry.failures++
+	entry.lastFailedAt = now
+
+	return &loginattemptentity.LoginAttempt{Key: key, Failures: entry.failures}, nil
+}
+
+func (r *MemoryLoginAttemptRepository) LockLoginAttempt(ctx context.Context, key string, duration time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if entry, ok := r.attempts[key]; ok {
+		entry.lockedUntil = time.Now().Add(duration)
+	}
+	return nil
+}
+
+func (r *MemoryLoginAttemptRepository) ResetLoginAttempt(ctx context.Context, key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.attempts, key)
+	return nil
+}
@@ -0,0 +1,109 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/danzBraham/cats-social/internal/entities/userentity"
+	"github.com/danzBraham/cats-social/internal/errors/usererror"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type LoginChallengeRepository interface {
+	CreateLoginChallenge(ctx context.Context, loginChallenge *userentity.LoginChallenge, ttl time.Duration) error
+	GetLoginChallengeUserId(ctx context.Context, tokenHash string) (string, error)
+	ConsumeLoginChallenge(ctx context.Context, tokenHash string) error
+}
+
+type LoginChallengeRepositoryImpl struct {
+	DB *pgxpool.Pool
+}
+
+func NewLoginChallengeRepository(db *pgxpool.Pool) LoginChallengeRepository {
+	return &LoginChallengeRepositoryImpl{DB: db}
+}
+
+// CreateLoginChallenge also removes the expired challenges of the user, so
+// abandoned logins don't pile up.
+func (r *LoginChallengeRepositoryImpl) CreateLoginChallenge(ctx context.Context, loginChallenge *userentity.LoginChallenge, ttl time.Duration) error {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	deleteExpiredQuery := `
+		DELETE FROM
+			login_challenges
+		WHERE
+			user_id = $1
+			AND expires_at <= NOW()
+	`
+	_, err = tx.Exec(ctx, deleteExpiredQuery, loginChallenge.UserId)
+	if err != nil {
+		return err
+	}
+
+	insertQuery := `
+		INSERT INTO
+			login_challenges (id, user_id, token_hash, expires_at)
+		VALUES
+			($1, $2, $3, NOW() + make_interval(secs => $4))
+	`
+	_, err = tx.Exec(ctx, insertQuery,
+		&loginChallenge.Id,
+		&loginChallenge.UserId,
+		&loginChallenge.TokenHash,
+		ttl.Seconds(),
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// GetLoginChallengeUserId returns the id of the user an unexpired challenge
+// was issued for, without using it up, so a mistyped code can be retried.
+func (r *LoginChallengeRepositoryImpl) GetLoginChallengeUserId(ctx context.Context, tokenHash string) (string, error) {
+	query := `
+		SELECT
+			user_id
+		FROM
+			login_challenges
+		WHERE
+			token_hash = $1
+			AND expires_at > NOW()
+	`
+	var userId string
+	err := r.DB.QueryRow(ctx, query, tokenHash).Scan(&userId)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", usererror.ErrInvalidChallengeToken
+	}
+	if err != nil {
+		return "", err
+	}
+	return userId, nil
+}
+
+// ConsumeLoginChallenge deletes the challenge. Only one of two concurrent
+// logins with the same challenge gets to delete it, the other one fails.
+func (r *LoginChallengeRepositoryImpl) ConsumeLoginChallenge(ctx context.Context, tokenHash string) error {
+	query := `
+		DELETE FROM
+			login_challenges
+		WHERE
+			token_hash = $1
+			AND expires_at > NOW()
+	`
+	tag, err := r.DB.Exec(ctx, query, tokenHash)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return usererror.ErrInvalidChallengeToken
+	}
+	return nil
+}
@@ -3,26 +3,43 @@ package repositories
 import (
 	"context"
 	"errors"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/danzBraham/cats-social/internal/entities/evententity"
 	"github.com/danzBraham/cats-social/internal/entities/matchentity"
+	"github.com/danzBraham/cats-social/internal/errors/matcherror"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// MatchEventFunc builds the event of a match request that was changed as a
+// side effect of another change, which is only known inside its transaction.
+type MatchEventFunc func(parties *matchentity.MatchParties) (*evententity.Event, error)
+
 type MatchRepository interface {
 	IsMatchIdExists(ctx context.Context, matchId string) (bool, error)
 	IsMatchIdValid(ctx context.Context, matchId string) (bool, error)
 	IsMatchIssuer(ctx context.Context, matchId, userId string) (bool, error)
+	IsMatchExpired(ctx context.Context, matchId string, ttl time.Duration) (bool, error)
+	IsMatchApproved(ctx context.Context, matchId string) (bool, error)
+	IsMatchParticipant(ctx context.Context, matchId, userId string) (bool, error)
+	IsMatchThreadWritable(ctx context.Context, matchId string) (bool, error)
+	GetMatchParties(ctx context.Context, matchId string) (*matchentity.MatchParties, error)
 	IsBothCatsHaveSameGender(ctx context.Context, matchCatId, userCatId string) (bool, error)
 	IsBothCatsAlreadyMatched(ctx context.Context, matchCatId, userCatId string) (bool, error)
 	IsOwnerOfBothCats(ctx context.Context, matchCatId, userCatId string) (bool, error)
 	IsMatchRequestExists(ctx context.Context, matchCatId, userCatId string) (bool, error)
-	CreateMatch(ctx context.Context, matchCat *matchentity.Match) error
-	GetMatches(ctx context.Context, userId string) ([]*matchentity.GetMatchResponse, error)
-	ApproveMatch(ctx context.Context, matchId string) error
-	RejectMatch(ctx context.Context, matchId string) error
-	DeleteMatch(ctx context.Context, matchId string) error
+	CreateMatch(ctx context.Context, matchCat *matchentity.Match, event *evententity.Event) error
+	GetMatches(ctx context.Context, userId string, params *matchentity.MatchQueryParams) ([]*matchentity.GetMatchResponse, *matchentity.MatchCursor, error)
+	GetMatchById(ctx context.Context, userId, matchId string) (*matchentity.GetMatchResponse, error)
+	ApproveMatch(ctx context.Context, matchId string, event *evententity.Event, removedEvent MatchEventFunc) ([]*matchentity.MatchParties, error)
+	RejectMatch(ctx context.Context, matchId string, event *evententity.Event) error
+	UnmatchMatch(ctx context.Context, matchId, userId string, event *evententity.Event) error
+	DeleteMatch(ctx context.Context, matchId string, event *evententity.Event) error
+	GetStaleMatches(ctx context.Context, ttl time.Duration) ([]*matchentity.MatchParties, error)
+	ExpireMatch(ctx context.Context, matchId string, event *evententity.Event) (bool, error)
 }
 
 type MatchRepositoryImpl struct {
@@ -99,6 +116,139 @@ func (r *MatchRepositoryImpl) IsMatchIssuer(ctx context.Context, matchId, userId
 	return true, nil
 }
 
+// IsMatchExpired also reports pending requests older than ttl that the
+// expiry scheduler hasn't reached yet.
+func (r *MatchRepositoryImpl) IsMatchExpired(ctx context.Context, matchId string, ttl time.Duration) (bool, error) {
+	query := `
+		SELECT
+			status = 'expired'
+			OR (status = 'pending' AND created_at < LOCALTIMESTAMP - make_interval(secs => $2))
+		FROM
+			match_requests
+		WHERE
+			id = $1
+	`
+	var result bool
+	err := r.DB.QueryRow(ctx, query, matchId, ttl.Seconds()).Scan(&result)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return result, nil
+}
+
+func (r *MatchRepositoryImpl) IsMatchApproved(ctx context.Context, matchId string) (bool, error) {
+	query := `
+		SELECT
+			1
+		FROM
+			match_requests
+		WHERE
+			id = $1
+			AND status = 'approved'
+			AND is_deleted = false
+	`
+	var exists int
+	err := r.DB.QueryRow(ctx, query, matchId).Scan(&exists)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// IsMatchParticipant reports whether the user owns either cat of the match.
+func (r *MatchRepositoryImpl) IsMatchParticipant(ctx context.Context, matchId, userId string) (bool, error) {
+	query := `
+		SELECT
+			1
+		FROM
+			match_requests mr
+		JOIN
+			cats mc ON mr.match_cat_id = mc.id
+		JOIN
+			cats uc ON mr.user_cat_id = uc.id
+		WHERE
+			mr.id = $1
+			AND (mc.owner_id = $2 OR uc.owner_id = $2)
+	`
+	var exists int
+	err := r.DB.QueryRow(ctx, query, matchId, userId).Scan(&exists)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// IsMatchThreadWritable reports whether messages can still be sent in the
+// thread of the match, which is only while it's pending or approved.
+func (r *MatchRepositoryImpl) IsMatchThreadWritable(ctx context.Context, matchId string) (bool, error) {
+	query := `
+		SELECT
+			1
+		FROM
+			match_requests
+		WHERE
+			id = $1
+			AND status IN ('pending', 'approved')
+			AND is_deleted = false
+	`
+	var exists int
+	err := r.DB.QueryRow(ctx, query, matchId).Scan(&exists)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// GetMatchParties returns the cats of the match, their owners and the status
+// of the match.
+func (r *MatchRepositoryImpl) GetMatchParties(ctx context.Context, matchId string) (*matchentity.MatchParties, error) {
+	query := `
+		SELECT
+			mr.id,
+			mr.match_cat_id,
+			mr.user_cat_id,
+			uc.owner_id,
+			mc.owner_id,
+			mr.status
+		FROM
+			match_requests mr
+		JOIN
+			cats mc ON mr.match_cat_id = mc.id
+		JOIN
+			cats uc ON mr.user_cat_id = uc.id
+		WHERE
+			mr.id = $1
+	`
+	parties := &matchentity.MatchParties{}
+	err := r.DB.QueryRow(ctx, query, matchId).Scan(
+		&parties.Id,
+		&parties.MatchCatId,
+		&parties.UserCatId,
+		&parties.IssuerId,
+		&parties.ReceiverId,
+		&parties.Status,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, matcherror.ErrMatchIdNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parties, nil
+}
+
 func (r *MatchRepositoryImpl) IsBothCatsHaveSameGender(ctx context.Context, matchCatId, userCatId string) (bool, error) {
 	query := `
 		SELECT
@@ -123,12 +273,13 @@ func (r *MatchRepositoryImpl) IsBothCatsHaveSameGender(ctx context.Context, matc
 func (r *MatchRepositoryImpl) IsBothCatsAlreadyMatched(ctx context.Context, matchCatId, userCatId string) (bool, error) {
 	query := `
 		SELECT
-			status = 'approved'
+			true
 		FROM
 			match_requests
 		WHERE
 			(match_cat_id = $1 OR user_cat_id = $1)
 			AND (match_cat_id = $2 OR user_cat_id = $2)
+			AND status = 'approved'
 	`
 	var result bool
 	err := r.DB.QueryRow(ctx, query, matchCatId, userCatId).Scan(&result)
@@ -185,14 +336,20 @@ func (r *MatchRepositoryImpl) IsMatchRequestExists(ctx context.Context, matchCat
 	return true, nil
 }
 
-func (r *MatchRepositoryImpl) CreateMatch(ctx context.Context, matchCat *matchentity.Match) error {
+func (r *MatchRepositoryImpl) CreateMatch(ctx context.Context, matchCat *matchentity.Match, event *evententity.Event) error {
 	query := `
 		INSERT INTO
 			match_requests (id, match_cat_id, user_cat_id, message)
 		VALUES
 			($1, $2, $3, $4)
 	`
-	_, err := r.DB.Exec(ctx, query,
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, query,
 		&matchCat.Id,
 		&matchCat.MatchCatId,
 		&matchCat.UserCatId,
@@ -201,107 +358,224 @@ func (r *MatchRepositoryImpl) CreateMatch(ctx context.Context, matchCat *matchen
 	if err != nil {
 		return err
 	}
-	return nil
+
+	err = insertEvent(ctx, tx, event)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
 }
 
-func (r *MatchRepositoryImpl) GetMatches(ctx context.Context, userId string) ([]*matchentity.GetMatchResponse, error) {
-	query := `
-		SELECT
-			mr.id,
-			u.name AS issuer_name,
-			u.email AS issuer_email,
-			u.created_at AS issuer_created_at,
-			mc.id AS mc_id,
-			mc.name AS mc_name,
-			mc.race AS mc_race,
-			mc.sex AS mc_sex,
-			mc.description AS mc_description,
-			mc.age_in_month AS mc_age_in_month,
-			mc.image_urls AS mc_image_urls,
-			mc.has_matched AS mc_has_matched,
-			mc.created_at AS mc_created_at,
-			uc.id AS uc_id,
-			uc.name AS uc_name,
-			uc.race AS uc_race,
-			uc.sex AS uc_sex,
-			uc.description AS uc_description,
-			uc.age_in_month AS uc_age_in_month,
-			uc.image_urls AS uc_image_urls,
-			uc.has_matched AS uc_has_matched,
-			uc.created_at AS uc_created_at,
-			mr.message,
-			mr.created_at
-		FROM
-			match_requests mr
-		JOIN
-			cats mc ON mr.match_cat_id = mc.id
-		JOIN
-			cats uc ON mr.user_cat_id = uc.id
-		JOIN
-			users u ON uc.owner_id = u.id
-		WHERE
-			(mc.owner_id = $1 OR uc.owner_id = $1)
-			AND mr.is_deleted = false
-		ORDER BY
-			created_at
-	`
-	rows, err := r.DB.Query(ctx, query, userId)
+// matchColumns selects a match request together with both cats and their
+// owners. $1 must be the id of the user reading it, for the direction.
+const matchColumns = `
+	SELECT
+		mr.id,
+		mr.status,
+		CASE WHEN uc.owner_id = $1 THEN 'outgoing' ELSE 'incoming' END AS direction,
+		u.name AS issuer_name,
+		u.email AS issuer_email,
+		u.created_at AS issuer_created_at,
+		ru.name AS receiver_name,
+		ru.email AS receiver_email,
+		ru.created_at AS receiver_created_at,
+		mc.id AS mc_id,
+		mc.name AS mc_name,
+		mc.race AS mc_race,
+		mc.sex AS mc_sex,
+		mc.description AS mc_description,
+		mc.age_in_month AS mc_age_in_month,
+		mc.image_urls AS mc_image_urls,
+		mc.has_matched AS mc_has_matched,
+		mc.city AS mc_city,
+		mc.created_at AS mc_created_at,
+		uc.id AS uc_id,
+		uc.name AS uc_name,
+		uc.race AS uc_race,
+		uc.sex AS uc_sex,
+		uc.description AS uc_description,
+		uc.age_in_month AS uc_age_in_month,
+		uc.image_urls AS uc_image_urls,
+		uc.has_matched AS uc_has_matched,
+		uc.city AS uc_city,
+		uc.created_at AS uc_created_at,
+		mr.message,
+		ub.name AS unmatched_by_name,
+		ub.email AS unmatched_by_email,
+		mr.unmatched_at,
+		mr.created_at
+	FROM
+		match_requests mr
+	JOIN
+		cats mc ON mr.match_cat_id = mc.id
+	JOIN
+		cats uc ON mr.user_cat_id = uc.id
+	JOIN
+		users u ON uc.owner_id = u.id
+	JOIN
+		users ru ON mc.owner_id = ru.id
+	LEFT JOIN
+		users ub ON mr.unmatched_by = ub.id
+	WHERE
+		(mc.owner_id = $1 OR uc.owner_id = $1)
+		AND mr.is_deleted = false
+`
+
+// scanMatch scans a row selected with matchColumns and returns the raw
+// creation time of the request for the cursor.
+func scanMatch(row pgx.Row) (*matchentity.GetMatchResponse, time.Time, error) {
+	var match matchentity.GetMatchResponse
+	var issuerCreatedAt, receiverCreatedAt, matchCatCreatedAt, userCatCreatedAt, matchCreatedAt time.Time
+	var unmatchedByName, unmatchedByEmail *string
+	var unmatchedAt *time.Time
+	err := row.Scan(
+		&match.Id,
+		&match.Status,
+		&match.Direction,
+		&match.IssuedBy.Name,
+		&match.IssuedBy.Email,
+		&issuerCreatedAt,
+		&match.ReceivedBy.Name,
+		&match.ReceivedBy.Email,
+		&receiverCreatedAt,
+		&match.MatchCatDetail.Id,
+		&match.MatchCatDetail.Name,
+		&match.MatchCatDetail.Race,
+		&match.MatchCatDetail.Sex,
+		&match.MatchCatDetail.Description,
+		&match.MatchCatDetail.AgeInMonth,
+		&match.MatchCatDetail.ImageUrls,
+		&match.MatchCatDetail.HasMatched,
+		&match.MatchCatDetail.City,
+		&matchCatCreatedAt,
+		&match.UserCatDetail.Id,
+		&match.UserCatDetail.Name,
+		&match.UserCatDetail.Race,
+		&match.UserCatDetail.Sex,
+		&match.UserCatDetail.Description,
+		&match.UserCatDetail.AgeInMonth,
+		&match.UserCatDetail.ImageUrls,
+		&match.UserCatDetail.HasMatched,
+		&match.UserCatDetail.City,
+		&userCatCreatedAt,
+		&match.Message,
+		&unmatchedByName,
+		&unmatchedByEmail,
+		&unmatchedAt,
+		&matchCreatedAt,
+	)
 	if err != nil {
-		return nil, err
+		return nil, time.Time{}, err
+	}
+	match.IssuedBy.CreatedAt = issuerCreatedAt.Format(time.RFC3339)
+	match.ReceivedBy.CreatedAt = receiverCreatedAt.Format(time.RFC3339)
+	match.MatchCatDetail.CreatedAt = matchCatCreatedAt.Format(time.RFC3339)
+	match.UserCatDetail.CreatedAt = userCatCreatedAt.Format(time.RFC3339)
+	match.CreatedAt = matchCreatedAt.Format(time.RFC3339)
+	if unmatchedAt != nil {
+		match.UnmatchedBy = &matchentity.UnmatchDetail{UnmatchedAt: unmatchedAt.Format(time.RFC3339)}
+		// the user who ended the match may have deleted their account since
+		if unmatchedByName != nil && unmatchedByEmail != nil {
+			match.UnmatchedBy.Name = *unmatchedByName
+			match.UnmatchedBy.Email = *unmatchedByEmail
+		}
+	}
+	return &match, matchCreatedAt, nil
+}
+
+func (r *MatchRepositoryImpl) GetMatches(ctx context.Context, userId string, params *matchentity.MatchQueryParams) ([]*matchentity.GetMatchResponse, *matchentity.MatchCursor, error) {
+	query := matchColumns
+	args := []interface{}{userId}
+	argId := 2
+
+	if len(params.Statuses) > 0 {
+		placeholders := make([]string, 0, len(params.Statuses))
+		for _, status := range params.Statuses {
+			placeholders = append(placeholders, `$`+strconv.Itoa(argId))
+			args = append(args, status)
+			argId++
+		}
+		query += ` AND mr.status IN (` + strings.Join(placeholders, ", ") + `)`
+	}
+
+	switch params.Direction {
+	case matchentity.Incoming:
+		query += ` AND mc.owner_id = $1`
+	case matchentity.Outgoing:
+		query += ` AND uc.owner_id = $1`
+	}
+
+	if params.CatId != "" {
+		query += ` AND (mr.match_cat_id = $` + strconv.Itoa(argId) + ` OR mr.user_cat_id = $` + strconv.Itoa(argId) + `)`
+		args = append(args, params.CatId)
+		argId++
+	}
+
+	if params.Cursor != nil {
+		query += ` AND (mr.created_at, mr.id) < ($` + strconv.Itoa(argId) + `, $` + strconv.Itoa(argId+1) + `)`
+		args = append(args, params.Cursor.CreatedAt, params.Cursor.Id)
+		argId += 2
+	}
+
+	// one extra row tells whether there is a next page
+	query += ` ORDER BY mr.created_at DESC, mr.id DESC LIMIT $` + strconv.Itoa(argId)
+	args = append(args, params.Limit+1)
+
+	rows, err := r.DB.Query(ctx, query, args...)
+	if err != nil {
+		return nil, nil, err
 	}
 	defer rows.Close()
 
-	matches := []*matchentity.GetMatchResponse{}
+	matches := make([]*matchentity.GetMatchResponse, 0, params.Limit+1)
+	var next *matchentity.MatchCursor
 	for rows.Next() {
-		var match matchentity.GetMatchResponse
-		var issuerCreatedAt, matchCatCreatedAt, userCatCreatedAt, matchCreatedAt time.Time
-		err := rows.Scan(
-			&match.Id,
-			&match.IssuedBy.Name,
-			&match.IssuedBy.Email,
-			&issuerCreatedAt,
-			&match.MatchCatDetail.Id,
-			&match.MatchCatDetail.Name,
-			&match.MatchCatDetail.Race,
-			&match.MatchCatDetail.Sex,
-			&match.MatchCatDetail.Description,
-			&match.MatchCatDetail.AgeInMonth,
-			&match.MatchCatDetail.ImageUrls,
-			&match.MatchCatDetail.HasMatched,
-			&matchCatCreatedAt,
-			&match.UserCatDetail.Id,
-			&match.UserCatDetail.Name,
-			&match.UserCatDetail.Race,
-			&match.UserCatDetail.Sex,
-			&match.UserCatDetail.Description,
-			&match.UserCatDetail.AgeInMonth,
-			&match.UserCatDetail.ImageUrls,
-			&match.UserCatDetail.HasMatched,
-			&userCatCreatedAt,
-			&match.Message,
-			&matchCreatedAt,
-		)
+		match, createdAt, err := scanMatch(rows)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
+		}
+
+		if len(matches) == params.Limit-1 {
+			next = &matchentity.MatchCursor{
+				CreatedAt: createdAt,
+				Id:        match.Id,
+			}
 		}
-		match.IssuedBy.CreatedAt = issuerCreatedAt.Format(time.RFC3339)
-		match.MatchCatDetail.CreatedAt = matchCatCreatedAt.Format(time.RFC3339)
-		match.UserCatDetail.CreatedAt = userCatCreatedAt.Format(time.RFC3339)
-		match.CreatedAt = matchCreatedAt.Format(time.RFC3339)
-		matches = append(matches, &match)
+
+		matches = append(matches, match)
 	}
 
 	if err = rows.Err(); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	return matches, nil
+	if len(matches) <= params.Limit {
+		return matches, nil, nil
+	}
+
+	return matches[:params.Limit], next, nil
+}
+
+func (r *MatchRepositoryImpl) GetMatchById(ctx context.Context, userId, matchId string) (*matchentity.GetMatchResponse, error) {
+	query := matchColumns + ` AND mr.id = $2`
+	match, _, err := scanMatch(r.DB.QueryRow(ctx, query, userId, matchId))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, matcherror.ErrMatchIdNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return match, nil
 }
 
-func (r *MatchRepositoryImpl) ApproveMatch(ctx context.Context, matchId string) error {
+// ApproveMatch returns the other pending requests of the two cats, which are
+// removed along with the approval. Each of them gets the event built by
+// removedEvent.
+func (r *MatchRepositoryImpl) ApproveMatch(ctx context.Context, matchId string, event *evententity.Event, removedEvent MatchEventFunc) ([]*matchentity.MatchParties, error) {
 	tx, err := r.DB.Begin(ctx)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer tx.Rollback(ctx)
 
@@ -313,13 +587,18 @@ func (r *MatchRepositoryImpl) ApproveMatch(ctx context.Context, matchId string)
 			status = 'approved'
 		WHERE 
 			id = $1
+			AND status = 'pending'
+			AND is_deleted = false
 		RETURNING 
 			match_cat_id, user_cat_id
 	`
 	var matchCatId, userCatId string
 	err = tx.QueryRow(ctx, approveQuery, matchId).Scan(&matchCatId, &userCatId)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, r.notPendingError(ctx, matchId)
+	}
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// update has_matched column for both cats
@@ -333,32 +612,77 @@ func (r *MatchRepositoryImpl) ApproveMatch(ctx context.Context, matchId string)
 	`
 	_, err = tx.Exec(ctx, updateCatsQuery, matchCatId, userCatId)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	// remove other match requests for the involved cats
+	// remove the other pending match requests of the involved cats, decided,
+	// expired and unmatched requests stay as their history
 	removeOtherMatchRequestQuery := `
 		UPDATE
-			match_requests
+			match_requests mr
 		SET
 			is_deleted = true
+		FROM
+			cats mc,
+			cats uc
 		WHERE
-			(match_cat_id = $1 OR user_cat_id = $1 OR match_cat_id = $2 OR user_cat_id = $2)
-			AND status != 'approved'
+			mr.match_cat_id = mc.id
+			AND mr.user_cat_id = uc.id
+			AND (mr.match_cat_id = $1 OR mr.user_cat_id = $1 OR mr.match_cat_id = $2 OR mr.user_cat_id = $2)
+			AND mr.status = 'pending'
+			AND mr.is_deleted = false
+		RETURNING
+			mr.id,
+			mr.match_cat_id,
+			mr.user_cat_id,
+			uc.owner_id,
+			mc.owner_id,
+			mr.status
 	`
-	_, err = tx.Exec(ctx, removeOtherMatchRequestQuery, matchCatId, userCatId)
+	rows, err := tx.Query(ctx, removeOtherMatchRequestQuery, matchCatId, userCatId)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	removed, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*matchentity.MatchParties, error) {
+		var parties matchentity.MatchParties
+		err := row.Scan(
+			&parties.Id,
+			&parties.MatchCatId,
+			&parties.UserCatId,
+			&parties.IssuerId,
+			&parties.ReceiverId,
+			&parties.Status,
+		)
+		return &parties, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = insertEvent(ctx, tx, event)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, parties := range removed {
+		removedMatchEvent, err := removedEvent(parties)
+		if err != nil {
+			return nil, err
+		}
+		err = insertEvent(ctx, tx, removedMatchEvent)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	if err = tx.Commit(ctx); err != nil {
-		return err
+		return nil, err
 	}
 
-	return nil
+	return removed, nil
 }
 
-func (r *MatchRepositoryImpl) RejectMatch(ctx context.Context, matchId string) error {
+func (r *MatchRepositoryImpl) RejectMatch(ctx context.Context, matchId string, event *evententity.Event) error {
 	query := `
 		UPDATE 
 			match_requests
@@ -366,15 +690,114 @@ func (r *MatchRepositoryImpl) RejectMatch(ctx context.Context, matchId string) e
 			status = 'rejected'
 		WHERE 
 			id = $1
+			AND status = 'pending'
+			AND is_deleted = false
+	`
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, query, matchId)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return r.notPendingError(ctx, matchId)
+	}
+
+	err = insertEvent(ctx, tx, event)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// notPendingError tells why a request that was pending when it was checked
+// could not be decided or withdrawn: the expiry worker got to it first, or it
+// was decided or withdrawn in the meantime.
+func (r *MatchRepositoryImpl) notPendingError(ctx context.Context, matchId string) error {
+	query := `
+		SELECT
+			status
+		FROM
+			match_requests
+		WHERE
+			id = $1
+	`
+	var status matchentity.Status
+	err := r.DB.QueryRow(ctx, query, matchId).Scan(&status)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return err
+	}
+	if status == matchentity.Expired {
+		return matcherror.ErrMatchRequestExpired
+	}
+	return matcherror.ErrMatchIdIsNoLongerValid
+}
+
+// UnmatchMatch ends an approved match. The request is kept with who ended it
+// and when, and both cats can be matched again.
+func (r *MatchRepositoryImpl) UnmatchMatch(ctx context.Context, matchId, userId string, event *evententity.Event) error {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	unmatchQuery := `
+		UPDATE
+			match_requests
+		SET
+			status = 'unmatched',
+			unmatched_by = $2,
+			unmatched_at = NOW(),
+			updated_at = NOW()
+		WHERE
+			id = $1
+			AND status = 'approved'
+		RETURNING
+			match_cat_id, user_cat_id
+	`
+	var matchCatId, userCatId string
+	err = tx.QueryRow(ctx, unmatchQuery, matchId, userId).Scan(&matchCatId, &userCatId)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return matcherror.ErrMatchIsNotApproved
+	}
+	if err != nil {
+		return err
+	}
+
+	updateCatsQuery := `
+		UPDATE
+			cats
+		SET
+			has_matched = false
+		WHERE
+			id IN ($1, $2)
 	`
-	_, err := r.DB.Exec(ctx, query, matchId)
+	_, err = tx.Exec(ctx, updateCatsQuery, matchCatId, userCatId)
 	if err != nil {
 		return err
 	}
+
+	err = insertEvent(ctx, tx, event)
+	if err != nil {
+		return err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return err
+	}
+
 	return nil
 }
 
-func (r *MatchRepositoryImpl) DeleteMatch(ctx context.Context, matchId string) error {
+// DeleteMatch withdraws a request that is still pending. An approved request
+// is ended with UnmatchMatch instead, so its cats are freed.
+func (r *MatchRepositoryImpl) DeleteMatch(ctx context.Context, matchId string, event *evententity.Event) error {
 	query := `
 		UPDATE
 			match_requests
@@ -382,10 +805,120 @@ func (r *MatchRepositoryImpl) DeleteMatch(ctx context.Context, matchId string) e
 			is_deleted = true
 		WHERE
 			id = $1
+			AND status = 'pending'
+			AND is_deleted = false
 	`
-	_, err := r.DB.Exec(ctx, query, matchId)
+	tx, err := r.DB.Begin(ctx)
 	if err != nil {
 		return err
 	}
-	return nil
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, query, matchId)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return r.notPendingError(ctx, matchId)
+	}
+
+	err = insertEvent(ctx, tx, event)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// GetStaleMatches returns the pending requests older than ttl.
+func (r *MatchRepositoryImpl) GetStaleMatches(ctx context.Context, ttl time.Duration) ([]*matchentity.MatchParties, error) {
+	query := `
+		SELECT
+			mr.id,
+			mr.match_cat_id,
+			mr.user_cat_id,
+			uc.owner_id,
+			mc.owner_id,
+			mr.status
+		FROM
+			match_requests mr
+		JOIN
+			cats mc ON mr.match_cat_id = mc.id
+		JOIN
+			cats uc ON mr.user_cat_id = uc.id
+		WHERE
+			mr.status = 'pending'
+			AND mr.is_deleted = false
+			AND mr.created_at < LOCALTIMESTAMP - make_interval(secs => $1)
+		ORDER BY
+			mr.created_at
+	`
+	rows, err := r.DB.Query(ctx, query, ttl.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	matches := []*matchentity.MatchParties{}
+	for rows.Next() {
+		var parties matchentity.MatchParties
+		err := rows.Scan(
+			&parties.Id,
+			&parties.MatchCatId,
+			&parties.UserCatId,
+			&parties.IssuerId,
+			&parties.ReceiverId,
+			&parties.Status,
+		)
+		if err != nil {
+			return nil, err
+		}
+		matches = append(matches, &parties)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return matches, nil
+}
+
+// ExpireMatch marks the request as expired and reports whether it was still
+// pending. The event is only written when it was.
+func (r *MatchRepositoryImpl) ExpireMatch(ctx context.Context, matchId string, event *evententity.Event) (bool, error) {
+	query := `
+		UPDATE
+			match_requests
+		SET
+			status = 'expired',
+			updated_at = NOW()
+		WHERE
+			id = $1
+			AND status = 'pending'
+			AND is_deleted = false
+	`
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, query, matchId)
+	if err != nil {
+		return false, err
+	}
+	if tag.RowsAffected() == 0 {
+		return false, nil
+	}
+
+	err = insertEvent(ctx, tx, event)
+	if err != nil {
+		return false, err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return false, err
+	}
+
+	return true, nil
 }
@@ -0,0 +1,166 @@
+package repositories
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/danzBraham/cats-social/internal/entities/messageentity"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type MessageRepository interface {
+	CreateMessage(ctx context.Context, message *messageentity.Message) (*messageentity.GetMessageResponse, error)
+	GetMessages(ctx context.Context, userId, matchId string, params *messageentity.MessageQueryParams) ([]*messageentity.GetMessageResponse, *messageentity.MessageCursor, error)
+	MarkMessagesAsRead(ctx context.Context, userId, matchId string) error
+}
+
+type MessageRepositoryImpl struct {
+	DB *pgxpool.Pool
+}
+
+func NewMessageRepository(db *pgxpool.Pool) MessageRepository {
+	return &MessageRepositoryImpl{DB: db}
+}
+
+func (r *MessageRepositoryImpl) CreateMessage(ctx context.Context, message *messageentity.Message) (*messageentity.GetMessageResponse, error) {
+	query := `
+		WITH inserted AS (
+			INSERT INTO
+				match_messages (id, match_id, sender_id, body)
+			VALUES
+				($1, $2, $3, $4)
+			RETURNING
+				id, sender_id, body, read_at, created_at
+		)
+		SELECT
+			i.id,
+			u.name,
+			true,
+			i.body,
+			i.read_at,
+			i.created_at
+		FROM
+			inserted i
+		JOIN
+			users u ON i.sender_id = u.id
+	`
+	response, _, err := scanMessage(r.DB.QueryRow(ctx, query,
+		&message.Id,
+		&message.MatchId,
+		&message.SenderId,
+		&message.Body,
+	))
+	if err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func (r *MessageRepositoryImpl) GetMessages(ctx context.Context, userId, matchId string, params *messageentity.MessageQueryParams) ([]*messageentity.GetMessageResponse, *messageentity.MessageCursor, error) {
+	query := `
+		SELECT
+			m.id,
+			u.name,
+			m.sender_id = $1,
+			m.body,
+			m.read_at,
+			m.created_at
+		FROM
+			match_messages m
+		JOIN
+			users u ON m.sender_id = u.id
+		WHERE
+			m.match_id = $2
+	`
+	args := []interface{}{userId, matchId}
+	argId := 3
+
+	if params.Cursor != nil {
+		query += ` AND (m.created_at, m.id) < ($` + strconv.Itoa(argId) + `, $` + strconv.Itoa(argId+1) + `)`
+		args = append(args, params.Cursor.CreatedAt, params.Cursor.Id)
+		argId += 2
+	}
+
+	// one extra row tells whether there is a next page
+	query += ` ORDER BY m.created_at DESC, m.id DESC LIMIT $` + strconv.Itoa(argId)
+	args = append(args, params.Limit+1)
+
+	rows, err := r.DB.Query(ctx, query, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	messages := make([]*messageentity.GetMessageResponse, 0, params.Limit+1)
+	var next *messageentity.MessageCursor
+	for rows.Next() {
+		message, createdAt, err := scanMessage(rows)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if len(messages) == params.Limit-1 {
+			next = &messageentity.MessageCursor{
+				CreatedAt: createdAt,
+				Id:        message.Id,
+			}
+		}
+
+		messages = append(messages, message)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	if len(messages) <= params.Limit {
+		return messages, nil, nil
+	}
+
+	return messages[:params.Limit], next, nil
+}
+
+// MarkMessagesAsRead marks every message the other owner sent in the thread
+// as read by the user.
+func (r *MessageRepositoryImpl) MarkMessagesAsRead(ctx context.Context, userId, matchId string) error {
+	query := `
+		UPDATE
+			match_messages
+		SET
+			read_at = NOW()
+		WHERE
+			match_id = $1
+			AND sender_id != $2
+			AND read_at IS NULL
+	`
+	_, err := r.DB.Exec(ctx, query, matchId, userId)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func scanMessage(row pgx.Row) (*messageentity.GetMessageResponse, time.Time, error) {
+	var message messageentity.GetMessageResponse
+	var readAt *time.Time
+	var createdAt time.Time
+	err := row.Scan(
+		&message.Id,
+		&message.Sender.Name,
+		&message.IsMine,
+		&message.Body,
+		&readAt,
+		&createdAt,
+	)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	if readAt != nil {
+		formatted := readAt.Format(time.RFC3339)
+		message.ReadAt = &formatted
+	}
+	message.CreatedAt = createdAt.Format(time.RFC3339)
+	return &message, createdAt, nil
+}
@@ -0,0 +1,251 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/danzBraham/cats-social/internal/entities/notificationentity"
+	"github.com/danzBraham/cats-social/internal/errors/notificationerror"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type NotificationRepository interface {
+	CreateNotification(ctx context.Context, notification *notificationentity.Notification) error
+	GetNotifications(ctx context.Context, userId string, params *notificationentity.NotificationQueryParams) ([]*notificationentity.GetNotificationResponse, *notificationentity.NotificationCursor, error)
+	GetNotificationsAfter(ctx context.Context, userId, notificationId string, limit int) ([]*notificationentity.GetNotificationResponse, error)
+	MarkNotificationAsRead(ctx context.Context, userId, notificationId string) error
+	MarkAllNotificationsAsRead(ctx context.Context, userId string) error
+}
+
+type NotificationRepositoryImpl struct {
+	DB *pgxpool.Pool
+}
+
+func NewNotificationRepository(db *pgxpool.Pool) NotificationRepository {
+	return &NotificationRepositoryImpl{DB: db}
+}
+
+// CreateNotification stores the notification and announces it on the
+// notifications channel. Postgres only delivers the announcement once the
+// insert is committed.
+func (r *NotificationRepositoryImpl) CreateNotification(ctx context.Context, notification *notificationentity.Notification) error {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	insertQuery := `
+		INSERT INTO
+			notifications (id, user_id, type, data)
+		VALUES
+			($1, $2, $3, $4)
+		RETURNING
+			created_at
+	`
+	var createdAt time.Time
+	err = tx.QueryRow(ctx, insertQuery,
+		&notification.Id,
+		&notification.UserId,
+		&notification.Type,
+		&notification.Data,
+	).Scan(&createdAt)
+	if err != nil {
+		return err
+	}
+
+	event, err := json.Marshal(&notificationentity.Event{
+		UserId: notification.UserId,
+		Notification: &notificationentity.GetNotificationResponse{
+			Id:        notification.Id,
+			Type:      notification.Type,
+			Data:      notification.Data,
+			CreatedAt: createdAt.Format(time.RFC3339),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `SELECT pg_notify($1, $2)`, notificationentity.Channel, string(event))
+	if err != nil {
+		return err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *NotificationRepositoryImpl) GetNotifications(ctx context.Context, userId string, params *notificationentity.NotificationQueryParams) ([]*notificationentity.GetNotificationResponse, *notificationentity.NotificationCursor, error) {
+	query := `
+		SELECT
+			id,
+			type,
+			data,
+			read_at,
+			created_at
+		FROM
+			notifications
+		WHERE
+			user_id = $1
+	`
+	args := []interface{}{userId}
+	argId := 2
+
+	if params.Unread {
+		query += ` AND read_at IS NULL`
+	}
+
+	if params.Cursor != nil {
+		query += ` AND (created_at, id) < ($` + strconv.Itoa(argId) + `, $` + strconv.Itoa(argId+1) + `)`
+		args = append(args, params.Cursor.CreatedAt, params.Cursor.Id)
+		argId += 2
+	}
+
+	// one extra row tells whether there is a next page
+	query += ` ORDER BY created_at DESC, id DESC LIMIT $` + strconv.Itoa(argId)
+	args = append(args, params.Limit+1)
+
+	rows, err := r.DB.Query(ctx, query, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	notifications := make([]*notificationentity.GetNotificationResponse, 0, params.Limit+1)
+	var next *notificationentity.NotificationCursor
+	for rows.Next() {
+		notification, createdAt, err := scanNotification(rows)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if len(notifications) == params.Limit-1 {
+			next = &notificationentity.NotificationCursor{
+				CreatedAt: createdAt,
+				Id:        notification.Id,
+			}
+		}
+
+		notifications = append(notifications, notification)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	if len(notifications) <= params.Limit {
+		return notifications, nil, nil
+	}
+
+	return notifications[:params.Limit], next, nil
+}
+
+// GetNotificationsAfter returns, oldest first, the notifications created after
+// the given one. It lets a stream that reconnects catch up on what it missed.
+func (r *NotificationRepositoryImpl) GetNotificationsAfter(ctx context.Context, userId, notificationId string, limit int) ([]*notificationentity.GetNotificationResponse, error) {
+	query := `
+		SELECT
+			n.id,
+			n.type,
+			n.data,
+			n.read_at,
+			n.created_at
+		FROM
+			notifications n
+		JOIN
+			notifications last ON last.id = $2 AND last.user_id = n.user_id
+		WHERE
+			n.user_id = $1
+			AND (n.created_at, n.id) > (last.created_at, last.id)
+		ORDER BY
+			n.created_at, n.id
+		LIMIT
+			$3
+	`
+	rows, err := r.DB.Query(ctx, query, userId, notificationId, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notifications := []*notificationentity.GetNotificationResponse{}
+	for rows.Next() {
+		notification, _, err := scanNotification(rows)
+		if err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, notification)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return notifications, nil
+}
+
+func (r *NotificationRepositoryImpl) MarkNotificationAsRead(ctx context.Context, userId, notificationId string) error {
+	query := `
+		UPDATE
+			notifications
+		SET
+			read_at = COALESCE(read_at, NOW())
+		WHERE
+			id = $1
+			AND user_id = $2
+	`
+	tag, err := r.DB.Exec(ctx, query, notificationId, userId)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return notificationerror.ErrNotificationIdNotFound
+	}
+	return nil
+}
+
+func (r *NotificationRepositoryImpl) MarkAllNotificationsAsRead(ctx context.Context, userId string) error {
+	query := `
+		UPDATE
+			notifications
+		SET
+			read_at = NOW()
+		WHERE
+			user_id = $1
+			AND read_at IS NULL
+	`
+	_, err := r.DB.Exec(ctx, query, userId)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func scanNotification(row pgx.Row) (*notificationentity.GetNotificationResponse, time.Time, error) {
+	var notification notificationentity.GetNotificationResponse
+	var readAt *time.Time
+	var createdAt time.Time
+	err := row.Scan(
+		&notification.Id,
+		&notification.Type,
+		&notification.Data,
+		&readAt,
+		&createdAt,
+	)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	if readAt != nil {
+		formatted := readAt.Format(time.RFC3339)
+		notification.ReadAt = &formatted
+	}
+	notification.CreatedAt = createdAt.Format(time.RFC3339)
+	return &notification, createdAt, nil
+}
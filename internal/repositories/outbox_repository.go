@@ -0,0 +1,183 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/danzBraham/cats-social/internal/entities/evententity"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type OutboxRepository interface {
+	ClaimEvent(ctx context.Context, maxAttempts int) (*evententity.Event, error)
+	MarkEventHandled(ctx context.Context, eventId, handler string) error
+	CompleteEvent(ctx context.Context, eventId string) error
+	FailEvent(ctx context.Context, eventId, message string, retryIn *time.Duration) error
+}
+
+type OutboxRepositoryImpl struct {
+	DB *pgxpool.Pool
+}
+
+func NewOutboxRepository(db *pgxpool.Pool) OutboxRepository {
+	return &OutboxRepositoryImpl{DB: db}
+}
+
+// ClaimEvent marks the oldest due event as processing and returns it, or nil
+// when there is nothing to dispatch. Events stuck in processing, because a
+// dispatcher died halfway, are picked up again after a few minutes, or given
+// up on when that was their last attempt.
+func (r *OutboxRepositoryImpl) ClaimEvent(ctx context.Context, maxAttempts int) (*evententity.Event, error) {
+	failStaleQuery := `
+		UPDATE
+			outbox_events
+		SET
+			status = 'failed',
+			error = 'the dispatcher stopped during the last attempt',
+			updated_at = NOW()
+		WHERE
+			attempts >= $1
+			AND status = 'processing'
+			AND updated_at < NOW() - INTERVAL '5 minutes'
+	`
+	_, err := r.DB.Exec(ctx, failStaleQuery, maxAttempts)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		UPDATE
+			outbox_events
+		SET
+			status = 'processing',
+			attempts = attempts + 1,
+			updated_at = NOW()
+		WHERE
+			id = (
+				SELECT
+					id
+				FROM
+					outbox_events
+				WHERE
+					attempts < $1
+					AND (
+						(status = 'pending' AND next_attempt_at <= LOCALTIMESTAMP)
+						OR (status = 'processing' AND updated_at < NOW() - INTERVAL '5 minutes')
+					)
+				ORDER BY
+					next_attempt_at, id
+				LIMIT 1
+				FOR UPDATE SKIP LOCKED
+			)
+		RETURNING
+			id, type, aggregate_id, payload, handled_by, attempts, created_at
+	`
+	var event evententity.Event
+	var createdAt time.Time
+	err = r.DB.QueryRow(ctx, query, maxAttempts).Scan(
+		&event.Id,
+		&event.Type,
+		&event.AggregateId,
+		&event.Payload,
+		&event.HandledBy,
+		&event.Attempts,
+		&createdAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	event.CreatedAt = createdAt.Format(time.RFC3339)
+	return &event, nil
+}
+
+// MarkEventHandled records that the handler is done with the event, so a
+// retry of the event doesn't run it again.
+func (r *OutboxRepositoryImpl) MarkEventHandled(ctx context.Context, eventId, handler string) error {
+	query := `
+		UPDATE
+			outbox_events
+		SET
+			handled_by = array_append(handled_by, $2),
+			updated_at = NOW()
+		WHERE
+			id = $1
+			AND NOT $2 = ANY(handled_by)
+	`
+	_, err := r.DB.Exec(ctx, query, eventId, handler)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *OutboxRepositoryImpl) CompleteEvent(ctx context.Context, eventId string) error {
+	query := `
+		UPDATE
+			outbox_events
+		SET
+			status = 'dispatched',
+			error = NULL,
+			dispatched_at = NOW(),
+			updated_at = NOW()
+		WHERE
+			id = $1
+	`
+	_, err := r.DB.Exec(ctx, query, eventId)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// FailEvent schedules the next attempt in retryIn, or gives up on the event
+// when retryIn is nil.
+func (r *OutboxRepositoryImpl) FailEvent(ctx context.Context, eventId, message string, retryIn *time.Duration) error {
+	query := `
+		UPDATE
+			outbox_events
+		SET
+			status = $2,
+			error = $3,
+			next_attempt_at = LOCALTIMESTAMP + make_interval(secs => $4),
+			updated_at = NOW()
+		WHERE
+			id = $1
+	`
+	status := evententity.Failed
+	var seconds float64
+	if retryIn != nil {
+		status = evententity.Pending
+		seconds = retryIn.Seconds()
+	}
+	_, err := r.DB.Exec(ctx, query, eventId, status, message, seconds)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// insertEvent writes the event to the outbox as part of tx, the transaction
+// of the change the event describes.
+func insertEvent(ctx context.Context, tx pgx.Tx, event *evententity.Event) error {
+	query := `
+		INSERT INTO
+			outbox_events (id, type, aggregate_id, payload)
+		VALUES
+			($1, $2, $3, $4)
+	`
+	_, err := tx.Exec(ctx, query,
+		&event.Id,
+		&event.Type,
+		&event.AggregateId,
+		&event.Payload,
+	)
+	if err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/danzBraham/cats-social/internal/entities/userentity"
+	"github.com/danzBraham/cats-social/internal/errors/usererror"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PasswordResetRepository interface {
+	CreatePasswordReset(ctx context.Context, passwordReset *userentity.PasswordReset, ttl time.Duration) error
+	ResetPassword(ctx context.Context, tokenHash, hashedPassword string) error
+}
+
+type PasswordResetRepositoryImpl struct {
+	DB *pgxpool.Pool
+}
+
+func NewPasswordResetRepository(db *pgxpool.Pool) PasswordResetRepository {
+	return &PasswordResetRepositoryImpl{DB: db}
+}
+
+func (r *PasswordResetRepositoryImpl) CreatePasswordReset(ctx context.Context, passwordReset *userentity.PasswordReset, ttl time.Duration) error {
+	query := `
+		INSERT INTO
+			password_resets (id, user_id, token_hash, expires_at)
+		VALUES
+			($1, $2, $3, NOW() + make_interval(secs => $4))
+	`
+	_, err := r.DB.Exec(ctx, query,
+		&passwordReset.Id,
+		&passwordReset.UserId,
+		&passwordReset.TokenHash,
+		ttl.Seconds(),
+	)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// ResetPassword marks an unused, unexpired reset token as used, sets the
+// password of the user it was issued for and revokes their sessions, all in
+// one transaction.
+func (r *PasswordResetRepositoryImpl) ResetPassword(ctx context.Context, tokenHash, hashedPassword string) error {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	consumeQuery := `
+		UPDATE
+			password_resets
+		SET
+			used_at = NOW()
+		WHERE
+			token_hash = $1
+			AND used_at IS NULL
+			AND expires_at > NOW()
+		RETURNING
+			user_id
+	`
+	var userId string
+	err = tx.QueryRow(ctx, consumeQuery, tokenHash).Scan(&userId)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return usererror.ErrInvalidResetToken
+	}
+	if err != nil {
+		return err
+	}
+
+	updatePasswordQuery := `
+		UPDATE
+			users
+		SET
+			password = $1,
+			updated_at = NOW()
+		WHERE
+			id = $2
+			AND is_deleted = false
+	`
+	_, err = tx.Exec(ctx, updatePasswordQuery, hashedPassword, userId)
+	if err != nil {
+		return err
+	}
+
+	revokeSessionsQuery := `
+		UPDATE
+			sessions
+		SET
+			revoked_at = NOW(),
+			updated_at = NOW()
+		WHERE
+			user_id = $1
+			AND revoked_at IS NULL
+	`
+	_, err = tx.Exec(ctx, revokeSessionsQuery, userId)
+	if err != nil {
+		return err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,37 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type RecoveryCodeRepository interface {
+	ConsumeRecoveryCode(ctx context.Context, userId, codeHash string) (bool, error)
+}
+
+type RecoveryCodeRepositoryImpl struct {
+	DB *pgxpool.Pool
+}
+
+func NewRecoveryCodeRepository(db *pgxpool.Pool) RecoveryCodeRepository {
+	return &RecoveryCodeRepositoryImpl{DB: db}
+}
+
+func (r *RecoveryCodeRepositoryImpl) ConsumeRecoveryCode(ctx context.Context, userId, codeHash string) (bool, error) {
+	query := `
+		UPDATE
+			recovery_codes
+		SET
+			used_at = NOW()
+		WHERE
+			user_id = $1
+			AND code_hash = $2
+			AND used_at IS NULL
+	`
+	commandTag, err := r.DB.Exec(ctx, query, userId, codeHash)
+	if err != nil {
+		return false, err
+	}
+	return commandTag.RowsAffected() > 0, nil
+}
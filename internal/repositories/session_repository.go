@@ -0,0 +1,157 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/danzBraham/cats-social/internal/entities/sessionentity"
+	"github.com/danzBraham/cats-social/internal/errors/autherror"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type SessionRepository interface {
+	IsSessionActive(ctx context.Context, sessionId string) (bool, error)
+	CreateSession(ctx context.Context, session *sessionentity.Session, ttl time.Duration) error
+	RotateRefreshToken(ctx context.Context, oldHash, newHash string, ttl time.Duration) (*sessionentity.Session, error)
+	RevokeSession(ctx context.Context, sessionId string) error
+	RevokeSessionsByUserId(ctx context.Context, userId string) error
+	RevokeOtherSessions(ctx context.Context, userId, sessionId string) error
+}
+
+type SessionRepositoryImpl struct {
+	DB *pgxpool.Pool
+}
+
+func NewSessionRepository(db *pgxpool.Pool) SessionRepository {
+	return &SessionRepositoryImpl{DB: db}
+}
+
+func (r *SessionRepositoryImpl) IsSessionActive(ctx context.Context, sessionId string) (bool, error) {
+	query := `
+		SELECT
+			1
+		FROM
+			sessions
+		WHERE
+			id = $1
+			AND revoked_at IS NULL
+			AND expires_at > NOW()
+	`
+	var exists int
+	err := r.DB.QueryRow(ctx, query, sessionId).Scan(&exists)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (r *SessionRepositoryImpl) CreateSession(ctx context.Context, session *sessionentity.Session, ttl time.Duration) error {
+	query := `
+		INSERT INTO
+			sessions (id, user_id, refresh_token_hash, expires_at)
+		VALUES
+			($1, $2, $3, NOW() + make_interval(secs => $4))
+	`
+	_, err := r.DB.Exec(ctx, query,
+		&session.Id,
+		&session.UserId,
+		&session.RefreshTokenHash,
+		ttl.Seconds(),
+	)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// RotateRefreshToken swaps the refresh token of a live session in a single
+// statement, so the same refresh token can never be redeemed twice.
+func (r *SessionRepositoryImpl) RotateRefreshToken(ctx context.Context, oldHash, newHash string, ttl time.Duration) (*sessionentity.Session, error) {
+	query := `
+		UPDATE
+			sessions
+		SET
+			refresh_token_hash = $1,
+			expires_at = NOW() + make_interval(secs => $2),
+			updated_at = NOW()
+		WHERE
+			refresh_token_hash = $3
+			AND revoked_at IS NULL
+			AND expires_at > NOW()
+		RETURNING
+			id, user_id
+	`
+	var session sessionentity.Session
+	err := r.DB.QueryRow(ctx, query, newHash, ttl.Seconds(), oldHash).Scan(
+		&session.Id,
+		&session.UserId,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, autherror.ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	session.RefreshTokenHash = newHash
+	return &session, nil
+}
+
+func (r *SessionRepositoryImpl) RevokeSession(ctx context.Context, sessionId string) error {
+	query := `
+		UPDATE
+			sessions
+		SET
+			revoked_at = NOW(),
+			updated_at = NOW()
+		WHERE
+			id = $1
+			AND revoked_at IS NULL
+	`
+	_, err := r.DB.Exec(ctx, query, sessionId)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *SessionRepositoryImpl) RevokeSessionsByUserId(ctx context.Context, userId string) error {
+	query := `
+		UPDATE
+			sessions
+		SET
+			revoked_at = NOW(),
+			updated_at = NOW()
+		WHERE
+			user_id = $1
+			AND revoked_at IS NULL
+	`
+	_, err := r.DB.Exec(ctx, query, userId)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *SessionRepositoryImpl) RevokeOtherSessions(ctx context.Context, userId, sessionId string) error {
+	query := `
+		UPDATE
+			sessions
+		SET
+			revoked_at = NOW(),
+			updated_at = NOW()
+		WHERE
+			user_id = $1
+			AND id != $2
+			AND revoked_at IS NULL
+	`
+	_, err := r.DB.Exec(ctx, query, userId, sessionId)
+	if err != nil {
+		return err
+	}
+	return nil
+}
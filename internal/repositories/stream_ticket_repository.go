@@ -0,0 +1,102 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/danzBraham/cats-social/internal/entities/notificationentity"
+	"github.com/danzBraham/cats-social/internal/errors/notificationerror"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type StreamTicketRepository interface {
+	CreateStreamTicket(ctx context.Context, streamTicket *notificationentity.StreamTicket, ttl time.Duration) (string, error)
+	ConsumeStreamTicket(ctx context.Context, tokenHash string) (*notificationentity.StreamTicket, error)
+}
+
+type StreamTicketRepositoryImpl struct {
+	DB *pgxpool.Pool
+}
+
+func NewStreamTicketRepository(db *pgxpool.Pool) StreamTicketRepository {
+	return &StreamTicketRepositoryImpl{DB: db}
+}
+
+// CreateStreamTicket returns when the ticket expires. It also removes the
+// expired tickets of the user, so tickets that were never used don't pile up.
+func (r *StreamTicketRepositoryImpl) CreateStreamTicket(ctx context.Context, streamTicket *notificationentity.StreamTicket, ttl time.Duration) (string, error) {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback(ctx)
+
+	deleteExpiredQuery := `
+		DELETE FROM
+			stream_tickets
+		WHERE
+			user_id = $1
+			AND expires_at <= NOW()
+	`
+	_, err = tx.Exec(ctx, deleteExpiredQuery, streamTicket.UserId)
+	if err != nil {
+		return "", err
+	}
+
+	insertQuery := `
+		INSERT INTO
+			stream_tickets (id, user_id, session_id, token_hash, expires_at)
+		VALUES
+			($1, $2, $3, $4, NOW() + make_interval(secs => $5))
+		RETURNING
+			expires_at
+	`
+	var expiresAt time.Time
+	err = tx.QueryRow(ctx, insertQuery,
+		&streamTicket.Id,
+		&streamTicket.UserId,
+		&streamTicket.SessionId,
+		&streamTicket.TokenHash,
+		ttl.Seconds(),
+	).Scan(&expiresAt)
+	if err != nil {
+		return "", err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return "", err
+	}
+
+	return expiresAt.Format(time.RFC3339), nil
+}
+
+// ConsumeStreamTicket deletes an unexpired ticket and returns who it was
+// issued to, so a ticket leaked through a log or the browser history can't
+// be used a second time.
+func (r *StreamTicketRepositoryImpl) ConsumeStreamTicket(ctx context.Context, tokenHash string) (*notificationentity.StreamTicket, error) {
+	query := `
+		DELETE FROM
+			stream_tickets
+		WHERE
+			token_hash = $1
+			AND expires_at > NOW()
+		RETURNING
+			id, user_id, session_id, token_hash
+	`
+	var streamTicket notificationentity.StreamTicket
+	err := r.DB.QueryRow(ctx, query, tokenHash).Scan(
+		&streamTicket.Id,
+		&streamTicket.UserId,
+		&streamTicket.SessionId,
+		&streamTicket.TokenHash,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, notificationerror.ErrInvalidStreamTicket
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &streamTicket, nil
+}
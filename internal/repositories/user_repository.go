@@ -3,19 +3,34 @@ package repositories
 import (
 	"context"
 	"errors"
+	"strconv"
 	"time"
 
+	"github.com/danzBraham/cats-social/internal/entities/evententity"
 	"github.com/danzBraham/cats-social/internal/entities/userentity"
 	"github.com/danzBraham/cats-social/internal/errors/usererror"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/oklog/ulid/v2"
 )
 
 type UserRepository interface {
 	IsEmailExists(ctx context.Context, email string) (bool, error)
-	CreateUser(ctx context.Context, user *userentity.User) error
+	CreateUser(ctx context.Context, user *userentity.User, event *evententity.Event) error
 	GetUserByEmail(ctx context.Context, email string) (*userentity.User, error)
 	GetUserById(ctx context.Context, userId string) (*userentity.User, error)
+	UpdateUserById(ctx context.Context, userId string, user *userentity.User) error
+	UpdatePasswordById(ctx context.Context, userId, hashedPassword string) error
+	VerifyEmail(ctx context.Context, userId, email string) (bool, error)
+	DeleteUserById(ctx context.Context, userId string) (map[string][]string, error)
+	GetUserAccess(ctx context.Context, userId string) (*userentity.UserAccess, error)
+	GetUsers(ctx context.Context, params *userentity.UserQueryParams) ([]*userentity.AdminUserResponse, error)
+	SuspendUserById(ctx context.Context, userId string) error
+	UnsuspendUserById(ctx context.Context, userId string) error
+	SetTOTPSecret(ctx context.Context, userId, secret string) error
+	EnableTOTP(ctx context.Context, userId string, step int64, codeHashes []string) error
+	DisableTOTP(ctx context.Context, userId string) error
+	UseTOTPStep(ctx context.Context, userId string, step int64) (bool, error)
 }
 
 type UserRepositoryImpl struct {
@@ -47,14 +62,20 @@ func (r *UserRepositoryImpl) IsEmailExists(ctx context.Context, email string) (b
 	return true, nil
 }
 
-func (r *UserRepositoryImpl) CreateUser(ctx context.Context, user *userentity.User) error {
+func (r *UserRepositoryImpl) CreateUser(ctx context.Context, user *userentity.User, event *evententity.Event) error {
 	query := `
 		INSERT INTO
 			users (id, name, email, password)
 		VALUES
 			($1, $2, $3, $4)
 	`
-	_, err := r.DB.Exec(ctx, query,
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, query,
 		&user.Id,
 		&user.Name,
 		&user.Email,
@@ -63,7 +84,13 @@ func (r *UserRepositoryImpl) CreateUser(ctx context.Context, user *userentity.Us
 	if err != nil {
 		return err
 	}
-	return nil
+
+	err = insertEvent(ctx, tx, event)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
 }
 
 func (r *UserRepositoryImpl) GetUserByEmail(ctx context.Context, email string) (*userentity.User, error) {
@@ -73,6 +100,11 @@ func (r *UserRepositoryImpl) GetUserByEmail(ctx context.Context, email string) (
 			name,
 			email,
 			password,
+			role,
+			email_verified_at,
+			suspended_at,
+			COALESCE(totp_secret, ''),
+			totp_enabled_at,
 			created_at
 		FROM
 			users 
@@ -81,12 +113,18 @@ func (r *UserRepositoryImpl) GetUserByEmail(ctx context.Context, email string) (
 			AND is_deleted = false
 	`
 	var user userentity.User
+	var emailVerifiedAt, suspendedAt, totpEnabledAt *time.Time
 	var createdAt time.Time
 	err := r.DB.QueryRow(ctx, query, email).Scan(
 		&user.Id,
 		&user.Name,
 		&user.Email,
 		&user.Password,
+		&user.Role,
+		&emailVerifiedAt,
+		&suspendedAt,
+		&user.TOTPSecret,
+		&totpEnabledAt,
 		&createdAt,
 	)
 	if errors.Is(err, pgx.ErrNoRows) {
@@ -95,6 +133,15 @@ func (r *UserRepositoryImpl) GetUserByEmail(ctx context.Context, email string) (
 	if err != nil {
 		return nil, err
 	}
+	if emailVerifiedAt != nil {
+		user.EmailVerifiedAt = emailVerifiedAt.Format(time.RFC3339)
+	}
+	if suspendedAt != nil {
+		user.SuspendedAt = suspendedAt.Format(time.RFC3339)
+	}
+	if totpEnabledAt != nil {
+		user.TOTPEnabledAt = totpEnabledAt.Format(time.RFC3339)
+	}
 	user.CreatedAt = createdAt.Format(time.RFC3339)
 	return &user, nil
 }
@@ -106,6 +153,14 @@ func (r *UserRepositoryImpl) GetUserById(ctx context.Context, userId string) (*u
 			name,
 			email,
 			password,
+			role,
+			email_verified_at,
+			suspended_at,
+			COALESCE(totp_secret, ''),
+			totp_enabled_at,
+			latitude,
+			longitude,
+			city,
 			created_at
 		FROM
 			users 
@@ -114,12 +169,23 @@ func (r *UserRepositoryImpl) GetUserById(ctx context.Context, userId string) (*u
 			AND is_deleted = false
 	`
 	var user userentity.User
+	var emailVerifiedAt, suspendedAt, totpEnabledAt *time.Time
+	var latitude, longitude *float64
+	var city string
 	var createdAt time.Time
 	err := r.DB.QueryRow(ctx, query, userId).Scan(
 		&user.Id,
 		&user.Name,
 		&user.Email,
 		&user.Password,
+		&user.Role,
+		&emailVerifiedAt,
+		&suspendedAt,
+		&user.TOTPSecret,
+		&totpEnabledAt,
+		&latitude,
+		&longitude,
+		&city,
 		&createdAt,
 	)
 	if errors.Is(err, pgx.ErrNoRows) {
@@ -128,6 +194,483 @@ func (r *UserRepositoryImpl) GetUserById(ctx context.Context, userId string) (*u
 	if err != nil {
 		return nil, err
 	}
+	if emailVerifiedAt != nil {
+		user.EmailVerifiedAt = emailVerifiedAt.Format(time.RFC3339)
+	}
+	if suspendedAt != nil {
+		user.SuspendedAt = suspendedAt.Format(time.RFC3339)
+	}
+	if totpEnabledAt != nil {
+		user.TOTPEnabledAt = totpEnabledAt.Format(time.RFC3339)
+	}
+	user.Location = newLocation(latitude, longitude, city)
 	user.CreatedAt = createdAt.Format(time.RFC3339)
 	return &user, nil
 }
+
+func (r *UserRepositoryImpl) UpdateUserById(ctx context.Context, userId string, user *userentity.User) error {
+	query := `
+		UPDATE
+			users
+		SET
+			name = $1,
+			email = $2,
+			email_verified_at = CASE WHEN email = $2 THEN email_verified_at END,
+			latitude = $3,
+			longitude = $4,
+			city = $5,
+			updated_at = NOW()
+		WHERE
+			id = $6
+			AND is_deleted = false
+	`
+	latitude, longitude, city := locationValues(user.Location)
+	_, err := r.DB.Exec(ctx, query,
+		&user.Name,
+		&user.Email,
+		latitude,
+		longitude,
+		city,
+		userId,
+	)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *UserRepositoryImpl) UpdatePasswordById(ctx context.Context, userId, hashedPassword string) error {
+	query := `
+		UPDATE
+			users
+		SET
+			password = $1,
+			updated_at = NOW()
+		WHERE
+			id = $2
+			AND is_deleted = false
+	`
+	_, err := r.DB.Exec(ctx, query, hashedPassword, userId)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// VerifyEmail marks the email as verified as long as it is still the
+// user's current address.
+func (r *UserRepositoryImpl) VerifyEmail(ctx context.Context, userId, email string) (bool, error) {
+	query := `
+		UPDATE
+			users
+		SET
+			email_verified_at = COALESCE(email_verified_at, NOW()),
+			updated_at = NOW()
+		WHERE
+			id = $1
+			AND email = $2
+			AND is_deleted = false
+	`
+	commandTag, err := r.DB.Exec(ctx, query, userId, email)
+	if err != nil {
+		return false, err
+	}
+	return commandTag.RowsAffected() > 0, nil
+}
+
+// DeleteUserById returns the image urls of the removed cats, keyed by cat id,
+// so the caller can clean up the stored images.
+func (r *UserRepositoryImpl) DeleteUserById(ctx context.Context, userId string) (map[string][]string, error) {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	// remove pending match requests that involve any of the user's cats
+	removeMatchRequestsQuery := `
+		UPDATE
+			match_requests mr
+		SET
+			is_deleted = true,
+			updated_at = NOW()
+		FROM
+			cats c
+		WHERE
+			(mr.match_cat_id = c.id OR mr.user_cat_id = c.id)
+			AND c.owner_id = $1
+			AND mr.status = 'pending'
+			AND mr.is_deleted = false
+	`
+	_, err = tx.Exec(ctx, removeMatchRequestsQuery, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	// remove the user's cats, keeping their image urls for cleanup
+	removeCatsQuery := `
+		UPDATE
+			cats
+		SET
+			is_deleted = true,
+			updated_at = NOW()
+		WHERE
+			owner_id = $1
+			AND is_deleted = false
+		RETURNING
+			id, image_urls
+	`
+	rows, err := tx.Query(ctx, removeCatsQuery, userId)
+	if err != nil {
+		return nil, err
+	}
+	imageUrls := map[string][]string{}
+	for rows.Next() {
+		var catId string
+		var catImageUrls []string
+		if err := rows.Scan(&catId, &catImageUrls); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		imageUrls[catId] = catImageUrls
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// revoke every session so issued tokens stop working
+	revokeSessionsQuery := `
+		UPDATE
+			sessions
+		SET
+			revoked_at = NOW(),
+			updated_at = NOW()
+		WHERE
+			user_id = $1
+			AND revoked_at IS NULL
+	`
+	_, err = tx.Exec(ctx, revokeSessionsQuery, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	revokeApiKeysQuery := `
+		UPDATE
+			api_keys
+		SET
+			revoked_at = NOW()
+		WHERE
+			user_id = $1
+			AND revoked_at IS NULL
+	`
+	_, err = tx.Exec(ctx, revokeApiKeysQuery, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	removeUserQuery := `
+		UPDATE
+			users
+		SET
+			is_deleted = true,
+			updated_at = NOW()
+		WHERE
+			id = $1
+	`
+	_, err = tx.Exec(ctx, removeUserQuery, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	return imageUrls, nil
+}
+
+// GetUserAccess returns the current role and suspension of a user that is
+// not deleted. It's read on every request, so a role change or suspension
+// applies to tokens that were issued before it.
+func (r *UserRepositoryImpl) GetUserAccess(ctx context.Context, userId string) (*userentity.UserAccess, error) {
+	query := `
+		SELECT
+			role,
+			suspended_at IS NOT NULL
+		FROM
+			users
+		WHERE
+			id = $1
+			AND is_deleted = false
+	`
+	var access userentity.UserAccess
+	err := r.DB.QueryRow(ctx, query, userId).Scan(&access.Role, &access.IsSuspended)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, usererror.ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &access, nil
+}
+
+func (r *UserRepositoryImpl) GetUsers(ctx context.Context, params *userentity.UserQueryParams) ([]*userentity.AdminUserResponse, error) {
+	query := `
+		SELECT
+			id,
+			name,
+			email,
+			role,
+			email_verified_at IS NOT NULL,
+			suspended_at IS NOT NULL,
+			created_at
+		FROM
+			users
+		WHERE
+			is_deleted = false
+	`
+	args := []interface{}{}
+	argId := 1
+
+	if params.Search != "" {
+		query += ` AND (name ILIKE $` + strconv.Itoa(argId) + ` OR email ILIKE $` + strconv.Itoa(argId) + `)`
+		args = append(args, `%`+params.Search+`%`)
+		argId++
+	}
+
+	query += ` ORDER BY created_at DESC LIMIT $` + strconv.Itoa(argId) + ` OFFSET $` + strconv.Itoa(argId+1)
+	args = append(args, params.Limit, params.Offset)
+
+	rows, err := r.DB.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := make([]*userentity.AdminUserResponse, 0, params.Limit)
+	for rows.Next() {
+		var user userentity.AdminUserResponse
+		var createdAt time.Time
+		err := rows.Scan(
+			&user.Id,
+			&user.Name,
+			&user.Email,
+			&user.Role,
+			&user.EmailVerified,
+			&user.Suspended,
+			&createdAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		user.CreatedAt = createdAt.Format(time.RFC3339)
+		users = append(users, &user)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+func (r *UserRepositoryImpl) SuspendUserById(ctx context.Context, userId string) error {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	suspendQuery := `
+		UPDATE
+			users
+		SET
+			suspended_at = COALESCE(suspended_at, NOW()),
+			updated_at = NOW()
+		WHERE
+			id = $1
+			AND is_deleted = false
+	`
+	_, err = tx.Exec(ctx, suspendQuery, userId)
+	if err != nil {
+		return err
+	}
+
+	// revoke every session so refresh tokens can't be used either
+	revokeSessionsQuery := `
+		UPDATE
+			sessions
+		SET
+			revoked_at = NOW(),
+			updated_at = NOW()
+		WHERE
+			user_id = $1
+			AND revoked_at IS NULL
+	`
+	_, err = tx.Exec(ctx, revokeSessionsQuery, userId)
+	if err != nil {
+		return err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *UserRepositoryImpl) UnsuspendUserById(ctx context.Context, userId string) error {
+	query := `
+		UPDATE
+			users
+		SET
+			suspended_at = NULL,
+			updated_at = NOW()
+		WHERE
+			id = $1
+			AND is_deleted = false
+	`
+	_, err := r.DB.Exec(ctx, query, userId)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *UserRepositoryImpl) SetTOTPSecret(ctx context.Context, userId, secret string) error {
+	query := `
+		UPDATE
+			users
+		SET
+			totp_secret = $1,
+			totp_enabled_at = NULL,
+			totp_last_used_step = NULL,
+			updated_at = NOW()
+		WHERE
+			id = $2
+			AND is_deleted = false
+	`
+	_, err := r.DB.Exec(ctx, query, secret, userId)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// EnableTOTP turns on two-factor authentication and replaces the user's
+// recovery codes in one transaction, so a failure leaves neither half behind.
+func (r *UserRepositoryImpl) EnableTOTP(ctx context.Context, userId string, step int64, codeHashes []string) error {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	enableQuery := `
+		UPDATE
+			users
+		SET
+			totp_enabled_at = NOW(),
+			totp_last_used_step = $1,
+			updated_at = NOW()
+		WHERE
+			id = $2
+			AND is_deleted = false
+	`
+	_, err = tx.Exec(ctx, enableQuery, step, userId)
+	if err != nil {
+		return err
+	}
+
+	removeRecoveryCodesQuery := `
+		DELETE FROM
+			recovery_codes
+		WHERE
+			user_id = $1
+	`
+	_, err = tx.Exec(ctx, removeRecoveryCodesQuery, userId)
+	if err != nil {
+		return err
+	}
+
+	insertRecoveryCodeQuery := `
+		INSERT INTO
+			recovery_codes (id, user_id, code_hash)
+		VALUES
+			($1, $2, $3)
+	`
+	for _, codeHash := range codeHashes {
+		_, err = tx.Exec(ctx, insertRecoveryCodeQuery, ulid.Make().String(), userId, codeHash)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *UserRepositoryImpl) DisableTOTP(ctx context.Context, userId string) error {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	disableQuery := `
+		UPDATE
+			users
+		SET
+			totp_secret = NULL,
+			totp_enabled_at = NULL,
+			totp_last_used_step = NULL,
+			updated_at = NOW()
+		WHERE
+			id = $1
+	`
+	_, err = tx.Exec(ctx, disableQuery, userId)
+	if err != nil {
+		return err
+	}
+
+	removeRecoveryCodesQuery := `
+		DELETE FROM
+			recovery_codes
+		WHERE
+			user_id = $1
+	`
+	_, err = tx.Exec(ctx, removeRecoveryCodesQuery, userId)
+	if err != nil {
+		return err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// UseTOTPStep records step as the last accepted TOTP step. It reports false
+// when the step, or a later one, was already used, which stops a code from
+// being replayed within its validity window.
+func (r *UserRepositoryImpl) UseTOTPStep(ctx context.Context, userId string, step int64) (bool, error) {
+	query := `
+		UPDATE
+			users
+		SET
+			totp_last_used_step = $1
+		WHERE
+			id = $2
+			AND (totp_last_used_step IS NULL OR totp_last_used_step < $1)
+	`
+	commandTag, err := r.DB.Exec(ctx, query, step, userId)
+	if err != nil {
+		return false, err
+	}
+	return commandTag.RowsAffected() > 0, nil
+}
@@ -0,0 +1,443 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/danzBraham/cats-social/internal/entities/webhookentity"
+	"github.com/danzBraham/cats-social/internal/errors/webhookerror"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/oklog/ulid/v2"
+)
+
+type WebhookRepository interface {
+	IsWebhookOwner(ctx context.Context, webhookId, userId string) (bool, error)
+	CreateWebhook(ctx context.Context, webhook *webhookentity.Webhook) (string, error)
+	GetWebhooks(ctx context.Context, userId string) ([]*webhookentity.GetWebhookResponse, error)
+	DeleteWebhookById(ctx context.Context, webhookId string) error
+	EnqueueEvent(ctx context.Context, event *webhookentity.Event, userIds []string) error
+	ClaimDelivery(ctx context.Context, maxAttempts int) (*webhookentity.Delivery, error)
+	CompleteDelivery(ctx context.Context, deliveryId string, responseStatus int) error
+	FailDelivery(ctx context.Context, deliveryId string, responseStatus *int, message string, retryIn *time.Duration) error
+	GetDeliveries(ctx context.Context, webhookId string, params *webhookentity.DeliveryQueryParams) ([]*webhookentity.GetDeliveryResponse, *webhookentity.DeliveryCursor, error)
+	Redeliver(ctx context.Context, webhookId, deliveryId string) (*webhookentity.GetDeliveryResponse, error)
+}
+
+type WebhookRepositoryImpl struct {
+	DB *pgxpool.Pool
+}
+
+func NewWebhookRepository(db *pgxpool.Pool) WebhookRepository {
+	return &WebhookRepositoryImpl{DB: db}
+}
+
+// deliveryColumns are the columns scanned by scanDelivery.
+const deliveryColumns = `
+	id,
+	event_id,
+	event_type,
+	payload,
+	status,
+	attempts,
+	response_status,
+	error,
+	next_attempt_at,
+	delivered_at,
+	created_at
+`
+
+func (r *WebhookRepositoryImpl) IsWebhookOwner(ctx context.Context, webhookId, userId string) (bool, error) {
+	query := `
+		SELECT
+			1
+		FROM
+			webhooks
+		WHERE
+			id = $1
+			AND user_id = $2
+	`
+	var exists int
+	err := r.DB.QueryRow(ctx, query, webhookId, userId).Scan(&exists)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (r *WebhookRepositoryImpl) CreateWebhook(ctx context.Context, webhook *webhookentity.Webhook) (string, error) {
+	query := `
+		INSERT INTO
+			webhooks (id, user_id, url, secret, event_types, is_global)
+		VALUES
+			($1, $2, $3, $4, $5, $6)
+		RETURNING
+			created_at
+	`
+	var createdAt time.Time
+	err := r.DB.QueryRow(ctx, query,
+		&webhook.Id,
+		&webhook.UserId,
+		&webhook.Url,
+		&webhook.Secret,
+		&webhook.EventTypes,
+		&webhook.IsGlobal,
+	).Scan(&createdAt)
+	if err != nil {
+		return "", err
+	}
+	return createdAt.Format(time.RFC3339), nil
+}
+
+func (r *WebhookRepositoryImpl) GetWebhooks(ctx context.Context, userId string) ([]*webhookentity.GetWebhookResponse, error) {
+	query := `
+		SELECT
+			id,
+			url,
+			event_types,
+			is_global,
+			created_at
+		FROM
+			webhooks
+		WHERE
+			user_id = $1
+		ORDER BY
+			created_at DESC
+	`
+	rows, err := r.DB.Query(ctx, query, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := []*webhookentity.GetWebhookResponse{}
+	for rows.Next() {
+		var webhook webhookentity.GetWebhookResponse
+		var createdAt time.Time
+		err := rows.Scan(
+			&webhook.Id,
+			&webhook.Url,
+			&webhook.EventTypes,
+			&webhook.Global,
+			&createdAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		webhook.CreatedAt = createdAt.Format(time.RFC3339)
+		webhooks = append(webhooks, &webhook)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return webhooks, nil
+}
+
+// DeleteWebhookById also removes its delivery log.
+func (r *WebhookRepositoryImpl) DeleteWebhookById(ctx context.Context, webhookId string) error {
+	query := `
+		DELETE FROM
+			webhooks
+		WHERE
+			id = $1
+	`
+	_, err := r.DB.Exec(ctx, query, webhookId)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// EnqueueEvent writes a delivery of the event for every webhook subscribed to
+// its type, either owned by one of the users or global. Webhooks the event
+// was already queued for are skipped, so dispatching an event again doesn't
+// send it twice.
+func (r *WebhookRepositoryImpl) EnqueueEvent(ctx context.Context, event *webhookentity.Event, userIds []string) error {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	webhooksQuery := `
+		SELECT
+			id
+		FROM
+			webhooks
+		WHERE
+			(user_id = ANY($1) OR is_global = true)
+			AND (event_types = '{}' OR $2 = ANY(event_types))
+	`
+	rows, err := tx.Query(ctx, webhooksQuery, userIds, event.Type)
+	if err != nil {
+		return err
+	}
+	webhookIds, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	insertQuery := `
+		INSERT INTO
+			webhook_deliveries (id, webhook_id, event_id, event_type, payload)
+		VALUES
+			($1, $2, $3, $4, $5)
+		ON CONFLICT (webhook_id, event_id) WHERE redelivery_of IS NULL DO NOTHING
+	`
+	for _, webhookId := range webhookIds {
+		_, err = tx.Exec(ctx, insertQuery, ulid.Make().String(), webhookId, event.Id, event.Type, payload)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ClaimDelivery marks the oldest due delivery as processing and returns it,
+// or nil when there is nothing to send. Deliveries stuck in processing,
+// because a worker died halfway, are picked up again after a few minutes, or
+// given up on when that was their last attempt. SKIP LOCKED lets several
+// workers claim deliveries at the same time.
+func (r *WebhookRepositoryImpl) ClaimDelivery(ctx context.Context, maxAttempts int) (*webhookentity.Delivery, error) {
+	failStaleQuery := `
+		UPDATE
+			webhook_deliveries
+		SET
+			status = 'failed',
+			error = 'the worker stopped during the last attempt',
+			updated_at = NOW()
+		WHERE
+			attempts >= $1
+			AND status = 'processing'
+			AND updated_at < NOW() - INTERVAL '5 minutes'
+	`
+	_, err := r.DB.Exec(ctx, failStaleQuery, maxAttempts)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		UPDATE
+			webhook_deliveries d
+		SET
+			status = 'processing',
+			attempts = d.attempts + 1,
+			updated_at = NOW()
+		FROM
+			webhooks w
+		WHERE
+			d.webhook_id = w.id
+			AND d.id = (
+				SELECT
+					id
+				FROM
+					webhook_deliveries
+				WHERE
+					attempts < $1
+					AND (
+						(status = 'pending' AND next_attempt_at <= LOCALTIMESTAMP)
+						OR (status = 'processing' AND updated_at < NOW() - INTERVAL '5 minutes')
+					)
+				ORDER BY
+					next_attempt_at
+				LIMIT 1
+				FOR UPDATE SKIP LOCKED
+			)
+		RETURNING
+			d.id, d.webhook_id, w.url, w.secret, d.event_id, d.event_type, d.payload, d.attempts
+	`
+	var delivery webhookentity.Delivery
+	err = r.DB.QueryRow(ctx, query, maxAttempts).Scan(
+		&delivery.Id,
+		&delivery.WebhookId,
+		&delivery.Url,
+		&delivery.Secret,
+		&delivery.EventId,
+		&delivery.EventType,
+		&delivery.Payload,
+		&delivery.Attempts,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+func (r *WebhookRepositoryImpl) CompleteDelivery(ctx context.Context, deliveryId string, responseStatus int) error {
+	query := `
+		UPDATE
+			webhook_deliveries
+		SET
+			status = 'delivered',
+			response_status = $2,
+			error = NULL,
+			delivered_at = NOW(),
+			updated_at = NOW()
+		WHERE
+			id = $1
+	`
+	_, err := r.DB.Exec(ctx, query, deliveryId, responseStatus)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// FailDelivery schedules the next attempt in retryIn, or gives up on the
+// delivery when retryIn is nil.
+func (r *WebhookRepositoryImpl) FailDelivery(ctx context.Context, deliveryId string, responseStatus *int, message string, retryIn *time.Duration) error {
+	query := `
+		UPDATE
+			webhook_deliveries
+		SET
+			status = $2,
+			response_status = $3,
+			error = $4,
+			next_attempt_at = LOCALTIMESTAMP + make_interval(secs => $5),
+			updated_at = NOW()
+		WHERE
+			id = $1
+	`
+	status := webhookentity.Failed
+	var seconds float64
+	if retryIn != nil {
+		status = webhookentity.Pending
+		seconds = retryIn.Seconds()
+	}
+	_, err := r.DB.Exec(ctx, query, deliveryId, status, responseStatus, message, seconds)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *WebhookRepositoryImpl) GetDeliveries(ctx context.Context, webhookId string, params *webhookentity.DeliveryQueryParams) ([]*webhookentity.GetDeliveryResponse, *webhookentity.DeliveryCursor, error) {
+	query := `SELECT ` + deliveryColumns + ` FROM webhook_deliveries WHERE webhook_id = $1`
+	args := []interface{}{webhookId}
+	argId := 2
+
+	if params.Cursor != nil {
+		query += ` AND (created_at, id) < ($` + strconv.Itoa(argId) + `, $` + strconv.Itoa(argId+1) + `)`
+		args = append(args, params.Cursor.CreatedAt, params.Cursor.Id)
+		argId += 2
+	}
+
+	// one extra row tells whether there is a next page
+	query += ` ORDER BY created_at DESC, id DESC LIMIT $` + strconv.Itoa(argId)
+	args = append(args, params.Limit+1)
+
+	rows, err := r.DB.Query(ctx, query, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	deliveries := make([]*webhookentity.GetDeliveryResponse, 0, params.Limit+1)
+	var next *webhookentity.DeliveryCursor
+	for rows.Next() {
+		delivery, createdAt, err := scanDelivery(rows)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if len(deliveries) == params.Limit-1 {
+			next = &webhookentity.DeliveryCursor{
+				CreatedAt: createdAt,
+				Id:        delivery.Id,
+			}
+		}
+
+		deliveries = append(deliveries, delivery)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	if len(deliveries) <= params.Limit {
+		return deliveries, nil, nil
+	}
+
+	return deliveries[:params.Limit], next, nil
+}
+
+// Redeliver queues a copy of the delivery, so the log keeps the outcome of
+// the original one. The copy points at the original delivery, which is what
+// keeps it out of the one delivery per event rule of EnqueueEvent.
+func (r *WebhookRepositoryImpl) Redeliver(ctx context.Context, webhookId, deliveryId string) (*webhookentity.GetDeliveryResponse, error) {
+	query := `
+		INSERT INTO
+			webhook_deliveries (id, webhook_id, event_id, event_type, payload, redelivery_of)
+		SELECT
+			$3, webhook_id, event_id, event_type, payload, COALESCE(redelivery_of, id)
+		FROM
+			webhook_deliveries
+		WHERE
+			id = $1
+			AND webhook_id = $2
+		RETURNING
+	` + deliveryColumns
+	delivery, _, err := scanDelivery(r.DB.QueryRow(ctx, query, deliveryId, webhookId, ulid.Make().String()))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, webhookerror.ErrDeliveryIdNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
+
+func scanDelivery(row pgx.Row) (*webhookentity.GetDeliveryResponse, time.Time, error) {
+	var delivery webhookentity.GetDeliveryResponse
+	var nextAttemptAt time.Time
+	var deliveredAt *time.Time
+	var createdAt time.Time
+	err := row.Scan(
+		&delivery.Id,
+		&delivery.EventId,
+		&delivery.EventType,
+		&delivery.Payload,
+		&delivery.Status,
+		&delivery.Attempts,
+		&delivery.ResponseStatus,
+		&delivery.Error,
+		&nextAttemptAt,
+		&deliveredAt,
+		&createdAt,
+	)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	// only deliveries still waiting have a next attempt
+	if delivery.Status == webhookentity.Pending {
+		formatted := nextAttemptAt.Format(time.RFC3339)
+		delivery.NextAttemptAt = &formatted
+	}
+	if deliveredAt != nil {
+		formatted := deliveredAt.Format(time.RFC3339)
+		delivery.DeliveredAt = &formatted
+	}
+	delivery.CreatedAt = createdAt.Format(time.RFC3339)
+	return &delivery, createdAt, nil
+}
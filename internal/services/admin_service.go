@@ -0,0 +1,167 @@
+package services
+
+import (
+	"context"
+	"log"
+
+	"github.com/danzBraham/cats-social/internal/entities/auditentity"
+	"github.com/danzBraham/cats-social/internal/entities/evententity"
+	"github.com/danzBraham/cats-social/internal/entities/userentity"
+	"github.com/danzBraham/cats-social/internal/errors/caterror"
+	"github.com/danzBraham/cats-social/internal/errors/matcherror"
+	"github.com/danzBraham/cats-social/internal/errors/usererror"
+	"github.com/danzBraham/cats-social/internal/repositories"
+	"github.com/oklog/ulid/v2"
+)
+
+type AdminService interface {
+	GetUsers(ctx context.Context, params *userentity.UserQueryParams) ([]*userentity.AdminUserResponse, error)
+	SuspendUser(ctx context.Context, adminId, userId string) error
+	UnsuspendUser(ctx context.Context, adminId, userId string) error
+	ForceDeleteCat(ctx context.Context, adminId, catId string) error
+	CancelMatch(ctx context.Context, adminId, matchId string) error
+}
+
+type AdminServiceImpl struct {
+	UserRepository  repositories.UserRepository
+	CatRepository   repositories.CatRepository
+	MatchRepository repositories.MatchRepository
+	AuditRepository repositories.AuditRepository
+	ImageService    ImageService
+}
+
+func NewAdminService(
+	userRepository repositories.UserRepository,
+	catRepository repositories.CatRepository,
+	matchRepository repositories.MatchRepository,
+	auditRepository repositories.AuditRepository,
+	imageService ImageService,
+) AdminService {
+	return &AdminServiceImpl{
+		UserRepository:  userRepository,
+		CatRepository:   catRepository,
+		MatchRepository: matchRepository,
+		AuditRepository: auditRepository,
+		ImageService:    imageService,
+	}
+}
+
+func (s *AdminServiceImpl) GetUsers(ctx context.Context, params *userentity.UserQueryParams) ([]*userentity.AdminUserResponse, error) {
+	return s.UserRepository.GetUsers(ctx, params)
+}
+
+func (s *AdminServiceImpl) SuspendUser(ctx context.Context, adminId, userId string) error {
+	if adminId == userId {
+		return usererror.ErrCannotSuspendYourself
+	}
+
+	_, err := s.UserRepository.GetUserById(ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	err = s.UserRepository.SuspendUserById(ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	s.audit(ctx, adminId, auditentity.UserSuspended, "user:"+userId)
+	return nil
+}
+
+func (s *AdminServiceImpl) UnsuspendUser(ctx context.Context, adminId, userId string) error {
+	_, err := s.UserRepository.GetUserById(ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	err = s.UserRepository.UnsuspendUserById(ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	s.audit(ctx, adminId, auditentity.UserUnsuspended, "user:"+userId)
+	return nil
+}
+
+func (s *AdminServiceImpl) ForceDeleteCat(ctx context.Context, adminId, catId string) error {
+	isCatIdExists, err := s.CatRepository.IsCatIdExists(ctx, catId)
+	if err != nil {
+		return err
+	}
+	if !isCatIdExists {
+		return caterror.ErrCatIdNotFound
+	}
+
+	cat, err := s.CatRepository.GetCatById(ctx, catId)
+	if err != nil {
+		return err
+	}
+
+	event, err := newEvent(evententity.CatDeleted, catId, &evententity.CatPayload{
+		CatId:   catId,
+		OwnerId: cat.OwnerId,
+	})
+	if err != nil {
+		return err
+	}
+
+	imageUrls, err := s.CatRepository.DeleteCatById(ctx, catId, event)
+	if err != nil {
+		return err
+	}
+
+	s.ImageService.RemoveCatImages(ctx, catId, imageUrls)
+	s.audit(ctx, adminId, auditentity.CatForceDeleted, "cat:"+catId)
+	return nil
+}
+
+func (s *AdminServiceImpl) CancelMatch(ctx context.Context, adminId, matchId string) error {
+	isMatchIdExists, err := s.MatchRepository.IsMatchIdExists(ctx, matchId)
+	if err != nil {
+		return err
+	}
+	if !isMatchIdExists {
+		return matcherror.ErrMatchIdNotFound
+	}
+
+	isMatchIdValid, err := s.MatchRepository.IsMatchIdValid(ctx, matchId)
+	if err != nil {
+		return err
+	}
+	if !isMatchIdValid {
+		return matcherror.ErrMatchIdIsNoLongerValid
+	}
+
+	parties, err := s.MatchRepository.GetMatchParties(ctx, matchId)
+	if err != nil {
+		return err
+	}
+
+	event, err := newMatchEvent(evententity.MatchDeleted, parties, parties.Status, adminId)
+	if err != nil {
+		return err
+	}
+
+	err = s.MatchRepository.DeleteMatch(ctx, matchId, event)
+	if err != nil {
+		return err
+	}
+
+	s.audit(ctx, adminId, auditentity.MatchCancelled, "match:"+matchId)
+	return nil
+}
+
+// audit records an administrative action. The action itself already
+// happened, so a failure is only logged.
+func (s *AdminServiceImpl) audit(ctx context.Context, adminId string, action auditentity.Action, subject string) {
+	auditLog := &auditentity.AuditLog{
+		Id:      ulid.Make().String(),
+		UserId:  adminId,
+		Action:  action,
+		Subject: subject,
+	}
+	if err := s.AuditRepository.CreateAuditLog(ctx, auditLog); err != nil {
+		log.Printf("failed to write audit log %s for %s: %v", action, subject, err)
+	}
+}
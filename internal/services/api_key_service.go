@@ -0,0 +1,76 @@
+package services
+
+import (
+	"context"
+
+	"github.com/danzBraham/cats-social/internal/entities/apikeyentity"
+	"github.com/danzBraham/cats-social/internal/errors/apikeyerror"
+	"github.com/danzBraham/cats-social/internal/helpers/randtoken"
+	"github.com/danzBraham/cats-social/internal/repositories"
+	"github.com/oklog/ulid/v2"
+)
+
+const (
+	ApiKeyPrefix       = "cs_"
+	apiKeyDisplayChars = 10
+)
+
+type ApiKeyService interface {
+	CreateApiKey(ctx context.Context, userId string, payload *apikeyentity.CreateApiKeyRequest) (*apikeyentity.CreateApiKeyResponse, error)
+	GetApiKeys(ctx context.Context, userId string) ([]*apikeyentity.GetApiKeyResponse, error)
+	RevokeApiKey(ctx context.Context, userId, apiKeyId string) error
+}
+
+type ApiKeyServiceImpl struct {
+	ApiKeyRepository repositories.ApiKeyRepository
+}
+
+func NewApiKeyService(apiKeyRepository repositories.ApiKeyRepository) ApiKeyService {
+	return &ApiKeyServiceImpl{ApiKeyRepository: apiKeyRepository}
+}
+
+func (s *ApiKeyServiceImpl) CreateApiKey(ctx context.Context, userId string, payload *apikeyentity.CreateApiKeyRequest) (*apikeyentity.CreateApiKeyResponse, error) {
+	secret, err := randtoken.Generate(32)
+	if err != nil {
+		return nil, err
+	}
+	key := ApiKeyPrefix + secret
+
+	apiKey := &apikeyentity.ApiKey{
+		Id:      ulid.Make().String(),
+		UserId:  userId,
+		Name:    payload.Name,
+		Prefix:  key[:apiKeyDisplayChars],
+		KeyHash: randtoken.Hash(key),
+		Scopes:  payload.Scopes,
+	}
+
+	createdAt, err := s.ApiKeyRepository.CreateApiKey(ctx, apiKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &apikeyentity.CreateApiKeyResponse{
+		Id:        apiKey.Id,
+		Name:      apiKey.Name,
+		Key:       key,
+		Scopes:    apiKey.Scopes,
+		CreatedAt: createdAt,
+	}, nil
+}
+
+func (s *ApiKeyServiceImpl) GetApiKeys(ctx context.Context, userId string) ([]*apikeyentity.GetApiKeyResponse, error) {
+	return s.ApiKeyRepository.GetApiKeys(ctx, userId)
+}
+
+func (s *ApiKeyServiceImpl) RevokeApiKey(ctx context.Context, userId, apiKeyId string) error {
+	isApiKeyOwner, err := s.ApiKeyRepository.IsApiKeyOwner(ctx, apiKeyId, userId)
+	if err != nil {
+		return err
+	}
+	if !isApiKeyOwner {
+		return apikeyerror.ErrApiKeyIdNotFound
+	}
+
+	return s.ApiKeyRepository.RevokeApiKeyById(ctx, apiKeyId)
+}
@@ -2,29 +2,56 @@ package services
 
 import (
 	"context"
+	"errors"
+	"math"
+	"os"
+	"slices"
+	"strconv"
 
 	"github.com/danzBraham/cats-social/internal/entities/catentity"
+	"github.com/danzBraham/cats-social/internal/entities/evententity"
 	"github.com/danzBraham/cats-social/internal/errors/caterror"
+	"github.com/danzBraham/cats-social/internal/helpers/cursor"
 	"github.com/danzBraham/cats-social/internal/repositories"
 	"github.com/oklog/ulid/v2"
 )
 
 type CatService interface {
 	CreateCat(ctx context.Context, userId string, payload *catentity.CreateCatRequest) (*catentity.CreateCatResponse, error)
-	GetCats(ctx context.Context, userId string, params *catentity.CatQueryParams) ([]*catentity.GetCatResponse, error)
+	GetCats(ctx context.Context, userId string, params *catentity.CatQueryParams) (*catentity.CatPage, error)
+	GetCatById(ctx context.Context, catId string) (*catentity.GetCatResponse, error)
 	UpdateCatById(ctx context.Context, userId, catId string, payload *catentity.UpdateCatRequest) error
+	PatchCatById(ctx context.Context, userId, catId string, payload *catentity.PatchCatRequest) error
+	UploadCatImages(ctx context.Context, userId, catId string, images [][]byte) (*catentity.UploadCatImagesResponse, error)
+	GetCatImages(ctx context.Context, catId string) ([]*catentity.CatImage, error)
+	UpdateCatImage(ctx context.Context, userId, catId, imageId string, payload *catentity.UpdateCatImageRequest) error
+	ReorderCatImages(ctx context.Context, userId, catId string, payload *catentity.ReorderCatImagesRequest) error
+	DeleteCatImage(ctx context.Context, userId, catId, imageId string) error
+	GetRecommendations(ctx context.Context, userId, catId string, params *catentity.RecommendationQueryParams) (*catentity.RecommendationPage, error)
 	DeleteCatById(ctx context.Context, userId, catId string) error
 }
 
 type CatServiceImpl struct {
-	CatRepository   repositories.CatRepository
-	MatchRepository repositories.MatchRepository
+	CatRepository      repositories.CatRepository
+	CatImageRepository repositories.CatImageRepository
+	MatchRepository    repositories.MatchRepository
+	UserRepository     repositories.UserRepository
+	ImageService       ImageService
 }
 
-func NewCatService(catRepository repositories.CatRepository, matchRepository repositories.MatchRepository) CatService {
+func NewCatService(
+	catRepository repositories.CatRepository,
+	catImageRepository repositories.CatImageRepository,
+	matchRepository repositories.MatchRepository,
+	userRepository repositories.UserRepository,
+	imageService ImageService,
+) CatService {
 	return &CatServiceImpl{
-		CatRepository:   catRepository,
-		MatchRepository: matchRepository,
+		CatRepository:      catRepository,
+		CatImageRepository: catImageRepository,
+		MatchRepository:    matchRepository,
+		UserRepository:     userRepository,
+		ImageService:       imageService,
 	}
 }
 
@@ -37,10 +64,32 @@ func (s *CatServiceImpl) CreateCat(ctx context.Context, userId string, payload *
 		AgeInMonth:  payload.AgeInMonth,
 		Description: payload.Description,
 		ImageUrls:   payload.ImageUrls,
+		Location:    payload.Location,
 		OwnerId:     userId,
 	}
+	if cat.ImageUrls == nil {
+		cat.ImageUrls = []string{}
+	}
+
+	err := s.defaultToOwnerLocation(ctx, userId, cat)
+	if err != nil {
+		return nil, err
+	}
 
-	createdAt, err := s.CatRepository.CreateCat(ctx, cat)
+	event, err := newEvent(evententity.CatCreated, cat.Id, &evententity.CatPayload{
+		CatId:       cat.Id,
+		OwnerId:     userId,
+		Name:        cat.Name,
+		Race:        string(cat.Race),
+		Sex:         string(cat.Sex),
+		AgeInMonth:  cat.AgeInMonth,
+		Description: cat.Description,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	createdAt, err := s.CatRepository.CreateCat(ctx, cat, event)
 	if err != nil {
 		return nil, err
 	}
@@ -51,8 +100,67 @@ func (s *CatServiceImpl) CreateCat(ctx context.Context, userId string, payload *
 	}, nil
 }
 
-func (s *CatServiceImpl) GetCats(ctx context.Context, userId string, params *catentity.CatQueryParams) ([]*catentity.GetCatResponse, error) {
-	return s.CatRepository.GetCats(ctx, userId, params)
+func (s *CatServiceImpl) GetCats(ctx context.Context, userId string, params *catentity.CatQueryParams) (*catentity.CatPage, error) {
+	cats, next, err := s.CatRepository.GetCats(ctx, userId, params)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.attachImages(ctx, cats...)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &catentity.CatPage{
+		Cats:    cats,
+		HasMore: next != nil,
+	}
+	if next != nil {
+		values := append([]interface{}{catentity.SortKey(params.Sort)}, next.Values...)
+		page.NextCursor = cursor.Encode(values...)
+	}
+
+	if params.IncludeTotal {
+		total, err := s.CatRepository.CountCats(ctx, userId, params)
+		if err != nil {
+			return nil, err
+		}
+		page.Total = &total
+	}
+
+	return page, nil
+}
+
+func (s *CatServiceImpl) GetCatById(ctx context.Context, catId string) (*catentity.GetCatResponse, error) {
+	cat, err := s.CatRepository.GetCatById(ctx, catId)
+	if errors.Is(err, caterror.ErrCatNotFound) {
+		return nil, caterror.ErrCatIdNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	catResponse := &catentity.GetCatResponse{
+		Id:          cat.Id,
+		Name:        cat.Name,
+		Race:        cat.Race,
+		Sex:         cat.Sex,
+		AgeInMonth:  cat.AgeInMonth,
+		Description: cat.Description,
+		ImageUrls:   cat.ImageUrls,
+		HasMatched:  cat.HasMatched,
+		CreatedAt:   cat.CreatedAt,
+	}
+	if cat.Location != nil {
+		catResponse.City = cat.Location.City
+	}
+
+	err = s.attachImages(ctx, catResponse)
+	if err != nil {
+		return nil, err
+	}
+
+	return catResponse, nil
 }
 
 func (s *CatServiceImpl) UpdateCatById(ctx context.Context, userId, catId string, payload *catentity.UpdateCatRequest) error {
@@ -72,12 +180,14 @@ func (s *CatServiceImpl) UpdateCatById(ctx context.Context, userId, catId string
 		return caterror.ErrNotCatOwner
 	}
 
-	isMatchRequestExists, err := s.MatchRepository.IsMatchRequestExists(ctx, catId, catId)
+	err = s.ensureSexIsEditable(ctx, catId)
 	if err != nil {
 		return err
 	}
-	if isMatchRequestExists {
-		return caterror.ErrSexIsEdited
+
+	oldCat, err := s.CatRepository.GetCatById(ctx, catId)
+	if err != nil {
+		return err
 	}
 
 	cat := &catentity.Cat{
@@ -87,13 +197,181 @@ func (s *CatServiceImpl) UpdateCatById(ctx context.Context, userId, catId string
 		AgeInMonth:  payload.AgeInMonth,
 		Description: payload.Description,
 		ImageUrls:   payload.ImageUrls,
+		Location:    payload.Location,
+	}
+
+	err = s.defaultToOwnerLocation(ctx, userId, cat)
+	if err != nil {
+		return err
+	}
+
+	event, err := newEvent(evententity.CatUpdated, catId, &evententity.CatPayload{
+		CatId:       catId,
+		OwnerId:     userId,
+		Name:        cat.Name,
+		Race:        string(cat.Race),
+		Sex:         string(cat.Sex),
+		AgeInMonth:  cat.AgeInMonth,
+		Description: cat.Description,
+	})
+	if err != nil {
+		return err
+	}
+
+	err = s.CatRepository.UpdateCatById(ctx, catId, cat, event)
+	if err != nil {
+		return err
+	}
+
+	s.ImageService.RemoveCatImages(ctx, catId, replacedImageUrls(oldCat.ImageUrls, cat.ImageUrls))
+	return nil
+}
+
+func (s *CatServiceImpl) PatchCatById(ctx context.Context, userId, catId string, payload *catentity.PatchCatRequest) error {
+	cat, err := s.CatRepository.GetCatById(ctx, catId)
+	if errors.Is(err, caterror.ErrCatNotFound) {
+		return caterror.ErrCatIdNotFound
+	}
+	if err != nil {
+		return err
 	}
 
-	err = s.CatRepository.UpdateCatById(ctx, catId, cat)
+	if cat.OwnerId != userId {
+		return caterror.ErrNotCatOwner
+	}
+
+	if payload.Sex != nil && *payload.Sex != cat.Sex {
+		err = s.ensureSexIsEditable(ctx, catId)
+		if err != nil {
+			return err
+		}
+	}
+
+	patched := &evententity.CatPayload{
+		CatId:   catId,
+		OwnerId: userId,
+	}
+	if payload.Name != nil {
+		patched.Name = *payload.Name
+	}
+	if payload.Race != nil {
+		patched.Race = string(*payload.Race)
+	}
+	if payload.Sex != nil {
+		patched.Sex = string(*payload.Sex)
+	}
+	if payload.AgeInMonth != nil {
+		patched.AgeInMonth = *payload.AgeInMonth
+	}
+	if payload.Description != nil {
+		patched.Description = *payload.Description
+	}
+
+	event, err := newEvent(evententity.CatUpdated, catId, patched)
+	if err != nil {
+		return err
+	}
+
+	err = s.CatRepository.PatchCatById(ctx, catId, payload, event)
 	if err != nil {
 		return err
 	}
 
+	if payload.ImageUrls != nil {
+		s.ImageService.RemoveCatImages(ctx, catId, replacedImageUrls(cat.ImageUrls, *payload.ImageUrls))
+	}
+	return nil
+}
+
+func (s *CatServiceImpl) UploadCatImages(ctx context.Context, userId, catId string, images [][]byte) (*catentity.UploadCatImagesResponse, error) {
+	err := s.ensureCatOwner(ctx, userId, catId)
+	if err != nil {
+		return nil, err
+	}
+
+	imageUrls, err := s.ImageService.StoreCatImages(ctx, catId, images)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.CatImageRepository.AddCatImages(ctx, catId, imageUrls)
+	if err != nil {
+		s.ImageService.RemoveCatImages(ctx, catId, imageUrls)
+		return nil, err
+	}
+
+	catImages, err := s.GetCatImages(ctx, catId)
+	if err != nil {
+		return nil, err
+	}
+
+	imagesResponse := &catentity.UploadCatImagesResponse{
+		ImageUrls: make([]string, 0, len(catImages)),
+		Images:    catImages,
+	}
+	for _, catImage := range catImages {
+		imagesResponse.ImageUrls = append(imagesResponse.ImageUrls, catImage.Url)
+	}
+
+	return imagesResponse, nil
+}
+
+func (s *CatServiceImpl) GetCatImages(ctx context.Context, catId string) ([]*catentity.CatImage, error) {
+	isCatIdExists, err := s.CatRepository.IsCatIdExists(ctx, catId)
+	if err != nil {
+		return nil, err
+	}
+	if !isCatIdExists {
+		return nil, caterror.ErrCatIdNotFound
+	}
+
+	cat := &catentity.GetCatResponse{Id: catId}
+	err = s.attachImages(ctx, cat)
+	if err != nil {
+		return nil, err
+	}
+
+	return cat.Images, nil
+}
+
+func (s *CatServiceImpl) UpdateCatImage(ctx context.Context, userId, catId, imageId string, payload *catentity.UpdateCatImageRequest) error {
+	err := s.ensureCatOwner(ctx, userId, catId)
+	if err != nil {
+		return err
+	}
+
+	isCatImageExists, err := s.CatImageRepository.IsCatImageExists(ctx, catId, imageId)
+	if err != nil {
+		return err
+	}
+	if !isCatImageExists {
+		return caterror.ErrCatImageIdNotFound
+	}
+
+	return s.CatImageRepository.UpdateCatImage(ctx, catId, imageId, payload)
+}
+
+func (s *CatServiceImpl) ReorderCatImages(ctx context.Context, userId, catId string, payload *catentity.ReorderCatImagesRequest) error {
+	err := s.ensureCatOwner(ctx, userId, catId)
+	if err != nil {
+		return err
+	}
+
+	return s.CatImageRepository.ReorderCatImages(ctx, catId, payload.ImageIds)
+}
+
+func (s *CatServiceImpl) DeleteCatImage(ctx context.Context, userId, catId, imageId string) error {
+	err := s.ensureCatOwner(ctx, userId, catId)
+	if err != nil {
+		return err
+	}
+
+	imageUrl, err := s.CatImageRepository.DeleteCatImage(ctx, catId, imageId)
+	if err != nil {
+		return err
+	}
+
+	s.ImageService.RemoveCatImages(ctx, catId, []string{imageUrl})
 	return nil
 }
 
@@ -114,10 +392,186 @@ func (s *CatServiceImpl) DeleteCatById(ctx context.Context, userId, catId string
 		return caterror.ErrNotCatOwner
 	}
 
-	err = s.CatRepository.DeleteCatById(ctx, catId)
+	event, err := newEvent(evententity.CatDeleted, catId, &evententity.CatPayload{
+		CatId:   catId,
+		OwnerId: userId,
+	})
 	if err != nil {
 		return err
 	}
 
+	imageUrls, err := s.CatRepository.DeleteCatById(ctx, catId, event)
+	if err != nil {
+		return err
+	}
+
+	s.ImageService.RemoveCatImages(ctx, catId, imageUrls)
+	return nil
+}
+
+// ensureSexIsEditable rejects sex changes while the cat is part of a pending
+// match request.
+func (s *CatServiceImpl) ensureSexIsEditable(ctx context.Context, catId string) error {
+	isMatchRequestExists, err := s.MatchRepository.IsMatchRequestExists(ctx, catId, catId)
+	if err != nil {
+		return err
+	}
+	if isMatchRequestExists {
+		return caterror.ErrSexIsEdited
+	}
 	return nil
 }
+
+func replacedImageUrls(oldImageUrls, newImageUrls []string) []string {
+	replaced := []string{}
+	for _, imageUrl := range oldImageUrls {
+		if !slices.Contains(newImageUrls, imageUrl) {
+			replaced = append(replaced, imageUrl)
+		}
+	}
+	return replaced
+}
+
+// attachImages fills Images with the ordered images of the cats and the
+// dimensions and variants of the processed ones, using one query per table
+// for all cats.
+func (s *CatServiceImpl) attachImages(ctx context.Context, cats ...*catentity.GetCatResponse) error {
+	catIds := make([]string, 0, len(cats))
+	for _, cat := range cats {
+		catIds = append(catIds, cat.Id)
+	}
+
+	catImages, err := s.CatImageRepository.GetCatImages(ctx, catIds)
+	if err != nil {
+		return err
+	}
+
+	imageUrls := []string{}
+	for _, images := range catImages {
+		for _, image := range images {
+			imageUrls = append(imageUrls, image.Url)
+		}
+	}
+
+	processedImages, err := s.ImageService.GetImages(ctx, imageUrls)
+	if err != nil {
+		return err
+	}
+
+	for _, cat := range cats {
+		cat.Images = catImages[cat.Id]
+		if cat.Images == nil {
+			cat.Images = []*catentity.CatImage{}
+		}
+		for _, image := range cat.Images {
+			if processedImage, ok := processedImages[image.Url]; ok {
+				image.Width = processedImage.Width
+				image.Height = processedImage.Height
+				image.Thumbnail = processedImage.Thumbnail
+				image.Medium = processedImage.Medium
+			}
+		}
+	}
+	return nil
+}
+
+// GetRecommendations lists the cats that the user's cat could request a match
+// with, best first.
+func (s *CatServiceImpl) GetRecommendations(ctx context.Context, userId, catId string, params *catentity.RecommendationQueryParams) (*catentity.RecommendationPage, error) {
+	err := s.ensureCatOwner(ctx, userId, catId)
+	if err != nil {
+		return nil, err
+	}
+
+	params.Weights = recommendationWeights()
+	recommendations, next, err := s.CatRepository.GetRecommendations(ctx, catId, params)
+	if err != nil {
+		return nil, err
+	}
+
+	cats := make([]*catentity.GetCatResponse, 0, len(recommendations))
+	for _, recommendation := range recommendations {
+		cats = append(cats, recommendation.Cat)
+	}
+	err = s.attachImages(ctx, cats...)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &catentity.RecommendationPage{
+		Recommendations: recommendations,
+		HasMore:         next != nil,
+	}
+	if next != nil {
+		page.NextCursor = cursor.Encode(next.AsOf, next.Score, next.Id)
+	}
+
+	// rounded after the cursor took the exact score
+	for _, recommendation := range recommendations {
+		recommendation.Score = roundScore(recommendation.Score)
+		recommendation.Factors.SameRace = roundScore(recommendation.Factors.SameRace)
+		recommendation.Factors.AgeProximity = roundScore(recommendation.Factors.AgeProximity)
+		recommendation.Factors.Distance = roundScore(recommendation.Factors.Distance)
+		recommendation.Factors.Recency = roundScore(recommendation.Factors.Recency)
+	}
+
+	return page, nil
+}
+
+// defaultToOwnerLocation places a cat sent without a location where its owner
+// lives, if the owner has set a location.
+func (s *CatServiceImpl) defaultToOwnerLocation(ctx context.Context, userId string, cat *catentity.Cat) error {
+	if cat.Location != nil {
+		return nil
+	}
+
+	owner, err := s.UserRepository.GetUserById(ctx, userId)
+	if err != nil {
+		return err
+	}
+	cat.Location = owner.Location
+	return nil
+}
+
+// ensureCatOwner checks that the cat exists and belongs to the user.
+func (s *CatServiceImpl) ensureCatOwner(ctx context.Context, userId, catId string) error {
+	isCatIdExists, err := s.CatRepository.IsCatIdExists(ctx, catId)
+	if err != nil {
+		return err
+	}
+	if !isCatIdExists {
+		return caterror.ErrCatIdNotFound
+	}
+
+	isCatOwner, err := s.CatRepository.IsCatOwner(ctx, catId, userId)
+	if err != nil {
+		return err
+	}
+	if !isCatOwner {
+		return caterror.ErrNotCatOwner
+	}
+	return nil
+}
+
+// recommendationWeights reads the RECOMMENDATION_WEIGHT_* variables, a weight
+// that is missing, negative or not a number keeps its default.
+func recommendationWeights() catentity.RecommendationWeights {
+	return catentity.RecommendationWeights{
+		SameRace:     recommendationWeight("RECOMMENDATION_WEIGHT_RACE", 1),
+		AgeProximity: recommendationWeight("RECOMMENDATION_WEIGHT_AGE", 1),
+		Distance:     recommendationWeight("RECOMMENDATION_WEIGHT_DISTANCE", 1),
+		Recency:      recommendationWeight("RECOMMENDATION_WEIGHT_RECENCY", 0.5),
+	}
+}
+
+func recommendationWeight(key string, defaultWeight float64) float64 {
+	weight, err := strconv.ParseFloat(os.Getenv(key), 64)
+	if err != nil || !(weight >= 0) || math.IsInf(weight, 0) {
+		return defaultWeight
+	}
+	return weight
+}
+
+func roundScore(score float64) float64 {
+	return math.Round(score*1000) / 1000
+}
@@ -0,0 +1,142 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/danzBraham/cats-social/internal/entities/evententity"
+	"github.com/danzBraham/cats-social/internal/entities/matchentity"
+	"github.com/danzBraham/cats-social/internal/repositories"
+	"github.com/oklog/ulid/v2"
+)
+
+const maxEventAttempts = 10
+
+// EventHandler reacts to a domain event. Delivery is at least once, so a
+// handler may see the same event again and has to be idempotent, for
+// example by keying on the event id.
+type EventHandler func(ctx context.Context, event *evententity.Event) error
+
+// EventBus dispatches the events of the outbox to the handlers subscribed to
+// their type. An event is retried with backoff until every handler succeeded
+// or it has been attempted maxEventAttempts times; handlers that succeeded
+// are not run again.
+type EventBus interface {
+	Subscribe(name string, handler EventHandler, eventTypes ...evententity.Type)
+	DispatchNext(ctx context.Context) (bool, error)
+}
+
+type eventSubscription struct {
+	name       string
+	handler    EventHandler
+	eventTypes []evententity.Type
+}
+
+type EventBusImpl struct {
+	OutboxRepository repositories.OutboxRepository
+	subscriptions    []*eventSubscription
+}
+
+func NewEventBus(outboxRepository repositories.OutboxRepository) EventBus {
+	return &EventBusImpl{OutboxRepository: outboxRepository}
+}
+
+// Subscribe registers the handler under a name that must stay the same
+// across deploys, it's what records the handler as done with an event. No
+// event types subscribes to every event. Subscribe before the bus is run.
+func (b *EventBusImpl) Subscribe(name string, handler EventHandler, eventTypes ...evententity.Type) {
+	b.subscriptions = append(b.subscriptions, &eventSubscription{
+		name:       name,
+		handler:    handler,
+		eventTypes: eventTypes,
+	})
+}
+
+// DispatchNext dispatches one due event. It reports false when there is
+// nothing to dispatch.
+func (b *EventBusImpl) DispatchNext(ctx context.Context) (bool, error) {
+	event, err := b.OutboxRepository.ClaimEvent(ctx, maxEventAttempts)
+	if err != nil {
+		return false, err
+	}
+	if event == nil {
+		return false, nil
+	}
+
+	var errs []error
+	for _, subscription := range b.subscriptions {
+		if len(subscription.eventTypes) > 0 && !slices.Contains(subscription.eventTypes, event.Type) {
+			continue
+		}
+		if slices.Contains(event.HandledBy, subscription.name) {
+			continue
+		}
+
+		err := runEventHandler(ctx, subscription.handler, event)
+		if err == nil {
+			err = b.OutboxRepository.MarkEventHandled(ctx, event.Id, subscription.name)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", subscription.name, err))
+		}
+	}
+
+	if len(errs) == 0 {
+		return true, b.OutboxRepository.CompleteEvent(ctx, event.Id)
+	}
+
+	var retryIn *time.Duration
+	if event.Attempts < maxEventAttempts {
+		delay := retryDelay(event.Attempts)
+		retryIn = &delay
+	}
+
+	message := errors.Join(errs...).Error()
+	if len(message) > maxErrorLength {
+		message = message[:maxErrorLength]
+	}
+	return true, b.OutboxRepository.FailEvent(ctx, event.Id, message, retryIn)
+}
+
+// runEventHandler turns a panicking handler into a failed one, so it's
+// retried like any other failure instead of taking the dispatcher down.
+func runEventHandler(ctx context.Context, handler EventHandler, event *evententity.Event) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return handler(ctx, event)
+}
+
+func newEvent(eventType evententity.Type, aggregateId string, payload interface{}) (*evententity.Event, error) {
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return &evententity.Event{
+		Id:          ulid.Make().String(),
+		Type:        eventType,
+		AggregateId: aggregateId,
+		Payload:     encoded,
+	}, nil
+}
+
+// newMatchEvent describes a change of the match request to status, made by
+// actorId.
+func newMatchEvent(eventType evententity.Type, parties *matchentity.MatchParties, status matchentity.Status, actorId string) (*evententity.Event, error) {
+	return newEvent(eventType, parties.Id, &evententity.MatchPayload{
+		MatchId:    parties.Id,
+		MatchCatId: parties.MatchCatId,
+		UserCatId:  parties.UserCatId,
+		IssuerId:   parties.IssuerId,
+		ReceiverId: parties.ReceiverId,
+		Status:     string(status),
+		ActorId:    actorId,
+	})
+}
@@ -0,0 +1,234 @@
+package services
+
+import (
+	"context"
+	"image"
+	"log"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/danzBraham/cats-social/internal/blobstore"
+	"github.com/danzBraham/cats-social/internal/entities/imageentity"
+	"github.com/danzBraham/cats-social/internal/errors/caterror"
+	"github.com/danzBraham/cats-social/internal/helpers/imaging"
+	"github.com/danzBraham/cats-social/internal/repositories"
+	"github.com/oklog/ulid/v2"
+)
+
+const (
+	MaxImageSize       = 5 << 20
+	MaxImagesPerUpload = 5
+
+	maxVariantAttempts = 3
+)
+
+// imageExtensions holds the accepted image types, keyed by their sniffed
+// content type.
+var imageExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// Bounding boxes of the generated variants. Webp images can't be decoded with
+// the standard library, so they are only served as uploaded.
+const (
+	thumbnailSize = 320
+	mediumSize    = 1024
+)
+
+type ImageService interface {
+	StoreCatImages(ctx context.Context, catId string, images [][]byte) ([]string, error)
+	GetImages(ctx context.Context, imageUrls []string) (map[string]*imageentity.Image, error)
+	RemoveCatImages(ctx context.Context, catId string, imageUrls []string)
+	ProcessNextVariantJob(ctx context.Context) (bool, error)
+}
+
+type ImageServiceImpl struct {
+	ImageVariantRepository repositories.ImageVariantRepository
+	BlobStore              blobstore.BlobStore
+}
+
+func NewImageService(imageVariantRepository repositories.ImageVariantRepository, blobStore blobstore.BlobStore) ImageService {
+	return &ImageServiceImpl{
+		ImageVariantRepository: imageVariantRepository,
+		BlobStore:              blobStore,
+	}
+}
+
+// StoreCatImages validates and stores the images, then queues the generation
+// of their variants. Nothing is stored when any image is rejected.
+func (s *ImageServiceImpl) StoreCatImages(ctx context.Context, catId string, images [][]byte) ([]string, error) {
+	if len(images) == 0 {
+		return nil, caterror.ErrNoImagesUploaded
+	}
+	if len(images) > MaxImagesPerUpload {
+		return nil, caterror.ErrTooManyImages
+	}
+
+	contentTypes := make([]string, len(images))
+	for i, image := range images {
+		if len(image) > MaxImageSize {
+			return nil, caterror.ErrImageTooLarge
+		}
+		contentTypes[i] = http.DetectContentType(image)
+		if _, ok := imageExtensions[contentTypes[i]]; !ok {
+			return nil, caterror.ErrUnsupportedImageType
+		}
+	}
+
+	imageUrls := make([]string, 0, len(images))
+	for i, image := range images {
+		key := catImagePrefix(catId) + ulid.Make().String() + imageExtensions[contentTypes[i]]
+		err := s.BlobStore.Put(ctx, key, contentTypes[i], image)
+		if err != nil {
+			s.RemoveCatImages(ctx, catId, imageUrls)
+			return nil, err
+		}
+		imageUrls = append(imageUrls, s.BlobStore.URL(key))
+
+		if contentTypes[i] == "image/webp" {
+			continue
+		}
+		err = s.ImageVariantRepository.CreateVariantJob(ctx, &imageentity.VariantJob{
+			SourceUrl: s.BlobStore.URL(key),
+			SourceKey: key,
+			CatId:     catId,
+		})
+		if err != nil {
+			s.RemoveCatImages(ctx, catId, imageUrls)
+			return nil, err
+		}
+	}
+
+	return imageUrls, nil
+}
+
+func (s *ImageServiceImpl) GetImages(ctx context.Context, imageUrls []string) (map[string]*imageentity.Image, error) {
+	return s.ImageVariantRepository.GetImages(ctx, imageUrls)
+}
+
+// RemoveCatImages deletes the images that were uploaded for the cat along
+// with their variants. Images hosted elsewhere or uploaded for another cat
+// are skipped, a cat can list any url so that's no proof the owner may
+// delete it. Failures are only logged, the cat itself is already updated at
+// this point.
+func (s *ImageServiceImpl) RemoveCatImages(ctx context.Context, catId string, imageUrls []string) {
+	prefix := catImagePrefix(catId)
+
+	keys := []string{}
+	ownImageUrls := []string{}
+	for _, imageUrl := range imageUrls {
+		key, ok := s.BlobStore.Key(imageUrl)
+		if !ok || !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		keys = append(keys, key)
+		ownImageUrls = append(ownImageUrls, imageUrl)
+	}
+
+	variantUrls, err := s.ImageVariantRepository.DeleteImageVariants(ctx, catId, ownImageUrls)
+	if err != nil {
+		log.Printf("failed to delete image variants: %v", err)
+	}
+	for _, variantUrl := range variantUrls {
+		key, ok := s.BlobStore.Key(variantUrl)
+		if !ok || !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		keys = append(keys, key)
+	}
+
+	for _, key := range keys {
+		if err := s.BlobStore.Delete(ctx, key); err != nil {
+			log.Printf("failed to delete image %s: %v", key, err)
+		}
+	}
+}
+
+// catImagePrefix is the blob store prefix of the images uploaded for a cat.
+func catImagePrefix(catId string) string {
+	return "cats/" + catId + "/"
+}
+
+// ProcessNextVariantJob generates the variants of one queued image. It
+// reports false when the queue is empty.
+func (s *ImageServiceImpl) ProcessNextVariantJob(ctx context.Context) (bool, error) {
+	job, err := s.ImageVariantRepository.ClaimVariantJob(ctx, maxVariantAttempts)
+	if err != nil {
+		return false, err
+	}
+	if job == nil {
+		return false, nil
+	}
+
+	err = s.generateVariants(ctx, job)
+	if err != nil {
+		log.Printf("failed to generate variants of %s (attempt %d): %v", job.SourceKey, job.Attempts, err)
+		return true, s.ImageVariantRepository.FailVariantJob(ctx, job.SourceUrl, job.Attempts < maxVariantAttempts)
+	}
+
+	return true, nil
+}
+
+func (s *ImageServiceImpl) generateVariants(ctx context.Context, job *imageentity.VariantJob) error {
+	data, err := s.BlobStore.Get(ctx, job.SourceKey)
+	if err != nil {
+		return err
+	}
+
+	img, err := imaging.Decode(data)
+	if err != nil {
+		return err
+	}
+
+	original := &imageentity.Image{
+		Url:    job.SourceUrl,
+		Width:  img.Bounds().Dx(),
+		Height: img.Bounds().Dy(),
+	}
+
+	original.Thumbnail, err = s.storeVariant(ctx, img, job.SourceKey, "thumbnail", thumbnailSize)
+	if err != nil {
+		return err
+	}
+
+	original.Medium, err = s.storeVariant(ctx, img, job.SourceKey, "medium", mediumSize)
+	if err != nil {
+		return err
+	}
+
+	isCompleted, err := s.ImageVariantRepository.CompleteVariantJob(ctx, job.SourceUrl, original)
+	if err != nil {
+		return err
+	}
+
+	// the image was removed in the meantime, so nobody references the variants
+	if !isCompleted {
+		s.RemoveCatImages(ctx, job.CatId, []string{original.Thumbnail.Url, original.Medium.Url})
+	}
+
+	return nil
+}
+
+func (s *ImageServiceImpl) storeVariant(ctx context.Context, img *image.RGBA, sourceKey, name string, size int) (*imageentity.Variant, error) {
+	variant := imaging.Fit(img, size, size)
+	encoded, contentType, extension, err := imaging.Encode(variant)
+	if err != nil {
+		return nil, err
+	}
+
+	key := strings.TrimSuffix(sourceKey, path.Ext(sourceKey)) + "_" + name + extension
+	err = s.BlobStore.Put(ctx, key, contentType, encoded)
+	if err != nil {
+		return nil, err
+	}
+
+	return &imageentity.Variant{
+		Url:    s.BlobStore.URL(key),
+		Width:  variant.Bounds().Dx(),
+		Height: variant.Bounds().Dy(),
+	}, nil
+}
@@ -0,0 +1,152 @@
+package services
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/danzBraham/cats-social/internal/entities/auditentity"
+	"github.com/danzBraham/cats-social/internal/errors/usererror"
+	"github.com/danzBraham/cats-social/internal/repositories"
+	"github.com/oklog/ulid/v2"
+)
+
+// LoginPolicy describes how failed logins for one key are throttled. The
+// first FreeAttempts failures are not delayed, every following failure
+// doubles the delay starting at BaseDelay (capped at MaxDelay), and reaching
+// MaxAttempts locks the key for LockoutDuration. Counters reset once no
+// failure happened for Window.
+type LoginPolicy struct {
+	FreeAttempts    int
+	MaxAttempts     int
+	BaseDelay       time.Duration
+	MaxDelay        time.Duration
+	LockoutDuration time.Duration
+	Window          time.Duration
+}
+
+var (
+	AccountLoginPolicy = LoginPolicy{
+		FreeAttempts:    3,
+		MaxAttempts:     10,
+		BaseDelay:       time.Second,
+		MaxDelay:        time.Minute,
+		LockoutDuration: 15 * time.Minute,
+		Window:          15 * time.Minute,
+	}
+	IpLoginPolicy = LoginPolicy{
+		FreeAttempts:    20,
+		MaxAttempts:     100,
+		BaseDelay:       time.Second,
+		MaxDelay:        time.Minute,
+		LockoutDuration: 15 * time.Minute,
+		Window:          15 * time.Minute,
+	}
+)
+
+func (p LoginPolicy) lockDuration(failures int) time.Duration {
+	if failures >= p.MaxAttempts {
+		return p.LockoutDuration
+	}
+	if failures <= p.FreeAttempts {
+		return 0
+	}
+	delay := p.BaseDelay << (failures - p.FreeAttempts - 1)
+	if delay > p.MaxDelay || delay <= 0 {
+		return p.MaxDelay
+	}
+	return delay
+}
+
+type LoginGuardService interface {
+	CheckLogin(ctx context.Context, email, ipAddress string) error
+	RecordFailedLogin(ctx context.Context, userId, email, ipAddress string) error
+	RecordSuccessfulLogin(ctx context.Context, email string) error
+}
+
+type LoginGuardServiceImpl struct {
+	LoginAttemptRepository repositories.LoginAttemptRepository
+	AuditRepository        repositories.AuditRepository
+}
+
+func NewLoginGuardService(
+	loginAttemptRepository repositories.LoginAttemptRepository,
+	auditRepository repositories.AuditRepository,
+) LoginGuardService {
+	return &LoginGuardServiceImpl{
+		LoginAttemptRepository: loginAttemptRepository,
+		AuditRepository:        auditRepository,
+	}
+}
+
+func (s *LoginGuardServiceImpl) CheckLogin(ctx context.Context, email, ipAddress string) error {
+	for _, key := range []string{accountLoginKey(email), ipLoginKey(ipAddress)} {
+		attempt, err := s.LoginAttemptRepository.GetLoginAttempt(ctx, key)
+		if err != nil {
+			return err
+		}
+		if attempt.LockedFor > 0 {
+			return &usererror.LoginLockedError{RetryAfter: attempt.LockedFor}
+		}
+	}
+	return nil
+}
+
+func (s *LoginGuardServiceImpl) RecordFailedLogin(ctx context.Context, userId, email, ipAddress string) error {
+	keys := []struct {
+		key    string
+		policy LoginPolicy
+	}{
+		{accountLoginKey(email), AccountLoginPolicy},
+		{ipLoginKey(ipAddress), IpLoginPolicy},
+	}
+
+	for _, k := range keys {
+		attempt, err := s.LoginAttemptRepository.RecordFailedLoginAttempt(ctx, k.key, k.policy.Window)
+		if err != nil {
+			return err
+		}
+
+		lockDuration := k.policy.lockDuration(attempt.Failures)
+		if lockDuration == 0 {
+			continue
+		}
+
+		err = s.LoginAttemptRepository.LockLoginAttempt(ctx, k.key, lockDuration)
+		if err != nil {
+			return err
+		}
+
+		if attempt.Failures == k.policy.MaxAttempts {
+			auditLog := &auditentity.AuditLog{
+				Id:        ulid.Make().String(),
+				Action:    auditentity.LoginLockout,
+				Subject:   k.key,
+				IpAddress: ipAddress,
+			}
+			if strings.HasPrefix(k.key, "email:") {
+				auditLog.UserId = userId
+			}
+			if err := s.AuditRepository.CreateAuditLog(ctx, auditLog); err != nil {
+				log.Printf("failed to write audit log for %s: %v", k.key, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *LoginGuardServiceImpl) RecordSuccessfulLogin(ctx context.Context, email string) error {
+	// only the account counter is cleared; the per-IP counter keeps
+	// protecting other accounts tried from the same client
+	return s.LoginAttemptRepository.ResetLoginAttempt(ctx, accountLoginKey(email))
+}
+
+func accountLoginKey(email string) string {
+	return "email:" + strings.ToLower(email)
+}
+
+func ipLoginKey(ipAddress string) string {
+	return "ip:" + ipAddress
+}
@@ -2,40 +2,66 @@ package services
 
 import (
 	"context"
+	"log"
+	"os"
+	"strconv"
+	"time"
 
+	"github.com/danzBraham/cats-social/internal/entities/evententity"
 	"github.com/danzBraham/cats-social/internal/entities/matchentity"
+	"github.com/danzBraham/cats-social/internal/entities/notificationentity"
 	"github.com/danzBraham/cats-social/internal/errors/matcherror"
+	"github.com/danzBraham/cats-social/internal/errors/usererror"
+	"github.com/danzBraham/cats-social/internal/helpers/cursor"
 	"github.com/danzBraham/cats-social/internal/repositories"
 	"github.com/oklog/ulid/v2"
 )
 
 type MatchService interface {
 	CreateMatch(ctx context.Context, userId string, payload *matchentity.CreateMatchRequest) error
-	GetMatches(ctx context.Context, userId string) ([]*matchentity.GetMatchResponse, error)
+	GetMatches(ctx context.Context, userId string, params *matchentity.MatchQueryParams) (*matchentity.MatchPage, error)
+	GetMatchById(ctx context.Context, userId, matchId string) (*matchentity.GetMatchResponse, error)
 	ApproveMatch(ctx context.Context, userId string, payload *matchentity.ApproveMatchRequest) error
 	RejectMatch(ctx context.Context, userId string, payload *matchentity.RejectMatchRequest) error
+	UnmatchMatch(ctx context.Context, userId string, payload *matchentity.UnmatchRequest) error
 	DeleteMatch(ctx context.Context, userId, matchId string) error
+	ExpireMatches(ctx context.Context) (int64, error)
 }
 
+const DefaultMatchRequestTTL = 7 * 24 * time.Hour
+
 type MatchServiceImpl struct {
-	MatchRepository repositories.MatchRepository
-	CatRepository   repositories.CatRepository
-	UserRepository  repositories.UserRepository
+	MatchRepository     repositories.MatchRepository
+	CatRepository       repositories.CatRepository
+	UserRepository      repositories.UserRepository
+	NotificationService NotificationService
 }
 
 func NewMatchService(
 	matchRepository repositories.MatchRepository,
 	catRepository repositories.CatRepository,
 	userRepository repositories.UserRepository,
+	notificationService NotificationService,
 ) MatchService {
 	return &MatchServiceImpl{
-		MatchRepository: matchRepository,
-		CatRepository:   catRepository,
-		UserRepository:  userRepository,
+		MatchRepository:     matchRepository,
+		CatRepository:       catRepository,
+		UserRepository:      userRepository,
+		NotificationService: notificationService,
 	}
 }
 
 func (s *MatchServiceImpl) CreateMatch(ctx context.Context, userId string, payload *matchentity.CreateMatchRequest) error {
+	if requireEmailVerification() {
+		user, err := s.UserRepository.GetUserById(ctx, userId)
+		if err != nil {
+			return err
+		}
+		if user.EmailVerifiedAt == "" {
+			return usererror.ErrEmailNotVerified
+		}
+	}
+
 	isMatchCatIdExists, err := s.CatRepository.IsCatIdExists(ctx, payload.MatchCatId)
 	if err != nil {
 		return err
@@ -99,16 +125,54 @@ func (s *MatchServiceImpl) CreateMatch(ctx context.Context, userId string, paylo
 		Message:    payload.Message,
 	}
 
-	err = s.MatchRepository.CreateMatch(ctx, matchCat)
+	matchCatDetail, err := s.CatRepository.GetCatById(ctx, matchCat.MatchCatId)
+	if err != nil {
+		return err
+	}
+
+	event, err := newMatchEvent(evententity.MatchRequested, &matchentity.MatchParties{
+		Id:         matchCat.Id,
+		MatchCatId: matchCat.MatchCatId,
+		UserCatId:  matchCat.UserCatId,
+		IssuerId:   userId,
+		ReceiverId: matchCatDetail.OwnerId,
+	}, matchentity.Pending, userId)
 	if err != nil {
 		return err
 	}
 
+	err = s.MatchRepository.CreateMatch(ctx, matchCat, event)
+	if err != nil {
+		return err
+	}
+
+	notifyOtherOwner(ctx, s.MatchRepository, s.NotificationService, userId, matchCat.Id, notificationentity.MatchRequested, &notificationentity.MatchData{
+		MatchId:    matchCat.Id,
+		MatchCatId: matchCat.MatchCatId,
+		UserCatId:  matchCat.UserCatId,
+	})
 	return nil
 }
 
-func (s *MatchServiceImpl) GetMatches(ctx context.Context, userId string) ([]*matchentity.GetMatchResponse, error) {
-	return s.MatchRepository.GetMatches(ctx, userId)
+func (s *MatchServiceImpl) GetMatches(ctx context.Context, userId string, params *matchentity.MatchQueryParams) (*matchentity.MatchPage, error) {
+	matches, next, err := s.MatchRepository.GetMatches(ctx, userId, params)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &matchentity.MatchPage{
+		Matches: matches,
+		HasMore: next != nil,
+	}
+	if next != nil {
+		page.NextCursor = cursor.Encode(next.CreatedAt, next.Id)
+	}
+
+	return page, nil
+}
+
+func (s *MatchServiceImpl) GetMatchById(ctx context.Context, userId, matchId string) (*matchentity.GetMatchResponse, error) {
+	return s.MatchRepository.GetMatchById(ctx, userId, matchId)
 }
 
 func (s *MatchServiceImpl) ApproveMatch(ctx context.Context, userId string, payload *matchentity.ApproveMatchRequest) error {
@@ -120,6 +184,14 @@ func (s *MatchServiceImpl) ApproveMatch(ctx context.Context, userId string, payl
 		return matcherror.ErrMatchIdNotFound
 	}
 
+	isMatchExpired, err := s.MatchRepository.IsMatchExpired(ctx, payload.MatchId, matchRequestTTL())
+	if err != nil {
+		return err
+	}
+	if isMatchExpired {
+		return matcherror.ErrMatchRequestExpired
+	}
+
 	isMatchIdValid, err := s.MatchRepository.IsMatchIdValid(ctx, payload.MatchId)
 	if err != nil {
 		return err
@@ -136,14 +208,47 @@ func (s *MatchServiceImpl) ApproveMatch(ctx context.Context, userId string, payl
 		return matcherror.ErrIssuerCannotDecide
 	}
 
-	err = s.MatchRepository.ApproveMatch(ctx, payload.MatchId)
+	parties, err := s.MatchRepository.GetMatchParties(ctx, payload.MatchId)
+	if err != nil {
+		return err
+	}
+
+	event, err := newMatchEvent(evententity.MatchApproved, parties, matchentity.Approved, userId)
+	if err != nil {
+		return err
+	}
+
+	// the other pending requests of both cats go away with the approval,
+	// subscribers learn about them like about any withdrawn request
+	removed, err := s.MatchRepository.ApproveMatch(ctx, payload.MatchId, event, func(removed *matchentity.MatchParties) (*evententity.Event, error) {
+		return newMatchEvent(evententity.MatchDeleted, removed, removed.Status, userId)
+	})
 	if err != nil {
 		return err
 	}
 
+	notifyOtherOwner(ctx, s.MatchRepository, s.NotificationService, userId, payload.MatchId, notificationentity.MatchApproved, &notificationentity.MatchData{MatchId: payload.MatchId})
+	for _, parties := range removed {
+		s.notifyRemoved(ctx, userId, parties)
+	}
 	return nil
 }
 
+// notifyRemoved tells the owners of a request removed by an approval, other
+// than the user who approved, that it's gone. Notifications are best effort,
+// a failure is only logged.
+func (s *MatchServiceImpl) notifyRemoved(ctx context.Context, userId string, parties *matchentity.MatchParties) {
+	for _, ownerId := range []string{parties.IssuerId, parties.ReceiverId} {
+		if ownerId == userId {
+			continue
+		}
+		err := s.NotificationService.Notify(ctx, ownerId, notificationentity.MatchRemoved, &notificationentity.MatchData{MatchId: parties.Id})
+		if err != nil {
+			log.Printf("failed to notify %s of match %s: %v", notificationentity.MatchRemoved, parties.Id, err)
+		}
+	}
+}
+
 func (s *MatchServiceImpl) RejectMatch(ctx context.Context, userId string, payload *matchentity.RejectMatchRequest) error {
 	isMatchIdExists, err := s.MatchRepository.IsMatchIdExists(ctx, payload.MatchId)
 	if err != nil {
@@ -153,6 +258,14 @@ func (s *MatchServiceImpl) RejectMatch(ctx context.Context, userId string, paylo
 		return matcherror.ErrMatchIdNotFound
 	}
 
+	isMatchExpired, err := s.MatchRepository.IsMatchExpired(ctx, payload.MatchId, matchRequestTTL())
+	if err != nil {
+		return err
+	}
+	if isMatchExpired {
+		return matcherror.ErrMatchRequestExpired
+	}
+
 	isMatchIdValid, err := s.MatchRepository.IsMatchIdValid(ctx, payload.MatchId)
 	if err != nil {
 		return err
@@ -169,11 +282,66 @@ func (s *MatchServiceImpl) RejectMatch(ctx context.Context, userId string, paylo
 		return matcherror.ErrIssuerCannotDecide
 	}
 
-	err = s.MatchRepository.RejectMatch(ctx, payload.MatchId)
+	parties, err := s.MatchRepository.GetMatchParties(ctx, payload.MatchId)
+	if err != nil {
+		return err
+	}
+
+	event, err := newMatchEvent(evententity.MatchRejected, parties, matchentity.Rejected, userId)
+	if err != nil {
+		return err
+	}
+
+	err = s.MatchRepository.RejectMatch(ctx, payload.MatchId, event)
+	if err != nil {
+		return err
+	}
+
+	notifyOtherOwner(ctx, s.MatchRepository, s.NotificationService, userId, payload.MatchId, notificationentity.MatchRejected, &notificationentity.MatchData{MatchId: payload.MatchId})
+	return nil
+}
+
+func (s *MatchServiceImpl) UnmatchMatch(ctx context.Context, userId string, payload *matchentity.UnmatchRequest) error {
+	isMatchIdExists, err := s.MatchRepository.IsMatchIdExists(ctx, payload.MatchId)
+	if err != nil {
+		return err
+	}
+	if !isMatchIdExists {
+		return matcherror.ErrMatchIdNotFound
+	}
+
+	isMatchParticipant, err := s.MatchRepository.IsMatchParticipant(ctx, payload.MatchId, userId)
+	if err != nil {
+		return err
+	}
+	if !isMatchParticipant {
+		return matcherror.ErrNotMatchParticipant
+	}
+
+	isMatchApproved, err := s.MatchRepository.IsMatchApproved(ctx, payload.MatchId)
+	if err != nil {
+		return err
+	}
+	if !isMatchApproved {
+		return matcherror.ErrMatchIsNotApproved
+	}
+
+	parties, err := s.MatchRepository.GetMatchParties(ctx, payload.MatchId)
+	if err != nil {
+		return err
+	}
+
+	event, err := newMatchEvent(evententity.MatchUnmatched, parties, matchentity.Unmatched, userId)
+	if err != nil {
+		return err
+	}
+
+	err = s.MatchRepository.UnmatchMatch(ctx, payload.MatchId, userId, event)
 	if err != nil {
 		return err
 	}
 
+	notifyOtherOwner(ctx, s.MatchRepository, s.NotificationService, userId, payload.MatchId, notificationentity.MatchUnmatched, &notificationentity.MatchData{MatchId: payload.MatchId})
 	return nil
 }
 
@@ -202,10 +370,65 @@ func (s *MatchServiceImpl) DeleteMatch(ctx context.Context, userId, matchId stri
 		return matcherror.ErrNotIssuer
 	}
 
-	err = s.MatchRepository.DeleteMatch(ctx, matchId)
+	parties, err := s.MatchRepository.GetMatchParties(ctx, matchId)
 	if err != nil {
 		return err
 	}
 
+	event, err := newMatchEvent(evententity.MatchDeleted, parties, parties.Status, userId)
+	if err != nil {
+		return err
+	}
+
+	err = s.MatchRepository.DeleteMatch(ctx, matchId, event)
+	if err != nil {
+		return err
+	}
+
+	notifyOtherOwner(ctx, s.MatchRepository, s.NotificationService, userId, matchId, notificationentity.MatchWithdrawn, &notificationentity.MatchData{MatchId: matchId})
 	return nil
 }
+
+// ExpireMatches is run by the expiry scheduler, it expires the pending match
+// requests older than MATCH_REQUEST_TTL.
+func (s *MatchServiceImpl) ExpireMatches(ctx context.Context) (int64, error) {
+	staleMatches, err := s.MatchRepository.GetStaleMatches(ctx, matchRequestTTL())
+	if err != nil {
+		return 0, err
+	}
+
+	var expired int64
+	for _, parties := range staleMatches {
+		event, err := newMatchEvent(evententity.MatchExpired, parties, matchentity.Expired, "")
+		if err != nil {
+			return expired, err
+		}
+
+		// a request decided since it was read is left alone
+		isExpired, err := s.MatchRepository.ExpireMatch(ctx, parties.Id, event)
+		if err != nil {
+			return expired, err
+		}
+		if isExpired {
+			expired++
+		}
+	}
+	return expired, nil
+}
+
+// matchRequestTTL reads MATCH_REQUEST_TTL as a duration such as "72h", a
+// value that is missing or not positive keeps the default of a week.
+func matchRequestTTL() time.Duration {
+	ttl, err := time.ParseDuration(os.Getenv("MATCH_REQUEST_TTL"))
+	if err != nil || ttl <= 0 {
+		return DefaultMatchRequestTTL
+	}
+	return ttl
+}
+
+// requireEmailVerification reports whether only users with a verified email
+// may send match requests, as configured by REQUIRE_EMAIL_VERIFICATION.
+func requireEmailVerification() bool {
+	required, _ := strconv.ParseBool(os.Getenv("REQUIRE_EMAIL_VERIFICATION"))
+	return required
+}
@@ -0,0 +1,120 @@
+package services
+
+import (
+	"context"
+	"strings"
+
+	"github.com/danzBraham/cats-social/internal/entities/messageentity"
+	"github.com/danzBraham/cats-social/internal/entities/notificationentity"
+	"github.com/danzBraham/cats-social/internal/errors/matcherror"
+	"github.com/danzBraham/cats-social/internal/errors/messageerror"
+	"github.com/danzBraham/cats-social/internal/helpers/cursor"
+	"github.com/danzBraham/cats-social/internal/repositories"
+	"github.com/oklog/ulid/v2"
+)
+
+type MessageService interface {
+	CreateMessage(ctx context.Context, userId, matchId string, payload *messageentity.CreateMessageRequest) (*messageentity.GetMessageResponse, error)
+	GetMessages(ctx context.Context, userId, matchId string, params *messageentity.MessageQueryParams) (*messageentity.MessagePage, error)
+}
+
+type MessageServiceImpl struct {
+	MessageRepository   repositories.MessageRepository
+	MatchRepository     repositories.MatchRepository
+	NotificationService NotificationService
+}
+
+func NewMessageService(
+	messageRepository repositories.MessageRepository,
+	matchRepository repositories.MatchRepository,
+	notificationService NotificationService,
+) MessageService {
+	return &MessageServiceImpl{
+		MessageRepository:   messageRepository,
+		MatchRepository:     matchRepository,
+		NotificationService: notificationService,
+	}
+}
+
+func (s *MessageServiceImpl) CreateMessage(ctx context.Context, userId, matchId string, payload *messageentity.CreateMessageRequest) (*messageentity.GetMessageResponse, error) {
+	err := s.ensureMatchParticipant(ctx, userId, matchId)
+	if err != nil {
+		return nil, err
+	}
+
+	isMatchThreadWritable, err := s.MatchRepository.IsMatchThreadWritable(ctx, matchId)
+	if err != nil {
+		return nil, err
+	}
+	if !isMatchThreadWritable {
+		return nil, messageerror.ErrThreadIsReadOnly
+	}
+
+	body := strings.TrimSpace(payload.Body)
+	if body == "" {
+		return nil, messageerror.ErrMessageIsEmpty
+	}
+
+	message := &messageentity.Message{
+		Id:       ulid.Make().String(),
+		MatchId:  matchId,
+		SenderId: userId,
+		Body:     body,
+	}
+
+	messageResponse, err := s.MessageRepository.CreateMessage(ctx, message)
+	if err != nil {
+		return nil, err
+	}
+
+	notifyOtherOwner(ctx, s.MatchRepository, s.NotificationService, userId, matchId, notificationentity.MessageReceived, &notificationentity.MessageData{
+		MatchId:   matchId,
+		MessageId: message.Id,
+	})
+	return messageResponse, nil
+}
+
+// GetMessages also marks the messages of the other owner as read when
+// params.MarkAsRead is set, opening the thread is what the read receipts are
+// based on.
+func (s *MessageServiceImpl) GetMessages(ctx context.Context, userId, matchId string, params *messageentity.MessageQueryParams) (*messageentity.MessagePage, error) {
+	err := s.ensureMatchParticipant(ctx, userId, matchId)
+	if err != nil {
+		return nil, err
+	}
+
+	if params.MarkAsRead {
+		err = s.MessageRepository.MarkMessagesAsRead(ctx, userId, matchId)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	messages, next, err := s.MessageRepository.GetMessages(ctx, userId, matchId, params)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &messageentity.MessagePage{
+		Messages: messages,
+		HasMore:  next != nil,
+	}
+	if next != nil {
+		page.NextCursor = cursor.Encode(next.CreatedAt, next.Id)
+	}
+
+	return page, nil
+}
+
+// ensureMatchParticipant hides the threads the user isn't part of as if the
+// match request didn't exist. Threads of deleted requests stay readable.
+func (s *MessageServiceImpl) ensureMatchParticipant(ctx context.Context, userId, matchId string) error {
+	isMatchParticipant, err := s.MatchRepository.IsMatchParticipant(ctx, matchId, userId)
+	if err != nil {
+		return err
+	}
+	if !isMatchParticipant {
+		return matcherror.ErrMatchIdNotFound
+	}
+	return nil
+}
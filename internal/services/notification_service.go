@@ -0,0 +1,204 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/danzBraham/cats-social/internal/entities/notificationentity"
+	"github.com/danzBraham/cats-social/internal/errors/autherror"
+	"github.com/danzBraham/cats-social/internal/errors/usererror"
+	"github.com/danzBraham/cats-social/internal/helpers/cursor"
+	"github.com/danzBraham/cats-social/internal/helpers/randtoken"
+	"github.com/danzBraham/cats-social/internal/notifier"
+	"github.com/danzBraham/cats-social/internal/repositories"
+	"github.com/oklog/ulid/v2"
+)
+
+const (
+	// StreamTicketTTL is only long enough for the browser to open the
+	// stream right after asking for the ticket.
+	StreamTicketTTL = 30 * time.Second
+
+	// replayLimit caps how many missed notifications a reconnecting stream
+	// gets.
+	replayLimit = 100
+)
+
+type NotificationService interface {
+	Notify(ctx context.Context, userId string, notificationType notificationentity.Type, data interface{}) error
+	GetNotifications(ctx context.Context, userId string, params *notificationentity.NotificationQueryParams) (*notificationentity.NotificationPage, error)
+	GetNotificationsAfter(ctx context.Context, userId, notificationId string) ([]*notificationentity.GetNotificationResponse, error)
+	ReadNotification(ctx context.Context, userId, notificationId string) error
+	ReadAllNotifications(ctx context.Context, userId string) error
+	Subscribe(userId string) (<-chan *notificationentity.GetNotificationResponse, func())
+	CreateStreamTicket(ctx context.Context, userId, sessionId string) (*notificationentity.CreateStreamTicketResponse, error)
+	CheckStreamAccess(ctx context.Context, userId, sessionId, apiKeyId string) error
+}
+
+type NotificationServiceImpl struct {
+	NotificationRepository repositories.NotificationRepository
+	StreamTicketRepository repositories.StreamTicketRepository
+	SessionRepository      repositories.SessionRepository
+	UserRepository         repositories.UserRepository
+	ApiKeyRepository       repositories.ApiKeyRepository
+	Hub                    *notifier.Hub
+}
+
+func NewNotificationService(
+	notificationRepository repositories.NotificationRepository,
+	streamTicketRepository repositories.StreamTicketRepository,
+	sessionRepository repositories.SessionRepository,
+	userRepository repositories.UserRepository,
+	apiKeyRepository repositories.ApiKeyRepository,
+	hub *notifier.Hub,
+) NotificationService {
+	return &NotificationServiceImpl{
+		NotificationRepository: notificationRepository,
+		StreamTicketRepository: streamTicketRepository,
+		SessionRepository:      sessionRepository,
+		UserRepository:         userRepository,
+		ApiKeyRepository:       apiKeyRepository,
+		Hub:                    hub,
+	}
+}
+
+// Notify stores a notification in the inbox of the user, the replica
+// streaming to the user pushes it as soon as it's committed.
+func (s *NotificationServiceImpl) Notify(ctx context.Context, userId string, notificationType notificationentity.Type, data interface{}) error {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	notification := &notificationentity.Notification{
+		Id:     ulid.Make().String(),
+		UserId: userId,
+		Type:   notificationType,
+		Data:   encoded,
+	}
+
+	return s.NotificationRepository.CreateNotification(ctx, notification)
+}
+
+func (s *NotificationServiceImpl) GetNotifications(ctx context.Context, userId string, params *notificationentity.NotificationQueryParams) (*notificationentity.NotificationPage, error) {
+	notifications, next, err := s.NotificationRepository.GetNotifications(ctx, userId, params)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &notificationentity.NotificationPage{
+		Notifications: notifications,
+		HasMore:       next != nil,
+	}
+	if next != nil {
+		page.NextCursor = cursor.Encode(next.CreatedAt, next.Id)
+	}
+
+	return page, nil
+}
+
+func (s *NotificationServiceImpl) GetNotificationsAfter(ctx context.Context, userId, notificationId string) ([]*notificationentity.GetNotificationResponse, error) {
+	return s.NotificationRepository.GetNotificationsAfter(ctx, userId, notificationId, replayLimit)
+}
+
+func (s *NotificationServiceImpl) ReadNotification(ctx context.Context, userId, notificationId string) error {
+	return s.NotificationRepository.MarkNotificationAsRead(ctx, userId, notificationId)
+}
+
+func (s *NotificationServiceImpl) ReadAllNotifications(ctx context.Context, userId string) error {
+	return s.NotificationRepository.MarkAllNotificationsAsRead(ctx, userId)
+}
+
+func (s *NotificationServiceImpl) Subscribe(userId string) (<-chan *notificationentity.GetNotificationResponse, func()) {
+	return s.Hub.Subscribe(userId)
+}
+
+// CreateStreamTicket issues a single use ticket a browser can put in the
+// query string of the stream endpoints, which it can't send headers to.
+func (s *NotificationServiceImpl) CreateStreamTicket(ctx context.Context, userId, sessionId string) (*notificationentity.CreateStreamTicketResponse, error) {
+	ticket, err := randtoken.Generate(32)
+	if err != nil {
+		return nil, err
+	}
+
+	streamTicket := &notificationentity.StreamTicket{
+		Id:        ulid.Make().String(),
+		UserId:    userId,
+		SessionId: sessionId,
+		TokenHash: randtoken.Hash(ticket),
+	}
+	expiresAt, err := s.StreamTicketRepository.CreateStreamTicket(ctx, streamTicket, StreamTicketTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	return &notificationentity.CreateStreamTicketResponse{
+		Ticket:    ticket,
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+// CheckStreamAccess tells whether an open stream may go on. Streams outlive
+// the request that authenticated them, so they're checked again now and then
+// to be closed after a logout, a revoked API key or a suspension. The stream
+// was opened either with a session or with an API key, the other id is empty.
+func (s *NotificationServiceImpl) CheckStreamAccess(ctx context.Context, userId, sessionId, apiKeyId string) error {
+	if sessionId != "" {
+		isSessionActive, err := s.SessionRepository.IsSessionActive(ctx, sessionId)
+		if err != nil {
+			return err
+		}
+		if !isSessionActive {
+			return autherror.ErrSessionRevoked
+		}
+	}
+
+	if apiKeyId != "" {
+		isApiKeyActive, err := s.ApiKeyRepository.IsApiKeyOwner(ctx, apiKeyId, userId)
+		if err != nil {
+			return err
+		}
+		if !isApiKeyActive {
+			return autherror.ErrInvalidApiKey
+		}
+	}
+
+	access, err := s.UserRepository.GetUserAccess(ctx, userId)
+	if err != nil {
+		return err
+	}
+	if access.IsSuspended {
+		return usererror.ErrUserSuspended
+	}
+
+	return nil
+}
+
+// notifyOtherOwner tells the owner of the other cat of a match request about
+// what the user did. Notifications are best effort, a failure is only logged.
+func notifyOtherOwner(
+	ctx context.Context,
+	matchRepository repositories.MatchRepository,
+	notificationService NotificationService,
+	userId, matchId string,
+	notificationType notificationentity.Type,
+	data interface{},
+) {
+	parties, err := matchRepository.GetMatchParties(ctx, matchId)
+	if err != nil {
+		log.Printf("failed to notify %s of match %s: %v", notificationType, matchId, err)
+		return
+	}
+
+	recipientId := parties.IssuerId
+	if userId == parties.IssuerId {
+		recipientId = parties.ReceiverId
+	}
+
+	err = notificationService.Notify(ctx, recipientId, notificationType, data)
+	if err != nil {
+		log.Printf("failed to notify %s of match %s: %v", notificationType, matchId, err)
+	}
+}
@@ -0,0 +1,114 @@
+package services
+
+import (
+	"os"
+
+	"github.com/danzBraham/cats-social/internal/blobstore"
+	"github.com/danzBraham/cats-social/internal/mailer"
+	"github.com/danzBraham/cats-social/internal/notifier"
+	"github.com/danzBraham/cats-social/internal/repositories"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Services is the service graph of the application. It is built once and
+// shared by the http server and the background workers, so state kept by a
+// service, such as the in-memory login attempts, is the same for both.
+type Services struct {
+	Image        ImageService
+	LoginGuard   LoginGuardService
+	TwoFactor    TwoFactorService
+	Notification NotificationService
+	Webhook      WebhookService
+	User         UserService
+	Cat          CatService
+	Match        MatchService
+	Admin        AdminService
+	ApiKey       ApiKeyService
+	Message      MessageService
+	EventBus     EventBus
+}
+
+func NewServices(
+	db *pgxpool.Pool,
+	mailer mailer.Mailer,
+	blobStore blobstore.BlobStore,
+	hub *notifier.Hub,
+) *Services {
+	// repositories
+	userRepository := repositories.NewUserRepository(db)
+	catRepository := repositories.NewCatRepository(db)
+	catImageRepository := repositories.NewCatImageRepository(db)
+	matchRepository := repositories.NewMatchRepository(db)
+	sessionRepository := repositories.NewSessionRepository(db)
+	passwordResetRepository := repositories.NewPasswordResetRepository(db)
+	loginChallengeRepository := repositories.NewLoginChallengeRepository(db)
+	auditRepository := repositories.NewAuditRepository(db)
+	recoveryCodeRepository := repositories.NewRecoveryCodeRepository(db)
+	imageVariantRepository := repositories.NewImageVariantRepository(db)
+	apiKeyRepository := repositories.NewApiKeyRepository(db)
+	messageRepository := repositories.NewMessageRepository(db)
+	notificationRepository := repositories.NewNotificationRepository(db)
+	streamTicketRepository := repositories.NewStreamTicketRepository(db)
+	webhookRepository := repositories.NewWebhookRepository(db)
+	outboxRepository := repositories.NewOutboxRepository(db)
+	var loginAttemptRepository repositories.LoginAttemptRepository
+	if os.Getenv("LOGIN_ATTEMPT_STORE") == "memory" {
+		loginAttemptRepository = repositories.NewMemoryLoginAttemptRepository()
+	} else {
+		loginAttemptRepository = repositories.NewLoginAttemptRepository(db)
+	}
+
+	// services
+	imageService := NewImageService(imageVariantRepository, blobStore)
+	loginGuardService := NewLoginGuardService(loginAttemptRepository, auditRepository)
+	twoFactorService := NewTwoFactorService(userRepository, recoveryCodeRepository)
+	notificationService := NewNotificationService(
+		notificationRepository,
+		streamTicketRepository,
+		sessionRepository,
+		userRepository,
+		apiKeyRepository,
+		hub,
+	)
+
+	return &Services{
+		Image:        imageService,
+		LoginGuard:   loginGuardService,
+		TwoFactor:    twoFactorService,
+		Notification: notificationService,
+		Webhook:      NewWebhookService(webhookRepository),
+		User: NewUserService(
+			userRepository,
+			sessionRepository,
+			passwordResetRepository,
+			loginChallengeRepository,
+			loginGuardService,
+			twoFactorService,
+			mailer,
+			imageService,
+		),
+		Cat: NewCatService(
+			catRepository,
+			catImageRepository,
+			matchRepository,
+			userRepository,
+			imageService,
+		),
+		Match: NewMatchService(
+			matchRepository,
+			catRepository,
+			userRepository,
+			notificationService,
+		),
+		Admin: NewAdminService(
+			userRepository,
+			catRepository,
+			matchRepository,
+			auditRepository,
+			imageService,
+		),
+		ApiKey:   NewApiKeyService(apiKeyRepository),
+		Message:  NewMessageService(messageRepository, matchRepository, notificationService),
+		EventBus: NewEventBus(outboxRepository),
+	}
+}
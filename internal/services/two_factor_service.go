@@ -0,0 +1,179 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"strings"
+	"time"
+
+	"github.com/danzBraham/cats-social/internal/entities/userentity"
+	"github.com/danzBraham/cats-social/internal/errors/usererror"
+	"github.com/danzBraham/cats-social/internal/helpers/bcrypt"
+	"github.com/danzBraham/cats-social/internal/helpers/randtoken"
+	"github.com/danzBraham/cats-social/internal/helpers/totp"
+	"github.com/danzBraham/cats-social/internal/repositories"
+)
+
+const (
+	TOTPIssuer        = "Cats Social"
+	RecoveryCodeCount = 10
+)
+
+type TwoFactorService interface {
+	SetupTwoFactor(ctx context.Context, userId string) (*userentity.SetupTwoFactorResponse, error)
+	EnableTwoFactor(ctx context.Context, userId string, payload *userentity.EnableTwoFactorRequest) (*userentity.EnableTwoFactorResponse, error)
+	DisableTwoFactor(ctx context.Context, userId string, payload *userentity.DisableTwoFactorRequest) error
+	VerifyCode(ctx context.Context, user *userentity.User, code string) error
+}
+
+type TwoFactorServiceImpl struct {
+	UserRepository         repositories.UserRepository
+	RecoveryCodeRepository repositories.RecoveryCodeRepository
+}
+
+func NewTwoFactorService(
+	userRepository repositories.UserRepository,
+	recoveryCodeRepository repositories.RecoveryCodeRepository,
+) TwoFactorService {
+	return &TwoFactorServiceImpl{
+		UserRepository:         userRepository,
+		RecoveryCodeRepository: recoveryCodeRepository,
+	}
+}
+
+func (s *TwoFactorServiceImpl) SetupTwoFactor(ctx context.Context, userId string) (*userentity.SetupTwoFactorResponse, error) {
+	user, err := s.UserRepository.GetUserById(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	if user.TOTPEnabledAt != "" {
+		return nil, usererror.ErrTwoFactorAlreadyEnabled
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.UserRepository.SetTOTPSecret(ctx, userId, secret)
+	if err != nil {
+		return nil, err
+	}
+
+	return &userentity.SetupTwoFactorResponse{
+		Secret:          secret,
+		ProvisioningUri: totp.ProvisioningURI(TOTPIssuer, user.Email, secret),
+	}, nil
+}
+
+func (s *TwoFactorServiceImpl) EnableTwoFactor(ctx context.Context, userId string, payload *userentity.EnableTwoFactorRequest) (*userentity.EnableTwoFactorResponse, error) {
+	user, err := s.UserRepository.GetUserById(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	if user.TOTPEnabledAt != "" {
+		return nil, usererror.ErrTwoFactorAlreadyEnabled
+	}
+	if user.TOTPSecret == "" {
+		return nil, usererror.ErrTwoFactorNotSetUp
+	}
+
+	step, ok := totp.Validate(user.TOTPSecret, payload.Code, time.Now())
+	if !ok {
+		return nil, usererror.ErrInvalidTwoFactorCode
+	}
+
+	recoveryCodes, codeHashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.UserRepository.EnableTOTP(ctx, userId, step, codeHashes)
+	if err != nil {
+		return nil, err
+	}
+
+	return &userentity.EnableTwoFactorResponse{
+		RecoveryCodes: recoveryCodes,
+	}, nil
+}
+
+func (s *TwoFactorServiceImpl) DisableTwoFactor(ctx context.Context, userId string, payload *userentity.DisableTwoFactorRequest) error {
+	user, err := s.UserRepository.GetUserById(ctx, userId)
+	if err != nil {
+		return err
+	}
+	if user.TOTPEnabledAt == "" {
+		return usererror.ErrTwoFactorNotEnabled
+	}
+
+	err = bcrypt.VerifyPassword(user.Password, payload.Password)
+	if err != nil {
+		return usererror.ErrInvalidPassword
+	}
+
+	err = s.VerifyCode(ctx, user, payload.Code)
+	if err != nil {
+		return err
+	}
+
+	return s.UserRepository.DisableTOTP(ctx, userId)
+}
+
+// VerifyCode accepts either a current TOTP code or one of the user's unused
+// recovery codes. Both can only be used once.
+func (s *TwoFactorServiceImpl) VerifyCode(ctx context.Context, user *userentity.User, code string) error {
+	if user.TOTPEnabledAt == "" {
+		return usererror.ErrTwoFactorNotEnabled
+	}
+
+	if len(code) == totp.Digits {
+		step, ok := totp.Validate(user.TOTPSecret, code, time.Now())
+		if !ok {
+			return usererror.ErrInvalidTwoFactorCode
+		}
+		isUnused, err := s.UserRepository.UseTOTPStep(ctx, user.Id, step)
+		if err != nil {
+			return err
+		}
+		if !isUnused {
+			return usererror.ErrInvalidTwoFactorCode
+		}
+		return nil
+	}
+
+	isConsumed, err := s.RecoveryCodeRepository.ConsumeRecoveryCode(ctx, user.Id, randtoken.Hash(normalizeRecoveryCode(code)))
+	if err != nil {
+		return err
+	}
+	if !isConsumed {
+		return usererror.ErrInvalidTwoFactorCode
+	}
+	return nil
+}
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// generateRecoveryCodes returns the codes shown to the user, formatted as
+// xxxx-xxxx, together with the hashes that get stored.
+func generateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, RecoveryCodeCount)
+	hashes := make([]string, 0, RecoveryCodeCount)
+	for i := 0; i < RecoveryCodeCount; i++ {
+		b := make([]byte, 5)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		code := strings.ToLower(recoveryCodeEncoding.EncodeToString(b))
+		codes = append(codes, code[:4]+"-"+code[4:])
+		hashes = append(hashes, randtoken.Hash(code))
+	}
+	return codes, hashes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	code = strings.ReplaceAll(code, "-", "")
+	return strings.ReplaceAll(code, " ", "")
+}
@@ -2,27 +2,85 @@ package services
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/danzBraham/cats-social/internal/entities/evententity"
+	"github.com/danzBraham/cats-social/internal/entities/sessionentity"
 	"github.com/danzBraham/cats-social/internal/entities/userentity"
 	"github.com/danzBraham/cats-social/internal/errors/usererror"
 	"github.com/danzBraham/cats-social/internal/helpers/bcrypt"
 	"github.com/danzBraham/cats-social/internal/helpers/jwt"
+	"github.com/danzBraham/cats-social/internal/helpers/randtoken"
+	"github.com/danzBraham/cats-social/internal/helpers/signedtoken"
+	"github.com/danzBraham/cats-social/internal/mailer"
 	"github.com/danzBraham/cats-social/internal/repositories"
 	"github.com/oklog/ulid/v2"
 )
 
+const (
+	AccessTokenTTL  = 8 * time.Hour
+	RefreshTokenTTL = 30 * 24 * time.Hour
+	ResetTokenTTL   = time.Hour
+	VerifyTokenTTL  = 24 * time.Hour
+	ChallengeTTL    = 5 * time.Minute
+
+	emailVerificationPurpose = "email-verification"
+)
+
 type UserService interface {
 	RegisterUser(ctx context.Context, payload *userentity.RegisterUserRequest) (*userentity.RegisterUserResponse, error)
-	LoginUser(ctx context.Context, payload *userentity.LoginUserRequest) (*userentity.LoginUserResponse, error)
+	LoginUser(ctx context.Context, payload *userentity.LoginUserRequest, ipAddress string) (*userentity.LoginUserResponse, error)
+	LoginTwoFactor(ctx context.Context, payload *userentity.LoginTwoFactorRequest, ipAddress string) (*userentity.LoginUserResponse, error)
+	RefreshToken(ctx context.Context, payload *sessionentity.RefreshTokenRequest) (*sessionentity.RefreshTokenResponse, error)
+	LogoutUser(ctx context.Context, sessionId string) error
+	GetCurrentUser(ctx context.Context, userId string) (*userentity.GetUserResponse, error)
+	UpdateCurrentUser(ctx context.Context, userId string, payload *userentity.UpdateUserRequest) (*userentity.GetUserResponse, error)
+	DeleteCurrentUser(ctx context.Context, userId string) error
+	ChangePassword(ctx context.Context, userId, sessionId string, payload *userentity.ChangePasswordRequest) error
+	ForgotPassword(ctx context.Context, payload *userentity.ForgotPasswordRequest) error
+	ResetPassword(ctx context.Context, payload *userentity.ResetPasswordRequest) error
+	VerifyEmail(ctx context.Context, token string) error
+	ResendVerificationEmail(ctx context.Context, userId string) error
 }
 
 type UserServiceImpl struct {
-	UserRepository repositories.UserRepository
+	UserRepository           repositories.UserRepository
+	SessionRepository        repositories.SessionRepository
+	PasswordResetRepository  repositories.PasswordResetRepository
+	LoginChallengeRepository repositories.LoginChallengeRepository
+	LoginGuardService        LoginGuardService
+	TwoFactorService         TwoFactorService
+	Mailer                   mailer.Mailer
+	ImageService             ImageService
 }
 
-func NewUserService(userRepository repositories.UserRepository) UserService {
-	return &UserServiceImpl{UserRepository: userRepository}
+func NewUserService(
+	userRepository repositories.UserRepository,
+	sessionRepository repositories.SessionRepository,
+	passwordResetRepository repositories.PasswordResetRepository,
+	loginChallengeRepository repositories.LoginChallengeRepository,
+	loginGuardService LoginGuardService,
+	twoFactorService TwoFactorService,
+	mailer mailer.Mailer,
+	imageService ImageService,
+) UserService {
+	return &UserServiceImpl{
+		UserRepository:           userRepository,
+		SessionRepository:        sessionRepository,
+		PasswordResetRepository:  passwordResetRepository,
+		LoginChallengeRepository: loginChallengeRepository,
+		LoginGuardService:        loginGuardService,
+		TwoFactorService:         twoFactorService,
+		Mailer:                   mailer,
+		ImageService:             imageService,
+	}
 }
 
 func (s *UserServiceImpl) RegisterUser(ctx context.Context, payload *userentity.RegisterUserRequest) (*userentity.RegisterUserResponse, error) {
@@ -44,44 +102,479 @@ func (s *UserServiceImpl) RegisterUser(ctx context.Context, payload *userentity.
 		Name:     payload.Name,
 		Email:    payload.Email,
 		Password: hashedPassword,
+		Role:     userentity.RoleUser,
 	}
 
-	err = s.UserRepository.CreateUser(ctx, user)
+	event, err := newEvent(evententity.UserRegistered, user.Id, &evententity.UserPayload{
+		UserId: user.Id,
+		Name:   user.Name,
+		Email:  user.Email,
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	token, err := jwt.GenerateToken(8*time.Hour, user.Id)
+	err = s.UserRepository.CreateUser(ctx, user, event)
+	if err != nil {
+		return nil, err
+	}
+
+	// the account already exists at this point, so a mail failure must not fail the registration
+	if err := s.sendVerificationEmail(ctx, user); err != nil {
+		log.Printf("failed to send verification email to user %s: %v", user.Id, err)
+	}
+
+	tokens, err := s.createSession(ctx, user)
 	if err != nil {
 		return nil, err
 	}
 
 	return &userentity.RegisterUserResponse{
-		Name:        user.Name,
-		Email:       user.Email,
-		AccessToken: token,
+		Name:         user.Name,
+		Email:        user.Email,
+		AccessToken:  tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
 	}, nil
 }
 
-func (s *UserServiceImpl) LoginUser(ctx context.Context, payload *userentity.LoginUserRequest) (*userentity.LoginUserResponse, error) {
+func (s *UserServiceImpl) LoginUser(ctx context.Context, payload *userentity.LoginUserRequest, ipAddress string) (*userentity.LoginUserResponse, error) {
+	err := s.LoginGuardService.CheckLogin(ctx, payload.Email, ipAddress)
+	if err != nil {
+		return nil, err
+	}
+
 	user, err := s.UserRepository.GetUserByEmail(ctx, payload.Email)
+	if errors.Is(err, usererror.ErrUserNotFound) {
+		// spend the same time as a real password check so response timing
+		// doesn't reveal whether the email is registered
+		bcrypt.VerifyPassword(dummyPasswordHash(), payload.Password)
+		return nil, s.failLogin(ctx, "", payload.Email, ipAddress)
+	}
 	if err != nil {
 		return nil, err
 	}
 
 	err = bcrypt.VerifyPassword(user.Password, payload.Password)
 	if err != nil {
-		return nil, usererror.ErrInvalidPassword
+		return nil, s.failLogin(ctx, user.Id, payload.Email, ipAddress)
+	}
+
+	if user.SuspendedAt != "" {
+		return nil, usererror.ErrUserSuspended
 	}
 
-	token, err := jwt.GenerateToken(8*time.Hour, user.Id)
+	if user.TOTPEnabledAt != "" {
+		challengeToken, err := randtoken.Generate(32)
+		if err != nil {
+			return nil, err
+		}
+
+		loginChallenge := &userentity.LoginChallenge{
+			Id:        ulid.Make().String(),
+			UserId:    user.Id,
+			TokenHash: randtoken.Hash(challengeToken),
+		}
+		err = s.LoginChallengeRepository.CreateLoginChallenge(ctx, loginChallenge, ChallengeTTL)
+		if err != nil {
+			return nil, err
+		}
+
+		// the password is right, but no session is opened until the
+		// challenge is exchanged together with a valid code
+		return &userentity.LoginUserResponse{
+			Name:              user.Name,
+			Email:             user.Email,
+			TwoFactorRequired: true,
+			ChallengeToken:    challengeToken,
+		}, nil
+	}
+
+	err = s.LoginGuardService.RecordSuccessfulLogin(ctx, payload.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens, err := s.createSession(ctx, user)
 	if err != nil {
 		return nil, err
 	}
 
 	return &userentity.LoginUserResponse{
-		Name:        user.Name,
-		Email:       user.Email,
-		AccessToken: token,
+		Name:         user.Name,
+		Email:        user.Email,
+		AccessToken:  tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+	}, nil
+}
+
+func (s *UserServiceImpl) LoginTwoFactor(ctx context.Context, payload *userentity.LoginTwoFactorRequest, ipAddress string) (*userentity.LoginUserResponse, error) {
+	challengeHash := randtoken.Hash(payload.ChallengeToken)
+	userId, err := s.LoginChallengeRepository.GetLoginChallengeUserId(ctx, challengeHash)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.UserRepository.GetUserById(ctx, userId)
+	if errors.Is(err, usererror.ErrUserNotFound) {
+		return nil, usererror.ErrInvalidChallengeToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	if user.SuspendedAt != "" {
+		return nil, usererror.ErrUserSuspended
+	}
+
+	err = s.LoginGuardService.CheckLogin(ctx, user.Email, ipAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.TwoFactorService.VerifyCode(ctx, user, payload.Code)
+	if errors.Is(err, usererror.ErrInvalidTwoFactorCode) {
+		if err := s.LoginGuardService.RecordFailedLogin(ctx, user.Id, user.Email, ipAddress); err != nil {
+			return nil, err
+		}
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.LoginChallengeRepository.ConsumeLoginChallenge(ctx, challengeHash)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.LoginGuardService.RecordSuccessfulLogin(ctx, user.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens, err := s.createSession(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	return &userentity.LoginUserResponse{
+		Name:         user.Name,
+		Email:        user.Email,
+		AccessToken:  tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+	}, nil
+}
+
+func (s *UserServiceImpl) RefreshToken(ctx context.Context, payload *sessionentity.RefreshTokenRequest) (*sessionentity.RefreshTokenResponse, error) {
+	refreshToken, err := randtoken.Generate(32)
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := s.SessionRepository.RotateRefreshToken(ctx,
+		randtoken.Hash(payload.RefreshToken),
+		randtoken.Hash(refreshToken),
+		RefreshTokenTTL,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// the role may have changed since the session started, so read it again
+	user, err := s.UserRepository.GetUserById(ctx, session.UserId)
+	if err != nil {
+		return nil, err
+	}
+	if user.SuspendedAt != "" {
+		return nil, usererror.ErrUserSuspended
+	}
+
+	accessToken, err := jwt.GenerateToken(AccessTokenTTL, user.Id, session.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sessionentity.RefreshTokenResponse{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+	}, nil
+}
+
+func (s *UserServiceImpl) LogoutUser(ctx context.Context, sessionId string) error {
+	return s.SessionRepository.RevokeSession(ctx, sessionId)
+}
+
+func (s *UserServiceImpl) GetCurrentUser(ctx context.Context, userId string) (*userentity.GetUserResponse, error) {
+	user, err := s.UserRepository.GetUserById(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	return &userentity.GetUserResponse{
+		Id:               user.Id,
+		Name:             user.Name,
+		Email:            user.Email,
+		EmailVerified:    user.EmailVerifiedAt != "",
+		TwoFactorEnabled: user.TOTPEnabledAt != "",
+		Location:         user.Location,
+		CreatedAt:        user.CreatedAt,
+	}, nil
+}
+
+func (s *UserServiceImpl) UpdateCurrentUser(ctx context.Context, userId string, payload *userentity.UpdateUserRequest) (*userentity.GetUserResponse, error) {
+	user, err := s.UserRepository.GetUserById(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	isEmailChanged := payload.Email != nil && *payload.Email != user.Email
+	if isEmailChanged {
+		isEmailExists, err := s.UserRepository.IsEmailExists(ctx, *payload.Email)
+		if err != nil {
+			return nil, err
+		}
+		if isEmailExists {
+			return nil, usererror.ErrEmailAlreadyExists
+		}
+		user.Email = *payload.Email
+		user.EmailVerifiedAt = ""
+	}
+
+	if payload.Name != nil {
+		user.Name = *payload.Name
+	}
+
+	if payload.Location != nil {
+		user.Location = payload.Location
+	}
+	if payload.ClearLocation {
+		user.Location = nil
+	}
+
+	err = s.UserRepository.UpdateUserById(ctx, userId, user)
+	if err != nil {
+		return nil, err
+	}
+
+	if isEmailChanged {
+		if err := s.sendVerificationEmail(ctx, user); err != nil {
+			log.Printf("failed to send verification email to user %s: %v", user.Id, err)
+		}
+	}
+
+	return &userentity.GetUserResponse{
+		Id:               user.Id,
+		Name:             user.Name,
+		Email:            user.Email,
+		EmailVerified:    user.EmailVerifiedAt != "",
+		TwoFactorEnabled: user.TOTPEnabledAt != "",
+		Location:         user.Location,
+		CreatedAt:        user.CreatedAt,
 	}, nil
 }
+
+func (s *UserServiceImpl) DeleteCurrentUser(ctx context.Context, userId string) error {
+	_, err := s.UserRepository.GetUserById(ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	imageUrls, err := s.UserRepository.DeleteUserById(ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	for catId, catImageUrls := range imageUrls {
+		s.ImageService.RemoveCatImages(ctx, catId, catImageUrls)
+	}
+	return nil
+}
+
+func (s *UserServiceImpl) ChangePassword(ctx context.Context, userId, sessionId string, payload *userentity.ChangePasswordRequest) error {
+	user, err := s.UserRepository.GetUserById(ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	err = bcrypt.VerifyPassword(user.Password, payload.OldPassword)
+	if err != nil {
+		return usererror.ErrInvalidPassword
+	}
+
+	hashedPassword, err := bcrypt.HashPassword(payload.NewPassword)
+	if err != nil {
+		return err
+	}
+
+	err = s.UserRepository.UpdatePasswordById(ctx, userId, hashedPassword)
+	if err != nil {
+		return err
+	}
+
+	// keep the caller signed in, but sign out every other device
+	return s.SessionRepository.RevokeOtherSessions(ctx, userId, sessionId)
+}
+
+func (s *UserServiceImpl) ForgotPassword(ctx context.Context, payload *userentity.ForgotPasswordRequest) error {
+	user, err := s.UserRepository.GetUserByEmail(ctx, payload.Email)
+	if errors.Is(err, usererror.ErrUserNotFound) {
+		// don't reveal whether the email is registered
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// the response can't depend on what happens next either, a failure is
+	// only logged and the user can ask again
+	token, err := randtoken.Generate(32)
+	if err != nil {
+		log.Printf("failed to generate password reset token for user %s: %v", user.Id, err)
+		return nil
+	}
+
+	passwordReset := &userentity.PasswordReset{
+		Id:        ulid.Make().String(),
+		UserId:    user.Id,
+		TokenHash: randtoken.Hash(token),
+	}
+
+	err = s.PasswordResetRepository.CreatePasswordReset(ctx, passwordReset, ResetTokenTTL)
+	if err != nil {
+		log.Printf("failed to store password reset for user %s: %v", user.Id, err)
+		return nil
+	}
+
+	link := fmt.Sprintf("%s/reset-password?token=%s", appURL(), url.QueryEscape(token))
+
+	err = s.Mailer.Send(ctx, &mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your Cats Social password",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nUse the link below to reset your password. It expires in %s.\n\n%s\n\nIf you didn't ask for this, you can ignore this email.\n",
+			user.Name, ResetTokenTTL, link,
+		),
+	})
+	if err != nil {
+		log.Printf("failed to send password reset email to user %s: %v", user.Id, err)
+	}
+
+	return nil
+}
+
+func (s *UserServiceImpl) ResetPassword(ctx context.Context, payload *userentity.ResetPasswordRequest) error {
+	hashedPassword, err := bcrypt.HashPassword(payload.NewPassword)
+	if err != nil {
+		return err
+	}
+
+	return s.PasswordResetRepository.ResetPassword(ctx, randtoken.Hash(payload.Token), hashedPassword)
+}
+
+func (s *UserServiceImpl) VerifyEmail(ctx context.Context, token string) error {
+	subject, err := signedtoken.Verify(emailVerificationPurpose, token)
+	if err != nil {
+		return usererror.ErrInvalidVerificationToken
+	}
+
+	userId, email, ok := strings.Cut(subject, "|")
+	if !ok {
+		return usererror.ErrInvalidVerificationToken
+	}
+
+	isVerified, err := s.UserRepository.VerifyEmail(ctx, userId, email)
+	if err != nil {
+		return err
+	}
+	if !isVerified {
+		// the account is gone or the email has changed since the link was sent
+		return usererror.ErrInvalidVerificationToken
+	}
+
+	return nil
+}
+
+func (s *UserServiceImpl) ResendVerificationEmail(ctx context.Context, userId string) error {
+	user, err := s.UserRepository.GetUserById(ctx, userId)
+	if err != nil {
+		return err
+	}
+	if user.EmailVerifiedAt != "" {
+		return usererror.ErrEmailAlreadyVerified
+	}
+
+	return s.sendVerificationEmail(ctx, user)
+}
+
+func (s *UserServiceImpl) sendVerificationEmail(ctx context.Context, user *userentity.User) error {
+	token, err := signedtoken.Generate(emailVerificationPurpose, user.Id+"|"+user.Email, VerifyTokenTTL)
+	if err != nil {
+		return err
+	}
+	link := fmt.Sprintf("%s/v1/user/verify?token=%s", appURL(), url.QueryEscape(token))
+
+	return s.Mailer.Send(ctx, &mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your Cats Social email",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nPlease confirm your email address by opening the link below. It expires in %s.\n\n%s\n",
+			user.Name, VerifyTokenTTL, link,
+		),
+	})
+}
+
+// failLogin records the failed attempt and returns the error shown to the
+// client, which is the same for unknown emails and wrong passwords.
+func (s *UserServiceImpl) failLogin(ctx context.Context, userId, email, ipAddress string) error {
+	err := s.LoginGuardService.RecordFailedLogin(ctx, userId, email, ipAddress)
+	if err != nil {
+		return err
+	}
+	return usererror.ErrInvalidCredentials
+}
+
+// dummyPasswordHash is compared against when the email is unknown.
+var dummyPasswordHash = sync.OnceValue(func() string {
+	hashedPassword, err := bcrypt.HashPassword(ulid.Make().String())
+	if err != nil {
+		return ""
+	}
+	return hashedPassword
+})
+
+// createSession opens a new server-side session for the user and returns
+// the access/refresh token pair bound to it.
+func (s *UserServiceImpl) createSession(ctx context.Context, user *userentity.User) (*sessionentity.RefreshTokenResponse, error) {
+	refreshToken, err := randtoken.Generate(32)
+	if err != nil {
+		return nil, err
+	}
+
+	session := &sessionentity.Session{
+		Id:               ulid.Make().String(),
+		UserId:           user.Id,
+		RefreshTokenHash: randtoken.Hash(refreshToken),
+	}
+
+	err = s.SessionRepository.CreateSession(ctx, session, RefreshTokenTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken, err := jwt.GenerateToken(AccessTokenTTL, user.Id, session.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sessionentity.RefreshTokenResponse{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+	}, nil
+}
+
+// appURL is the public base URL used in links sent to users.
+func appURL() string {
+	if u := os.Getenv("APP_URL"); u != "" {
+		return u
+	}
+	return "http://localhost:8080"
+}
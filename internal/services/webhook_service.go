@@ -0,0 +1,322 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/danzBraham/cats-social/internal/entities/evententity"
+	"github.com/danzBraham/cats-social/internal/entities/userentity"
+	"github.com/danzBraham/cats-social/internal/entities/webhookentity"
+	"github.com/danzBraham/cats-social/internal/errors/webhookerror"
+	"github.com/danzBraham/cats-social/internal/helpers/cursor"
+	"github.com/danzBraham/cats-social/internal/helpers/randtoken"
+	"github.com/danzBraham/cats-social/internal/repositories"
+	"github.com/oklog/ulid/v2"
+)
+
+const (
+	WebhookSecretPrefix = "whsec_"
+
+	maxDeliveryAttempts = 10
+	// the first retry waits retryBaseDelay, every next one twice as long
+	retryBaseDelay = 30 * time.Second
+	retryMaxDelay  = 6 * time.Hour
+	webhookTimeout = 10 * time.Second
+	maxErrorLength = 500
+)
+
+type WebhookService interface {
+	CreateWebhook(ctx context.Context, userId string, role userentity.Role, payload *webhookentity.CreateWebhookRequest) (*webhookentity.CreateWebhookResponse, error)
+	GetWebhooks(ctx context.Context, userId string) ([]*webhookentity.GetWebhookResponse, error)
+	DeleteWebhook(ctx context.Context, userId, webhookId string) error
+	GetDeliveries(ctx context.Context, userId, webhookId string, params *webhookentity.DeliveryQueryParams) (*webhookentity.DeliveryPage, error)
+	Redeliver(ctx context.Context, userId, webhookId, deliveryId string) (*webhookentity.GetDeliveryResponse, error)
+	HandleEvent(ctx context.Context, event *evententity.Event) error
+	DeliverNext(ctx context.Context) (bool, error)
+}
+
+type WebhookServiceImpl struct {
+	WebhookRepository repositories.WebhookRepository
+	Client            *http.Client
+}
+
+func NewWebhookService(webhookRepository repositories.WebhookRepository) WebhookService {
+	return &WebhookServiceImpl{
+		WebhookRepository: webhookRepository,
+		Client:            newWebhookClient(),
+	}
+}
+
+func (s *WebhookServiceImpl) CreateWebhook(ctx context.Context, userId string, role userentity.Role, payload *webhookentity.CreateWebhookRequest) (*webhookentity.CreateWebhookResponse, error) {
+	if payload.Global && role != userentity.RoleAdmin {
+		return nil, webhookerror.ErrGlobalWebhookForbidden
+	}
+
+	secret, err := randtoken.Generate(32)
+	if err != nil {
+		return nil, err
+	}
+
+	webhook := &webhookentity.Webhook{
+		Id:         ulid.Make().String(),
+		UserId:     userId,
+		Url:        payload.Url,
+		Secret:     WebhookSecretPrefix + secret,
+		EventTypes: payload.EventTypes,
+		IsGlobal:   payload.Global,
+	}
+	if webhook.EventTypes == nil {
+		webhook.EventTypes = []webhookentity.EventType{}
+	}
+
+	createdAt, err := s.WebhookRepository.CreateWebhook(ctx, webhook)
+	if err != nil {
+		return nil, err
+	}
+
+	return &webhookentity.CreateWebhookResponse{
+		Id:         webhook.Id,
+		Url:        webhook.Url,
+		EventTypes: webhook.EventTypes,
+		Global:     webhook.IsGlobal,
+		Secret:     webhook.Secret,
+		CreatedAt:  createdAt,
+	}, nil
+}
+
+func (s *WebhookServiceImpl) GetWebhooks(ctx context.Context, userId string) ([]*webhookentity.GetWebhookResponse, error) {
+	return s.WebhookRepository.GetWebhooks(ctx, userId)
+}
+
+func (s *WebhookServiceImpl) DeleteWebhook(ctx context.Context, userId, webhookId string) error {
+	err := s.ensureWebhookOwner(ctx, userId, webhookId)
+	if err != nil {
+		return err
+	}
+
+	return s.WebhookRepository.DeleteWebhookById(ctx, webhookId)
+}
+
+func (s *WebhookServiceImpl) GetDeliveries(ctx context.Context, userId, webhookId string, params *webhookentity.DeliveryQueryParams) (*webhookentity.DeliveryPage, error) {
+	err := s.ensureWebhookOwner(ctx, userId, webhookId)
+	if err != nil {
+		return nil, err
+	}
+
+	deliveries, next, err := s.WebhookRepository.GetDeliveries(ctx, webhookId, params)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &webhookentity.DeliveryPage{
+		Deliveries: deliveries,
+		HasMore:    next != nil,
+	}
+	if next != nil {
+		page.NextCursor = cursor.Encode(next.CreatedAt, next.Id)
+	}
+
+	return page, nil
+}
+
+func (s *WebhookServiceImpl) Redeliver(ctx context.Context, userId, webhookId, deliveryId string) (*webhookentity.GetDeliveryResponse, error) {
+	err := s.ensureWebhookOwner(ctx, userId, webhookId)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.WebhookRepository.Redeliver(ctx, webhookId, deliveryId)
+}
+
+// HandleEvent queues the cat and match events for the webhooks of the
+// owners and the global ones, it ignores the other events. The webhook
+// event keeps the id of the domain event, so receivers can drop the
+// duplicates of an event that was dispatched more than once.
+func (s *WebhookServiceImpl) HandleEvent(ctx context.Context, event *evententity.Event) error {
+	var userIds []string
+	var data interface{}
+
+	switch event.Type {
+	case evententity.CatCreated, evententity.CatDeleted:
+		payload := &evententity.CatPayload{}
+		err := json.Unmarshal(event.Payload, payload)
+		if err != nil {
+			return err
+		}
+		userIds = []string{payload.OwnerId}
+		data = &webhookentity.CatData{
+			CatId:      payload.CatId,
+			OwnerId:    payload.OwnerId,
+			Name:       payload.Name,
+			Race:       payload.Race,
+			Sex:        payload.Sex,
+			AgeInMonth: payload.AgeInMonth,
+		}
+	case evententity.MatchRequested, evententity.MatchApproved, evententity.MatchRejected,
+		evententity.MatchDeleted, evententity.MatchUnmatched, evententity.MatchExpired:
+		payload := &evententity.MatchPayload{}
+		err := json.Unmarshal(event.Payload, payload)
+		if err != nil {
+			return err
+		}
+		userIds = []string{payload.IssuerId, payload.ReceiverId}
+		data = &webhookentity.MatchData{
+			MatchId:    payload.MatchId,
+			MatchCatId: payload.MatchCatId,
+			UserCatId:  payload.UserCatId,
+			Status:     payload.Status,
+		}
+	default:
+		return nil
+	}
+
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	return s.WebhookRepository.EnqueueEvent(ctx, &webhookentity.Event{
+		Id:        event.Id,
+		Type:      webhookentity.EventType(event.Type),
+		CreatedAt: event.CreatedAt,
+		Data:      encoded,
+	}, userIds)
+}
+
+// DeliverNext sends one due delivery. It reports false when there is nothing
+// to send. A failed delivery is retried with exponential backoff until it has
+// been attempted maxDeliveryAttempts times.
+func (s *WebhookServiceImpl) DeliverNext(ctx context.Context) (bool, error) {
+	delivery, err := s.WebhookRepository.ClaimDelivery(ctx, maxDeliveryAttempts)
+	if err != nil {
+		return false, err
+	}
+	if delivery == nil {
+		return false, nil
+	}
+
+	responseStatus, err := s.send(ctx, delivery)
+	if err == nil {
+		return true, s.WebhookRepository.CompleteDelivery(ctx, delivery.Id, *responseStatus)
+	}
+
+	var retryIn *time.Duration
+	if delivery.Attempts < maxDeliveryAttempts {
+		delay := retryDelay(delivery.Attempts)
+		retryIn = &delay
+	}
+
+	message := err.Error()
+	if len(message) > maxErrorLength {
+		message = message[:maxErrorLength]
+	}
+	return true, s.WebhookRepository.FailDelivery(ctx, delivery.Id, responseStatus, message, retryIn)
+}
+
+// send posts the event and returns the response status, if there was a
+// response. Anything but a 2xx is an error.
+func (s *WebhookServiceImpl) send(ctx context.Context, delivery *webhookentity.Delivery) (*int, error) {
+	ctx, cancel := context.WithTimeout(ctx, webhookTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.Url, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return nil, err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "cats-social-webhooks")
+	req.Header.Set("X-Webhook-Id", delivery.EventId)
+	req.Header.Set("X-Webhook-Delivery", delivery.Id)
+	req.Header.Set("X-Webhook-Event", string(delivery.EventType))
+	req.Header.Set("X-Webhook-Signature", "t="+timestamp+",v1="+signPayload(delivery.Secret, timestamp, delivery.Payload))
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	// drain a bit of the body so the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &resp.StatusCode, fmt.Errorf("unexpected response status %d", resp.StatusCode)
+	}
+	return &resp.StatusCode, nil
+}
+
+func (s *WebhookServiceImpl) ensureWebhookOwner(ctx context.Context, userId, webhookId string) error {
+	isWebhookOwner, err := s.WebhookRepository.IsWebhookOwner(ctx, webhookId, userId)
+	if err != nil {
+		return err
+	}
+	if !isWebhookOwner {
+		return webhookerror.ErrWebhookIdNotFound
+	}
+	return nil
+}
+
+// signPayload signs "<timestamp>.<payload>" with HMAC-SHA256. Signing the
+// timestamp lets receivers reject old deliveries that are replayed.
+func signPayload(secret, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func retryDelay(attempts int) time.Duration {
+	delay := retryBaseDelay
+	for i := 1; i < attempts && delay < retryMaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, retryMaxDelay)
+}
+
+// newWebhookClient doesn't follow redirects and, unless
+// WEBHOOK_ALLOW_PRIVATE_NETWORKS is set, refuses to connect to loopback,
+// private and link-local addresses so webhooks can't reach internal services.
+func newWebhookClient() *http.Client {
+	allowPrivate, _ := strconv.ParseBool(os.Getenv("WEBHOOK_ALLOW_PRIVATE_NETWORKS"))
+
+	dialer := &net.Dialer{Timeout: webhookTimeout}
+	if !allowPrivate {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsUnspecified() {
+				return webhookerror.ErrBlockedAddress
+			}
+			return nil
+		}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   webhookTimeout,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
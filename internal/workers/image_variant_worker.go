@@ -0,0 +1,49 @@
+package workers
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/danzBraham/cats-social/internal/services"
+)
+
+// ImageVariantWorker generates the variants of uploaded images in the
+// background, so uploads don't wait for the resizing.
+type ImageVariantWorker struct {
+	ImageService services.ImageService
+	Interval     time.Duration
+}
+
+func NewImageVariantWorker(imageService services.ImageService) *ImageVariantWorker {
+	return &ImageVariantWorker{
+		ImageService: imageService,
+		Interval:     2 * time.Second,
+	}
+}
+
+// Run polls the queue until ctx is cancelled. Every tick drains the queue
+// before going back to sleep.
+func (w *ImageVariantWorker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+
+	for {
+		for ctx.Err() == nil {
+			processed, err := w.ImageService.ProcessNextVariantJob(ctx)
+			if err != nil {
+				log.Printf("image variant worker: %v", err)
+				break
+			}
+			if !processed {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package workers
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/danzBraham/cats-social/internal/services"
+)
+
+// MatchExpiryWorker expires the pending match requests that outlived their
+// TTL, so they stop blocking new requests and sex changes of the cats.
+type MatchExpiryWorker struct {
+	MatchService services.MatchService
+	Interval     time.Duration
+}
+
+func NewMatchExpiryWorker(matchService services.MatchService) *MatchExpiryWorker {
+	return &MatchExpiryWorker{
+		MatchService: matchService,
+		Interval:     time.Minute,
+	}
+}
+
+// Run expires stale requests once at start and then every tick until ctx is
+// cancelled. Running it on several instances is safe.
+func (w *MatchExpiryWorker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+
+	for {
+		expired, err := w.MatchService.ExpireMatches(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("match expiry worker: %v", err)
+		}
+		if expired > 0 {
+			log.Printf("match expiry worker: expired %d match requests", expired)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package workers
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/danzBraham/cats-social/internal/services"
+)
+
+// OutboxWorker dispatches the domain events written to the outbox to the
+// handlers subscribed to the event bus.
+type OutboxWorker struct {
+	EventBus services.EventBus
+	Interval time.Duration
+}
+
+func NewOutboxWorker(eventBus services.EventBus) *OutboxWorker {
+	return &OutboxWorker{
+		EventBus: eventBus,
+		Interval: time.Second,
+	}
+}
+
+// Run polls the outbox until ctx is cancelled. Every tick dispatches all the
+// due events before going back to sleep.
+func (w *OutboxWorker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+
+	for {
+		for ctx.Err() == nil {
+			dispatched, err := w.EventBus.DispatchNext(ctx)
+			if err != nil {
+				log.Printf("outbox worker: %v", err)
+				break
+			}
+			if !dispatched {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
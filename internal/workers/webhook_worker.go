@@ -0,0 +1,49 @@
+package workers
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/danzBraham/cats-social/internal/services"
+)
+
+// WebhookWorker posts the queued webhook deliveries, so the requests that
+// trigger an event don't wait for the receivers.
+type WebhookWorker struct {
+	WebhookService services.WebhookService
+	Interval       time.Duration
+}
+
+func NewWebhookWorker(webhookService services.WebhookService) *WebhookWorker {
+	return &WebhookWorker{
+		WebhookService: webhookService,
+		Interval:       2 * time.Second,
+	}
+}
+
+// Run polls the outbox until ctx is cancelled. Every tick sends all the due
+// deliveries before going back to sleep.
+func (w *WebhookWorker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+
+	for {
+		for ctx.Err() == nil {
+			delivered, err := w.WebhookService.DeliverNext(ctx)
+			if err != nil {
+				log.Printf("webhook worker: %v", err)
+				break
+			}
+			if !delivered {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}